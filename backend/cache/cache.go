@@ -0,0 +1,84 @@
+// cache/cache.go
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// TTL is a small in-process cache whose entries expire after a fixed duration.
+// It is safe for concurrent use. Expired entries are dropped lazily on access and
+// whenever the cache grows past maxEntries.
+type TTL[K comparable, V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func NewTTL[K comparable, V any](ttl time.Duration, maxEntries int) *TTL[K, V] {
+	return &TTL[K, V]{ttl: ttl, maxEntries: maxEntries, entries: map[K]ttlEntry[V]{}}
+}
+
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *TTL[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full of live entries: start over rather than track recency
+		if len(c.entries) >= c.maxEntries {
+			c.entries = map[K]ttlEntry[V]{}
+		}
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+// Delete drops the entry for key, if any.
+func (c *TTL[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Clear drops every entry, e.g. after a write that invalidates all cached results.
+func (c *TTL[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[K]ttlEntry[V]{}
+}
+
+// GetOrLoad returns the cached value or calls load and caches its result.
+// Errors are not cached.
+func (c *TTL[K, V]) GetOrLoad(key K, load func() (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+	c.Set(key, v)
+	return v, nil
+}
@@ -0,0 +1,29 @@
+// Command recompute-reputation rebuilds every user's cached reputation from the
+// reputation ledger. The same operation is exposed as POST /admin/reputation/recompute.
+package main
+
+import (
+	"log"
+
+	"stackit/config"
+	"stackit/database"
+	"stackit/services"
+)
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+
+	updated, err := services.NewReputationService(db, cfg).RecomputeAll()
+	if err != nil {
+		log.Fatalf("Failed to recompute reputation: %v", err)
+	}
+	log.Printf("Recomputed reputation; %d users changed.", updated)
+}
@@ -9,6 +9,7 @@ import (
 
 	"stackit/config"
 	"stackit/models"
+	"stackit/services"
 )
 
 var DB *gorm.DB
@@ -25,6 +26,14 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 }
 
 func MigrateModels(db *gorm.DB) {
+	// Denormalized question stats need a one-off backfill when their columns are first added
+	backfillStats := db.Migrator().HasTable(&models.Question{}) && !db.Migrator().HasColumn(&models.Question{}, "AnswerCount")
+	// Likewise the reputation ledger is seeded from existing votes and accepted answers
+	backfillReputation := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "Reputation")
+	backfillAcceptedAt := db.Migrator().HasTable(&models.Answer{}) && !db.Migrator().HasColumn(&models.Answer{}, "AcceptedAt")
+	// Audit entries written before the hash chain are sealed into it once
+	sealAuditLog := db.Migrator().HasTable(&models.AuditLog{}) && !db.Migrator().HasColumn(&models.AuditLog{}, "Hash")
+
 	// Auto-migrate all models
 	err := db.AutoMigrate(
 		&models.User{},
@@ -34,9 +43,191 @@ func MigrateModels(db *gorm.DB) {
 		&models.QuestionTag{},
 		&models.Vote{},
 		&models.Notification{},
+		&models.Attachment{},
+		&models.CloseVote{},
+		&models.TagSynonym{},
+		&models.TagPreference{},
+		&models.Follow{},
+		&models.BookmarkList{},
+		&models.Bookmark{},
+		&models.ReputationEvent{},
+		&models.BadgeAward{},
+		&models.UserVisit{},
+		&models.Bounty{},
+		&models.LeaderboardEntry{},
+		&models.Flag{},
+		&models.FlagReview{},
+		&models.AuditLog{},
+		&models.ContentRule{},
+		&models.SpamToken{},
+		&models.SpamTrainingPost{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to auto-migrate database: %v", err)
 	}
+	if err := migrateSearch(db); err != nil {
+		log.Fatalf("Failed to migrate search indexes: %v", err)
+	}
+	if err := migrateListIndexes(db); err != nil {
+		log.Fatalf("Failed to migrate list indexes: %v", err)
+	}
+	if backfillStats {
+		if err := backfillQuestionStats(db); err != nil {
+			log.Fatalf("Failed to backfill question stats: %v", err)
+		}
+	}
+	if backfillAcceptedAt {
+		// Best available approximation for answers accepted before the column existed,
+		// and before the reputation backfill, which dates acceptances by it
+		if err := db.Exec(`UPDATE answers SET accepted_at = updated_at WHERE is_accepted`).Error; err != nil {
+			log.Fatalf("Failed to backfill accepted_at: %v", err)
+		}
+	}
+	if backfillReputation {
+		if err := backfillReputationLedger(db); err != nil {
+			log.Fatalf("Failed to backfill reputation: %v", err)
+		}
+	}
+	if sealAuditLog {
+		if err := services.SealAuditChain(db); err != nil {
+			log.Fatalf("Failed to seal audit log: %v", err)
+		}
+	}
+	// After sealing, which is the last update the audit log ever takes
+	if err := migrateAuditLog(db); err != nil {
+		log.Fatalf("Failed to protect audit log: %v", err)
+	}
 	log.Println("Database migration completed.")
 }
+
+// migrateSearch adds the full-text search columns and trigram index, which GORM
+// cannot express as model fields. Weights: title A, question body B, answer content C.
+func migrateSearch(db *gorm.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_title_trgm ON questions USING GIN (title gin_trgm_ops)`,
+		// Tag autocomplete: prefix matches use the pattern index, fuzzy matches the trigram one
+		`CREATE INDEX IF NOT EXISTS idx_tags_name_pattern ON tags (name text_pattern_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_tags_name_trgm ON tags USING GIN (name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_tag_synonyms_name_pattern ON tag_synonyms (name text_pattern_ops)`,
+		`ALTER TABLE questions ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(description, '')), 'B')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_search_vector ON questions USING GIN (search_vector)`,
+		`ALTER TABLE answers ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (setweight(to_tsvector('english', coalesce(content, '')), 'C')) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_answers_search_vector ON answers USING GIN (search_vector)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateAuditLog makes the audit log append-only at the database level: updates,
+// deletes and truncation are rejected. The hash chain catches what bypasses this,
+// such as dropping the trigger.
+func migrateAuditLog(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit_logs is append-only';
+			END;
+			$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_logs_no_modify ON audit_logs`,
+		`CREATE TRIGGER audit_logs_no_modify BEFORE UPDATE OR DELETE ON audit_logs
+			FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()`,
+		`DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs`,
+		`CREATE TRIGGER audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only()`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateListIndexes creates the composite and partial indexes behind the sort modes
+// and filters of GET /questions and the leaderboards. Partial indexes match GORM's
+// soft-delete condition.
+func migrateListIndexes(db *gorm.DB) error {
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_questions_newest ON questions (created_at DESC, id DESC) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_active ON questions (last_activity_at DESC, id DESC) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_votes ON questions (score DESC, id DESC) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_hot ON questions (hot_score DESC, id DESC) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_unanswered ON questions (created_at DESC, id DESC) WHERE deleted_at IS NULL AND answer_count = 0`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_owner_created ON questions (owner_id, created_at DESC) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_questions_accepted_created ON questions (has_accepted, created_at DESC) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_question_tags_tag_question ON question_tags (tag_id, question_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_answers_question ON answers (question_id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_leaderboard_reputation ON leaderboard_entries (period, tag_id, reputation DESC, user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_leaderboard_accepted ON leaderboard_entries (period, tag_id, accepted_answers DESC, user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_leaderboard_answer_score ON leaderboard_entries (period, tag_id, answer_score DESC, user_id)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backfillQuestionStats(db *gorm.DB) error {
+	statements := []string{
+		`UPDATE answers a SET score = COALESCE((SELECT SUM(v.type) FROM votes v WHERE v.answer_id = a.id), 0)`,
+		`UPDATE questions q SET
+			score = COALESCE((SELECT SUM(a.score) FROM answers a WHERE a.question_id = q.id AND a.deleted_at IS NULL), 0),
+			answer_count = (SELECT COUNT(*) FROM answers a WHERE a.question_id = q.id AND a.deleted_at IS NULL),
+			has_accepted = EXISTS (SELECT 1 FROM answers a WHERE a.question_id = q.id AND a.is_accepted AND a.deleted_at IS NULL),
+			last_activity_at = GREATEST(q.updated_at, COALESCE((SELECT MAX(a.updated_at) FROM answers a WHERE a.question_id = q.id), q.updated_at))`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	log.Println("Backfilled question stats.")
+	return nil
+}
+
+// backfillReputationLedger records events for votes and acceptances that predate the
+// ledger. Kinds and amounts match services.reputationDeltas; self-votes and self-accepts
+// earn nothing and the daily cap is not applied retroactively. Votes carry no time, so
+// they are dated by the answer; acceptances by when they happened, where known. Dating
+// them all now would put years of history into today's leaderboards.
+func backfillReputationLedger(db *gorm.DB) error {
+	statements := []string{
+		`INSERT INTO reputation_events (user_id, kind, delta, question_id, answer_id, actor_id, created_at)
+			SELECT a.owner_id, CASE WHEN v.type > 0 THEN 'upvote_received' ELSE 'downvote_received' END,
+				CASE WHEN v.type > 0 THEN 10 ELSE -2 END, a.question_id, a.id, v.user_id, a.created_at
+			FROM votes v JOIN answers a ON a.id = v.answer_id
+			WHERE a.deleted_at IS NULL AND a.owner_id <> v.user_id`,
+		`INSERT INTO reputation_events (user_id, kind, delta, question_id, answer_id, actor_id, created_at)
+			SELECT v.user_id, 'downvote_cast', -1, a.question_id, a.id, v.user_id, a.created_at
+			FROM votes v JOIN answers a ON a.id = v.answer_id
+			WHERE v.type < 0 AND a.deleted_at IS NULL AND a.owner_id <> v.user_id`,
+		`INSERT INTO reputation_events (user_id, kind, delta, question_id, answer_id, actor_id, created_at)
+			SELECT a.owner_id, 'answer_accepted', 15, a.question_id, a.id, q.owner_id, COALESCE(a.accepted_at, a.created_at)
+			FROM answers a JOIN questions q ON q.id = a.question_id
+			WHERE a.is_accepted AND a.deleted_at IS NULL AND a.owner_id <> q.owner_id`,
+		`INSERT INTO reputation_events (user_id, kind, delta, question_id, answer_id, actor_id, created_at)
+			SELECT q.owner_id, 'accepted_answer', 2, a.question_id, a.id, q.owner_id, COALESCE(a.accepted_at, a.created_at)
+			FROM answers a JOIN questions q ON q.id = a.question_id
+			WHERE a.is_accepted AND a.deleted_at IS NULL AND a.owner_id <> q.owner_id`,
+		`UPDATE users u SET reputation = 1 + COALESCE((SELECT SUM(e.delta) FROM reputation_events e WHERE e.user_id = u.id), 0)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	log.Println("Backfilled reputation ledger.")
+	return nil
+}
@@ -0,0 +1,233 @@
+// handlers/admin_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stackit/config"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// AdminHandler serves the user management API. Every route is mounted behind
+// AdminAuthMiddleware.
+type AdminHandler struct {
+	AdminService *services.AdminService
+	Validator    *validator.Validate
+}
+
+func NewAdminHandler(db *gorm.DB, cfg *config.Config, sessions *services.SessionService) *AdminHandler {
+	return &AdminHandler{
+		AdminService: services.NewAdminService(db, cfg, sessions),
+		Validator:    validator.New(),
+	}
+}
+
+// ListUsers searches users.
+// Query params: q (username or email substring), role, status (active|suspended|banned),
+// joined_from and joined_to (YYYY-MM-DD, joined_to inclusive, or RFC 3339).
+func (h *AdminHandler) ListUsers(c echo.Context) error {
+	filter := services.UserFilter{
+		Query:  c.QueryParam("q"),
+		Role:   c.QueryParam("role"),
+		Status: c.QueryParam("status"),
+	}
+	switch filter.Status {
+	case "", services.UserStatusActive, services.UserStatusSuspended, services.UserStatusBanned:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "status must be one of: active, suspended, banned")
+	}
+	var err error
+	if filter.JoinedFrom, err = parseDateParam(c.QueryParam("joined_from"), false); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid 'joined_from' date")
+	}
+	if filter.JoinedTo, err = parseDateParam(c.QueryParam("joined_to"), true); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid 'joined_to' date")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	users, next, err := h.AdminService.SearchUsers(filter, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch users")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.AdminService.CountUsers(filter)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count users")
+		}
+		total = &count
+	}
+
+	userResponses := []schemas.UserResponse{}
+	for i := range users {
+		userResponses = append(userResponses, toUserResponse(&users[i]))
+	}
+	return pagination.Respond(c, userResponses, next, total)
+}
+
+func (h *AdminHandler) ChangeRole(c echo.Context) error {
+	userID, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req schemas.RoleUpdate
+	if err := h.bind(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.AdminService.ChangeRole(auditActor(c), userID, req.Role)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *AdminHandler) SuspendUser(c echo.Context) error {
+	userID, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req schemas.SuspendRequest
+	if err := h.bind(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.AdminService.Suspend(auditActor(c), userID, time.Duration(req.DurationHours)*time.Hour, req.Reason)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *AdminHandler) UnsuspendUser(c echo.Context) error {
+	userID, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.AdminService.Unsuspend(auditActor(c), userID)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *AdminHandler) BanUser(c echo.Context) error {
+	userID, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req schemas.BanRequest
+	if err := h.bind(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.AdminService.Ban(auditActor(c), userID, req.Reason)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *AdminHandler) ResetPassword(c echo.Context) error {
+	userID, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	password, err := h.AdminService.ResetPassword(auditActor(c), userID)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, schemas.PasswordResetResponse{TemporaryPassword: password})
+}
+
+// ForceLogout revokes every token the user holds.
+func (h *AdminHandler) ForceLogout(c echo.Context) error {
+	userID, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.AdminService.ForceLogout(auditActor(c), userID); err != nil {
+		return adminError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// MergeUser merges the user in the URL, a duplicate account, into the target user.
+func (h *AdminHandler) MergeUser(c echo.Context) error {
+	userID, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req schemas.MergeUsersRequest
+	if err := h.bind(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.AdminService.MergeUsers(auditActor(c), userID, req.TargetUserID)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *AdminHandler) bind(c echo.Context, req interface{}) error {
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+func userIDParam(c echo.Context) (uint, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	return uint(id), nil
+}
+
+// auditActor identifies the authenticated user and their client for the audit log.
+func auditActor(c echo.Context) services.AuditActor {
+	actor := services.AuditActor{IP: c.RealIP(), UserAgent: c.Request().UserAgent()}
+	if userID, ok := c.Get("userID").(uint); ok {
+		actor.UserID = &userID
+	}
+	return actor
+}
+
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrMergeSameUser):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrCannotManageSelf):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrUserBanned), errors.Is(err, services.ErrNotSuspended):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update user")
+}
@@ -2,9 +2,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"stackit/config"
+	"stackit/middlewares"
+	"stackit/pagination"
 	"stackit/schemas"
 	"stackit/services"
 
@@ -14,16 +18,26 @@ import (
 )
 
 type AnswerHandler struct {
-	AnswerService   *services.AnswerService
-	QuestionService *services.QuestionService // To check question ownership
-	Validator       *validator.Validate
+	AnswerService    *services.AnswerService
+	QuestionService  *services.QuestionService // To check question ownership
+	FollowService    *services.FollowService
+	PrivilegeService *services.PrivilegeService
+	BadgeService     *services.BadgeService
+	UserService      *services.UserService
+	Notifier         *services.NotificationDispatcher
+	Validator        *validator.Validate
 }
 
-func NewAnswerHandler(db *gorm.DB) *AnswerHandler {
+func NewAnswerHandler(db *gorm.DB, cfg *config.Config, notifier *services.NotificationDispatcher, filter *services.ContentFilter) *AnswerHandler {
 	return &AnswerHandler{
-		AnswerService:   services.NewAnswerService(db),
-		QuestionService: services.NewQuestionService(db),
-		Validator:       validator.New(),
+		AnswerService:    &services.AnswerService{DB: db, Config: cfg, Filter: filter},
+		QuestionService:  services.NewQuestionService(db),
+		FollowService:    services.NewFollowService(db),
+		PrivilegeService: services.NewPrivilegeService(db, cfg),
+		BadgeService:     services.NewBadgeService(db, cfg),
+		UserService:      services.NewUserService(db),
+		Notifier:         notifier,
+		Validator:        validator.New(),
 	}
 }
 
@@ -45,18 +59,27 @@ func (h *AnswerHandler) CreateAnswer(c echo.Context) error {
 
 	answer, err := h.AnswerService.CreateAnswer(&answerCreate, userID)
 	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidAttachments):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrQuestionNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Question not found")
+		case errors.Is(err, services.ErrQuestionClosed):
+			return echo.NewHTTPError(http.StatusForbidden, "This question is closed and no longer accepts answers.")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create answer: "+err.Error())
 	}
 
-	return c.JSON(http.StatusCreated, schemas.AnswerResponse{
-		ID:         answer.ID,
-		Content:    answer.Content,
-		QuestionID: answer.QuestionID,
-		OwnerID:    answer.OwnerID,
-		IsAccepted: answer.IsAccepted,
-		CreatedAt:  answer.CreatedAt,
-		UpdatedAt:  &answer.UpdatedAt,
-	})
+	// Quarantined answers stay unannounced
+	if answer.QuarantinedAt == nil {
+		h.Notifier.Publish(services.ActivityEvent{
+			Kind:       services.NotificationKindNewAnswer,
+			QuestionID: answer.QuestionID,
+			ActorID:    userID,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, toAnswerResponse(answer, contentBoth))
 }
 
 func (h *AnswerHandler) GetAnswersByQuestionID(c echo.Context) error {
@@ -65,24 +88,104 @@ func (h *AnswerHandler) GetAnswersByQuestionID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
 	}
 
-	answers, err := h.AnswerService.GetAnswersByQuestionID(uint(questionID))
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+	view, err := contentView(c)
+	if err != nil {
+		return err
+	}
+	sort := c.QueryParam("sort")
+	if sort != "" && sort != services.AnswerSortOldest && sort != services.AnswerSortVotes {
+		return echo.NewHTTPError(http.StatusBadRequest, "sort must be 'oldest' or 'votes'")
+	}
+
+	question, err := h.QuestionService.GetQuestionByID(uint(questionID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Question not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch question")
+	}
+	// A hidden question's answers are as hidden as the question itself
+	if question.HiddenAt != nil && !canSeeHidden(c, question.OwnerID) {
+		return echo.NewHTTPError(http.StatusNotFound, "Question not found")
+	}
+	userID, _ := c.Get("userID").(uint)
+	isModerator := middlewares.IsModerator(c.Get("userRole").(string))
+
+	answers, next, err := h.AnswerService.GetAnswersByQuestionID(question.ID, userID, isModerator, sort, page)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch answers")
 	}
 
+	var total *int64
+	if page.WithTotal {
+		count, err := h.AnswerService.CountAnswersByQuestionID(question.ID, userID, isModerator)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count answers")
+		}
+		total = &count
+	}
+
 	answerResponses := []schemas.AnswerResponse{}
-	for _, ans := range answers {
-		answerResponses = append(answerResponses, schemas.AnswerResponse{
-			ID:         ans.ID,
-			Content:    ans.Content,
-			QuestionID: ans.QuestionID,
-			OwnerID:    ans.OwnerID,
-			IsAccepted: ans.IsAccepted,
-			CreatedAt:  ans.CreatedAt,
-			UpdatedAt:  &ans.UpdatedAt,
-		})
+	for i := range answers {
+		answerResponses = append(answerResponses, toAnswerResponse(&answers[i], view))
+	}
+	return pagination.Respond(c, answerResponses, next, total)
+}
+
+// GetUserAnswers lists the answers written by :username; ?sort= is newest (default)
+// or votes.
+func (h *AnswerHandler) GetUserAnswers(c echo.Context) error {
+	user, err := h.UserService.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+	view, err := contentView(c)
+	if err != nil {
+		return err
+	}
+	sort := c.QueryParam("sort")
+	if sort != "" && sort != services.AnswerSortNewest && sort != services.AnswerSortVotes {
+		return echo.NewHTTPError(http.StatusBadRequest, "sort must be 'newest' or 'votes'")
 	}
-	return c.JSON(http.StatusOK, answerResponses)
+
+	includeHidden := canSeeHidden(c, user.ID)
+	answers, next, err := h.AnswerService.GetAnswersByOwner(user.ID, includeHidden, sort, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch answers")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.AnswerService.CountAnswersByOwner(user.ID, includeHidden)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count answers")
+		}
+		total = &count
+	}
+
+	answerResponses := []schemas.AnswerResponse{}
+	for i := range answers {
+		answerResponses = append(answerResponses, toAnswerResponse(&answers[i], view))
+	}
+	return pagination.Respond(c, answerResponses, next, total)
 }
 
 func (h *AnswerHandler) AcceptAnswer(c echo.Context) error {
@@ -114,15 +217,15 @@ func (h *AnswerHandler) AcceptAnswer(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to accept answer: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, schemas.AnswerResponse{
-		ID:         updatedAnswer.ID,
-		Content:    updatedAnswer.Content,
+	h.Notifier.Publish(services.ActivityEvent{
+		Kind:       services.NotificationKindAnswerAccepted,
 		QuestionID: updatedAnswer.QuestionID,
-		OwnerID:    updatedAnswer.OwnerID,
-		IsAccepted: updatedAnswer.IsAccepted,
-		CreatedAt:  updatedAnswer.CreatedAt,
-		UpdatedAt:  &updatedAnswer.UpdatedAt,
+		AnswerID:   &updatedAnswer.ID,
+		ActorID:    currentUserID,
 	})
+	h.BadgeService.EvaluateAsync(services.BadgeTriggerAnswerAccepted, updatedAnswer.OwnerID)
+
+	return c.JSON(http.StatusOK, toAnswerResponse(updatedAnswer, contentBoth))
 }
 
 func (h *AnswerHandler) VoteAnswer(c echo.Context) error {
@@ -141,9 +244,58 @@ func (h *AnswerHandler) VoteAnswer(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	privilege := services.PrivilegeUpvote
+	if voteCreate.Type < 0 {
+		privilege = services.PrivilegeDownvote
+	}
+	if err := requirePrivilege(c, h.PrivilegeService, privilege); err != nil {
+		return err
+	}
+
 	if err := h.AnswerService.CreateOrUpdateVote(userID, uint(answerID), voteCreate.Type); err != nil {
+		if errors.Is(err, services.ErrQuestionLocked) {
+			return echo.NewHTTPError(http.StatusForbidden, "This question is locked.")
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Answer not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process vote: "+err.Error())
 	}
 
+	// The vote already succeeded, so a failed lookup only delays the badge to the next sweep
+	if answer, err := h.AnswerService.GetAnswerByID(uint(answerID)); err == nil && answer != nil {
+		h.BadgeService.EvaluateAsync(services.BadgeTriggerAnswerVoted, answer.OwnerID)
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Vote processed successfully"})
 }
+
+func (h *AnswerHandler) FollowAnswer(c echo.Context) error {
+	return setFollowing(c, h.FollowService, services.FollowTargetAnswer, true)
+}
+
+func (h *AnswerHandler) UnfollowAnswer(c echo.Context) error {
+	return setFollowing(c, h.FollowService, services.FollowTargetAnswer, false)
+}
+
+// setFollowing follows or unfollows the question or answer identified by :id.
+func setFollowing(c echo.Context, followService *services.FollowService, targetType string, follow bool) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+targetType+" ID")
+	}
+	userID := c.Get("userID").(uint)
+
+	if follow {
+		err = followService.Follow(userID, targetType, uint(id))
+	} else {
+		err = followService.Unfollow(userID, targetType, uint(id))
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrFollowTargetNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update follow")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+// handlers/attachment_handler.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"stackit/config"
+	"stackit/services"
+	"stackit/storage"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type AttachmentHandler struct {
+	AttachmentService *services.AttachmentService
+}
+
+func NewAttachmentHandler(db *gorm.DB, store storage.Storage, cfg *config.Config) *AttachmentHandler {
+	return &AttachmentHandler{
+		AttachmentService: services.NewAttachmentService(db, store, cfg),
+	}
+}
+
+func (h *AttachmentHandler) UploadAttachment(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	userRole := c.Get("userRole").(string)
+
+	if userRole == "guest" {
+		return echo.NewHTTPError(http.StatusForbidden, "Guest users cannot upload files.")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing multipart field 'file'")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read uploaded file")
+	}
+	defer file.Close()
+
+	attachment, err := h.AttachmentService.Upload(c.Request().Context(), userID, fileHeader.Filename, file)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileTooLarge):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, services.ErrUnsupportedFile):
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, services.ErrUploadQuota), errors.Is(err, services.ErrImageTooLarge):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to upload file: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, toAttachmentResponse(attachment))
+}
+
+// GetAttachment serves an attachment of a visible post. It is public, so uploads
+// that are unlinked or whose post is deleted or hidden are reported as not found.
+func (h *AttachmentHandler) GetAttachment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid attachment ID")
+	}
+
+	attachment, err := h.AttachmentService.GetPublishedAttachment(uint(id))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch attachment")
+	}
+	if attachment == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Attachment not found")
+	}
+
+	// The post can be hidden or deleted at any time, so caches must check back soon
+	etag := `"` + attachment.SHA256 + `"`
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "private, max-age=300")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	blob, err := h.AttachmentService.Open(c.Request().Context(), attachment)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Attachment not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read attachment")
+	}
+	defer blob.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("%s; filename=%q", disposition, attachment.FileName))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachment.Size, 10))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set(echo.HeaderContentType, attachment.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = io.Copy(c.Response(), blob)
+	return err
+}
+
+func (h *AttachmentHandler) DeleteAttachment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid attachment ID")
+	}
+
+	if err := h.AttachmentService.DeleteAttachment(c.Request().Context(), auditActor(c), uint(id)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete attachment: "+err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
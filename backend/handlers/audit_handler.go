@@ -0,0 +1,162 @@
+// handlers/audit_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// AuditHandler serves the audit log. Every route is mounted behind AdminAuthMiddleware.
+type AuditHandler struct {
+	AuditService *services.AuditService
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{AuditService: services.NewAuditService(db)}
+}
+
+// ListAuditLogs returns entries, newest first.
+// Query params: actor_id, action, target_type, target_id, from and to (YYYY-MM-DD,
+// to inclusive, or RFC 3339).
+func (h *AuditHandler) ListAuditLogs(c echo.Context) error {
+	filter, err := auditFilterFromRequest(c)
+	if err != nil {
+		return err
+	}
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	entries, next, err := h.AuditService.Search(filter, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch audit log")
+	}
+	var total *int64
+	if page.WithTotal {
+		count, err := h.AuditService.Count(filter)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count audit log")
+		}
+		total = &count
+	}
+
+	responses := make([]schemas.AuditLogResponse, 0, len(entries))
+	for i := range entries {
+		responses = append(responses, toAuditLogResponse(&entries[i]))
+	}
+	return pagination.Respond(c, responses, next, total)
+}
+
+// ExportAuditLogs streams matching entries as JSON Lines, oldest first. It takes the
+// same filters as ListAuditLogs.
+func (h *AuditHandler) ExportAuditLogs(c echo.Context) error {
+	filter, err := auditFilterFromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit-log.jsonl"`)
+	encoder := json.NewEncoder(res)
+	err = h.AuditService.Export(filter, func(entries []models.AuditLog) error {
+		if !res.Committed {
+			res.WriteHeader(http.StatusOK)
+		}
+		for i := range entries {
+			if err := encoder.Encode(toAuditLogResponse(&entries[i])); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		if res.Committed {
+			// Too late for an error status; the truncated export is the signal
+			log.Printf("Failed to export audit log: %v", err)
+			return nil
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to export audit log")
+	}
+	if !res.Committed {
+		res.WriteHeader(http.StatusOK)
+	}
+	return nil
+}
+
+// VerifyAuditLog checks the hash chain over the whole log.
+func (h *AuditHandler) VerifyAuditLog(c echo.Context) error {
+	result, err := h.AuditService.Verify()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify audit log")
+	}
+	return c.JSON(http.StatusOK, schemas.AuditVerificationResponse{
+		Valid:      result.Valid,
+		Checked:    result.Checked,
+		BrokenAtID: result.BrokenAtID,
+		Reason:     result.Reason,
+	})
+}
+
+func auditFilterFromRequest(c echo.Context) (services.AuditFilter, error) {
+	filter := services.AuditFilter{
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("target_type"),
+	}
+	var err error
+	if filter.ActorID, err = uintQueryParam(c, "actor_id"); err != nil {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid 'actor_id'")
+	}
+	if filter.TargetID, err = uintQueryParam(c, "target_id"); err != nil {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid 'target_id'")
+	}
+	if filter.From, err = parseDateParam(c.QueryParam("from"), false); err != nil {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid 'from' date")
+	}
+	if filter.To, err = parseDateParam(c.QueryParam("to"), true); err != nil {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid 'to' date")
+	}
+	return filter, nil
+}
+
+func uintQueryParam(c echo.Context, name string) (*uint, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return nil, err
+	}
+	id := uint(n)
+	return &id, nil
+}
+
+func toAuditLogResponse(l *models.AuditLog) schemas.AuditLogResponse {
+	return schemas.AuditLogResponse{
+		ID:         l.ID,
+		ActorID:    l.ActorID,
+		Action:     l.Action,
+		TargetType: l.TargetType,
+		TargetID:   l.TargetID,
+		Diff:       json.RawMessage(l.Diff),
+		Details:    json.RawMessage(l.Details),
+		IP:         l.IP,
+		UserAgent:  l.UserAgent,
+		PrevHash:   l.PrevHash,
+		Hash:       l.Hash,
+		CreatedAt:  l.CreatedAt,
+	}
+}
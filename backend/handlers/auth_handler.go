@@ -2,6 +2,8 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -16,16 +18,18 @@ import (
 )
 
 type AuthHandler struct {
-	AuthService *services.AuthService
-	Config      *config.Config
-	Validator   *validator.Validate
+	AuthService  *services.AuthService
+	AuditService *services.AuditService
+	Config       *config.Config
+	Validator    *validator.Validate
 }
 
 func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		AuthService: services.NewAuthService(db),
-		Config:      cfg,
-		Validator:   validator.New(),
+		AuthService:  services.NewAuthService(db),
+		AuditService: services.NewAuditService(db),
+		Config:       cfg,
+		Validator:    validator.New(),
 	}
 }
 
@@ -43,14 +47,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	userResp := schemas.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		Role:      user.Role,
-		IsActive:  user.IsActive,
-		CreatedAt: user.CreatedAt,
-	}
+	userResp := toUserResponse(user)
 	return c.JSON(http.StatusCreated, userResp)
 }
 
@@ -65,18 +62,43 @@ func (h *AuthHandler) Login(c echo.Context) error {
 
 	user, err := h.AuthService.AuthenticateUser(userLogin.Username, userLogin.Password)
 	if err != nil {
+		h.audit(auditActor(c), services.AuditEntry{
+			Action:  services.AuditLoginFailed,
+			Details: map[string]interface{}{"username": userLogin.Username, "reason": err.Error()},
+		})
+		if errors.Is(err, services.ErrAccountBanned) || errors.Is(err, services.ErrAccountSuspended) {
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
 
 	// Generate JWT token
 	expirationTime := time.Now().Add(time.Duration(h.Config.AccessTokenExpireMinutes) * time.Minute)
-	tokenString, err := utils.GenerateJWT(user.Username, user.Role, expirationTime, h.Config.SecretKey)
+	tokenString, err := utils.GenerateJWT(user.ID, user.Username, user.Role, user.SessionVersion, expirationTime, h.Config.SecretKey)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
 	}
 
+	actor := auditActor(c)
+	actor.UserID = &user.ID
+	h.audit(actor, services.AuditEntry{Action: services.AuditLoginSucceeded, TargetType: services.AuditTargetUser, TargetID: &user.ID})
+	h.audit(actor, services.AuditEntry{
+		Action:     services.AuditTokenIssued,
+		TargetType: services.AuditTargetUser,
+		TargetID:   &user.ID,
+		Details:    map[string]interface{}{"expires_at": expirationTime.UTC(), "session_version": user.SessionVersion},
+	})
+
 	return c.JSON(http.StatusOK, schemas.Token{
 		AccessToken: tokenString,
 		TokenType:   "bearer",
 	})
 }
+
+// audit records a login event. A failure to record it is logged but does not fail
+// the login.
+func (h *AuthHandler) audit(actor services.AuditActor, entry services.AuditEntry) {
+	if err := h.AuditService.Record(actor, entry); err != nil {
+		log.Printf("Failed to audit %s: %v", entry.Action, err)
+	}
+}
@@ -0,0 +1,155 @@
+// handlers/avatar_handler.go
+package handlers
+
+import (
+	"errors"
+	"image"
+	"net/http"
+	"strconv"
+
+	"stackit/config"
+	"stackit/services"
+	"stackit/storage"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type AvatarHandler struct {
+	AvatarService *services.AvatarService
+	UserService   *services.UserService
+}
+
+func NewAvatarHandler(db *gorm.DB, store storage.Storage, cfg *config.Config) *AvatarHandler {
+	return &AvatarHandler{
+		AvatarService: services.NewAvatarService(db, store, cfg),
+		UserService:   services.NewUserService(db),
+	}
+}
+
+// UploadAvatar replaces the current user's avatar. The optional form fields x, y and
+// size select the square to crop, in pixels of the uploaded image; without them the
+// centred square is used.
+func (h *AvatarHandler) UploadAvatar(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	userRole := c.Get("userRole").(string)
+
+	if userRole == "guest" {
+		return echo.NewHTTPError(http.StatusForbidden, "Guest users cannot upload avatars.")
+	}
+
+	crop, err := cropFromForm(c)
+	if err != nil {
+		return err
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing multipart field 'file'")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read uploaded file")
+	}
+	defer file.Close()
+
+	user, err := h.AvatarService.Upload(c.Request().Context(), userID, file, crop)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileTooLarge):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, services.ErrUnsupportedFile):
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, services.ErrImageTooLarge):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to upload avatar: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *AvatarHandler) DeleteAvatar(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	if err := h.AvatarService.Remove(c.Request().Context(), userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove avatar: "+err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetAvatar serves a user's avatar as PNG. Avatar URLs carry the avatar version in
+// ?v=, so responses to them can be cached indefinitely; a version other than the
+// current one is not found. Unversioned requests, and identicons served because the
+// upload could not be read, are cached briefly or not at all.
+func (h *AvatarHandler) GetAvatar(c echo.Context) error {
+	size := 128
+	if raw := c.QueryParam("size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid avatar size")
+		}
+		size = n
+	}
+
+	user, err := h.UserService.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	version := c.QueryParam("v")
+	if version != "" && version != services.AvatarVersion(user) {
+		return echo.NewHTTPError(http.StatusNotFound, "Avatar version not found")
+	}
+
+	avatar, err := h.AvatarService.Get(c.Request().Context(), user, size)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load avatar")
+	}
+
+	header := c.Response().Header()
+	header.Set("ETag", avatar.ETag)
+	switch {
+	case avatar.Fallback:
+		header.Set("Cache-Control", "no-store")
+	case version == "":
+		header.Set("Cache-Control", "public, max-age=300")
+	default:
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	if c.Request().Header.Get("If-None-Match") == avatar.ETag {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, avatar.ContentType, avatar.Data)
+}
+
+// cropFromForm reads the optional crop square; it is empty when no field is set.
+func cropFromForm(c echo.Context) (image.Rectangle, error) {
+	fields := []string{"x", "y", "size"}
+	values := make([]int, len(fields))
+	set := 0
+	for i, field := range fields {
+		raw := c.FormValue(field)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return image.Rectangle{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid crop field '"+field+"'")
+		}
+		values[i] = n
+		set++
+	}
+	if set == 0 {
+		return image.Rectangle{}, nil
+	}
+	if set < len(fields) || values[2] == 0 {
+		return image.Rectangle{}, echo.NewHTTPError(http.StatusBadRequest, "Crop requires x, y and a positive size")
+	}
+	x, y, side := values[0], values[1], values[2]
+	return image.Rect(x, y, x+side, y+side), nil
+}
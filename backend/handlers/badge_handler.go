@@ -0,0 +1,126 @@
+// handlers/badge_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type BadgeHandler struct {
+	BadgeService *services.BadgeService
+	UserService  *services.UserService
+}
+
+func NewBadgeHandler(db *gorm.DB, cfg *config.Config) *BadgeHandler {
+	return &BadgeHandler{
+		BadgeService: services.NewBadgeService(db, cfg),
+		UserService:  services.NewUserService(db),
+	}
+}
+
+// ListBadges returns the badge catalogue with how often each badge was awarded.
+func (h *BadgeHandler) ListBadges(c echo.Context) error {
+	counts, err := h.BadgeService.CountHolders()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch badges")
+	}
+
+	badgeResponses := []schemas.BadgeResponse{}
+	for _, b := range services.BadgeCatalogue {
+		badgeResponses = append(badgeResponses, schemas.BadgeResponse{
+			Slug:        b.Slug,
+			Name:        b.Name,
+			Description: b.Description,
+			Tier:        b.Tier,
+			AwardCount:  counts[b.Slug],
+		})
+	}
+	return c.JSON(http.StatusOK, badgeResponses)
+}
+
+// GetBadgeHolders lists the awards of a badge, newest first.
+func (h *BadgeHandler) GetBadgeHolders(c echo.Context) error {
+	slug := c.Param("slug")
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	awards, next, err := h.BadgeService.GetHolders(slug, page)
+	if err != nil {
+		if errors.Is(err, services.ErrBadgeNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch badge holders")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.BadgeService.CountBadgeHolders(slug)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count badge holders")
+		}
+		total = &count
+	}
+	return pagination.Respond(c, toBadgeAwardResponses(awards), next, total)
+}
+
+// GetUserBadges lists the badges a user has earned, newest first.
+func (h *BadgeHandler) GetUserBadges(c echo.Context) error {
+	user, err := h.UserService.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	awards, next, err := h.BadgeService.GetUserBadges(user.ID, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch badges")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.BadgeService.CountUserBadges(user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count badges")
+		}
+		total = &count
+	}
+	return pagination.Respond(c, toBadgeAwardResponses(awards), next, total)
+}
+
+func toBadgeAwardResponses(awards []models.BadgeAward) []schemas.BadgeAwardResponse {
+	awardResponses := []schemas.BadgeAwardResponse{}
+	for _, a := range awards {
+		badge, _ := services.FindBadge(a.Badge)
+		awardResponses = append(awardResponses, schemas.BadgeAwardResponse{
+			ID:        a.ID,
+			Badge:     a.Badge,
+			Name:      badge.Name,
+			Tier:      badge.Tier,
+			UserID:    a.UserID,
+			Username:  a.User.Username,
+			PostType:  a.PostType,
+			PostID:    a.PostID,
+			AwardedAt: a.CreatedAt,
+		})
+	}
+	return awardResponses
+}
@@ -0,0 +1,247 @@
+// handlers/bookmark_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type BookmarkHandler struct {
+	BookmarkService *services.BookmarkService
+	Validator       *validator.Validate
+}
+
+func NewBookmarkHandler(db *gorm.DB) *BookmarkHandler {
+	return &BookmarkHandler{
+		BookmarkService: services.NewBookmarkService(db),
+		Validator:       validator.New(),
+	}
+}
+
+// SaveBookmark bookmarks the question in the URL, or updates the list and note of an
+// existing bookmark.
+func (h *BookmarkHandler) SaveBookmark(c echo.Context) error {
+	questionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+	}
+	userID := c.Get("userID").(uint)
+
+	var req schemas.BookmarkRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	bookmark, err := h.BookmarkService.SaveBookmark(userID, uint(questionID), req.ListID, req.Note)
+	if err != nil {
+		return bookmarkError(err)
+	}
+	return c.JSON(http.StatusOK, toBookmarkResponse(bookmark, contentSource))
+}
+
+func (h *BookmarkHandler) DeleteBookmark(c echo.Context) error {
+	questionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+	}
+	userID := c.Get("userID").(uint)
+
+	if err := h.BookmarkService.DeleteBookmark(userID, uint(questionID)); err != nil {
+		return bookmarkError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetBookmarks lists the caller's bookmarks. Query params: sort (newest, oldest,
+// activity, votes) and list (a list ID, or "none" for unlisted bookmarks).
+func (h *BookmarkHandler) GetBookmarks(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+	view, err := contentView(c)
+	if err != nil {
+		return err
+	}
+
+	filter := services.BookmarkFilter{Sort: c.QueryParam("sort")}
+	switch filter.Sort {
+	case "", services.BookmarkSortNewest, services.BookmarkSortOldest, services.BookmarkSortActivity, services.BookmarkSortVotes:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "sort must be one of: newest, oldest, activity, votes")
+	}
+	switch list := c.QueryParam("list"); list {
+	case "":
+	case "none":
+		filter.Unlisted = true
+	default:
+		id, err := strconv.Atoi(list)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "list must be a list ID or 'none'")
+		}
+		listID := uint(id)
+		filter.ListID = &listID
+	}
+
+	bookmarks, next, err := h.BookmarkService.GetBookmarks(userID, filter, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bookmarks")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.BookmarkService.CountBookmarks(userID, filter)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count bookmarks")
+		}
+		total = &count
+	}
+
+	bookmarkResponses := []schemas.BookmarkResponse{}
+	for i := range bookmarks {
+		bookmarkResponses = append(bookmarkResponses, toBookmarkResponse(&bookmarks[i], view))
+	}
+	return pagination.Respond(c, bookmarkResponses, next, total)
+}
+
+func (h *BookmarkHandler) GetLists(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	lists, err := h.BookmarkService.GetLists(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bookmark lists")
+	}
+
+	listResponses := []schemas.BookmarkListResponse{}
+	for _, l := range lists {
+		listResponses = append(listResponses, schemas.BookmarkListResponse{
+			ID:            l.ID,
+			Name:          l.Name,
+			BookmarkCount: l.BookmarkCount,
+			CreatedAt:     l.CreatedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, listResponses)
+}
+
+func (h *BookmarkHandler) CreateList(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req schemas.BookmarkListRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	list, err := h.BookmarkService.CreateList(userID, req.Name)
+	if err != nil {
+		return bookmarkError(err)
+	}
+	return c.JSON(http.StatusCreated, toBookmarkListResponse(list))
+}
+
+func (h *BookmarkHandler) RenameList(c echo.Context) error {
+	listID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid list ID")
+	}
+	userID := c.Get("userID").(uint)
+
+	var req schemas.BookmarkListRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	list, err := h.BookmarkService.RenameList(userID, uint(listID), req.Name)
+	if err != nil {
+		return bookmarkError(err)
+	}
+	return c.JSON(http.StatusOK, toBookmarkListResponse(list))
+}
+
+// DeleteList removes a list; its bookmarks are kept as unlisted.
+func (h *BookmarkHandler) DeleteList(c echo.Context) error {
+	listID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid list ID")
+	}
+	userID := c.Get("userID").(uint)
+
+	if err := h.BookmarkService.DeleteList(userID, uint(listID)); err != nil {
+		return bookmarkError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func bookmarkError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrQuestionNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Question not found")
+	case errors.Is(err, services.ErrBookmarkNotFound), errors.Is(err, services.ErrBookmarkListNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrBookmarkListExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update bookmarks")
+}
+
+func toBookmarkResponse(b *models.Bookmark, view string) schemas.BookmarkResponse {
+	question := toQuestionResponse(&b.Question, view)
+	question.IsBookmarked = true
+	return schemas.BookmarkResponse{
+		ID:         b.ID,
+		QuestionID: b.QuestionID,
+		ListID:     b.ListID,
+		Note:       b.Note,
+		Question:   question,
+		CreatedAt:  b.CreatedAt,
+		UpdatedAt:  b.UpdatedAt,
+	}
+}
+
+func toBookmarkListResponse(l *models.BookmarkList) schemas.BookmarkListResponse {
+	return schemas.BookmarkListResponse{
+		ID:        l.ID,
+		Name:      l.Name,
+		CreatedAt: l.CreatedAt,
+	}
+}
+
+// markBookmarked sets IsBookmarked on the responses the user has bookmarked.
+func markBookmarked(bookmarks *services.BookmarkService, userID uint, responses []schemas.QuestionResponse) error {
+	ids := make([]uint, 0, len(responses))
+	for _, r := range responses {
+		ids = append(ids, r.ID)
+	}
+	bookmarked, err := bookmarks.BookmarkedQuestionIDs(userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range responses {
+		responses[i].IsBookmarked = bookmarked[responses[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// handlers/bounty_handler.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type BountyHandler struct {
+	BountyService   *services.BountyService
+	BookmarkService *services.BookmarkService
+	Config          *config.Config
+	Validator       *validator.Validate
+}
+
+func NewBountyHandler(db *gorm.DB, cfg *config.Config) *BountyHandler {
+	return &BountyHandler{
+		BountyService:   services.NewBountyService(db, cfg),
+		BookmarkService: services.NewBookmarkService(db),
+		Config:          cfg,
+		Validator:       validator.New(),
+	}
+}
+
+// StartBounty offers reputation on the question in the URL.
+func (h *BountyHandler) StartBounty(c echo.Context) error {
+	questionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+	}
+	userID := c.Get("userID").(uint)
+
+	if c.Get("userRole").(string) == "guest" {
+		return echo.NewHTTPError(http.StatusForbidden, "Guest users cannot offer bounties.")
+	}
+
+	var req schemas.BountyCreate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Days == 0 {
+		req.Days = h.Config.BountyMaxDays
+	}
+
+	bounty, err := h.BountyService.StartBounty(userID, uint(questionID), req.Amount, req.Reason, req.Days)
+	if err != nil {
+		return h.bountyError(err)
+	}
+	return c.JSON(http.StatusCreated, toBountyResponse(bounty))
+}
+
+// AwardBounty gives a bounty to an answer on its question.
+func (h *BountyHandler) AwardBounty(c echo.Context) error {
+	bountyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid bounty ID")
+	}
+	userID := c.Get("userID").(uint)
+
+	var req schemas.BountyAwardRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	bounty, err := h.BountyService.AwardBounty(userID, uint(bountyID), req.AnswerID)
+	if err != nil {
+		return h.bountyError(err)
+	}
+	return c.JSON(http.StatusOK, toBountyResponse(bounty))
+}
+
+// GetFeatured lists questions with an active bounty, soonest to expire first.
+func (h *BountyHandler) GetFeatured(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+	view, err := contentView(c)
+	if err != nil {
+		return err
+	}
+
+	bounties, next, err := h.BountyService.GetFeatured(page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch featured questions")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.BountyService.CountFeatured()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count featured questions")
+		}
+		total = &count
+	}
+
+	questionResponses := make([]schemas.QuestionResponse, len(bounties))
+	for i := range bounties {
+		questionResponses[i] = toQuestionResponse(&bounties[i].Question, view)
+	}
+	if err := markBookmarked(h.BookmarkService, userID, questionResponses); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bookmarks")
+	}
+
+	bountyResponses := []schemas.BountyResponse{}
+	for i := range bounties {
+		resp := toBountyResponse(&bounties[i])
+		resp.Question = &questionResponses[i]
+		bountyResponses = append(bountyResponses, resp)
+	}
+	return pagination.Respond(c, bountyResponses, next, total)
+}
+
+func (h *BountyHandler) bountyError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrQuestionNotFound), errors.Is(err, services.ErrBountyNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrBountyAmount):
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Bounty amount must be between %d and %d", h.Config.BountyMinAmount, h.Config.BountyMaxAmount))
+	case errors.Is(err, services.ErrBountyDuration):
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Bounty duration must be between 1 and %d days", h.Config.BountyMaxDays))
+	case errors.Is(err, services.ErrBountyAnswerInvalid):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrNotBountyOwner), errors.Is(err, services.ErrInsufficientReputation):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrQuestionNotOpen), errors.Is(err, services.ErrBountyAlreadyActive),
+		errors.Is(err, services.ErrBountyNotActive):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update bounty")
+}
+
+func toBountyResponse(b *models.Bounty) schemas.BountyResponse {
+	return schemas.BountyResponse{
+		ID:              b.ID,
+		QuestionID:      b.QuestionID,
+		OwnerID:         b.OwnerID,
+		Amount:          b.Amount,
+		Reason:          b.Reason,
+		Status:          b.Status,
+		ExpiresAt:       b.ExpiresAt,
+		AwardedAnswerID: b.AwardedAnswerID,
+		AwardedAt:       b.AwardedAt,
+		CreatedAt:       b.CreatedAt,
+	}
+}
@@ -0,0 +1,188 @@
+// handlers/content_filter_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// ContentFilterHandler serves the quarantine queue, mounted behind
+// ModeratorAuthMiddleware, and the blocklist, mounted behind AdminAuthMiddleware.
+type ContentFilterHandler struct {
+	Filter    *services.ContentFilter
+	Validator *validator.Validate
+}
+
+func NewContentFilterHandler(filter *services.ContentFilter) *ContentFilterHandler {
+	return &ContentFilterHandler{Filter: filter, Validator: validator.New()}
+}
+
+// GetQuarantine lists quarantined posts, oldest first.
+// Query params: type (question|answer, default question).
+func (h *ContentFilterHandler) GetQuarantine(c echo.Context) error {
+	targetType := c.QueryParam("type")
+	if targetType == "" {
+		targetType = services.FollowTargetQuestion
+	}
+	if targetType != services.FollowTargetQuestion && targetType != services.FollowTargetAnswer {
+		return echo.NewHTTPError(http.StatusBadRequest, "type must be one of: question, answer")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	responses := []schemas.QuarantinedPostResponse{}
+	var next *pagination.Cursor
+	if targetType == services.FollowTargetQuestion {
+		var questions []models.Question
+		if questions, next, err = h.Filter.GetQuarantinedQuestions(page); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch quarantine")
+		}
+		for i := range questions {
+			question := toQuestionResponse(&questions[i], contentBoth)
+			responses = append(responses, schemas.QuarantinedPostResponse{
+				TargetType:    targetType,
+				TargetID:      questions[i].ID,
+				Reason:        questions[i].QuarantineReason,
+				QuarantinedAt: *questions[i].QuarantinedAt,
+				Question:      &question,
+			})
+		}
+	} else {
+		var answers []models.Answer
+		if answers, next, err = h.Filter.GetQuarantinedAnswers(page); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch quarantine")
+		}
+		for i := range answers {
+			answer := toAnswerResponse(&answers[i], contentBoth)
+			responses = append(responses, schemas.QuarantinedPostResponse{
+				TargetType:    targetType,
+				TargetID:      answers[i].ID,
+				Reason:        answers[i].QuarantineReason,
+				QuarantinedAt: *answers[i].QuarantinedAt,
+				Answer:        &answer,
+			})
+		}
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.Filter.CountQuarantined(targetType)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count quarantine")
+		}
+		total = &count
+	}
+	return pagination.Respond(c, responses, next, total)
+}
+
+// ReleasePost publishes a quarantined post.
+func (h *ContentFilterHandler) ReleasePost(c echo.Context) error {
+	targetType, targetID, err := quarantineParams(c)
+	if err != nil {
+		return err
+	}
+	if err := h.Filter.Release(auditActor(c), targetType, targetID); err != nil {
+		return contentFilterError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ConfirmSpam deletes a quarantined post as spam.
+func (h *ContentFilterHandler) ConfirmSpam(c echo.Context) error {
+	targetType, targetID, err := quarantineParams(c)
+	if err != nil {
+		return err
+	}
+	if err := h.Filter.ConfirmSpam(auditActor(c), targetType, targetID); err != nil {
+		return contentFilterError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *ContentFilterHandler) ListRules(c echo.Context) error {
+	rules, err := h.Filter.ListRules()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch content rules")
+	}
+	responses := make([]schemas.ContentRuleResponse, 0, len(rules))
+	for i := range rules {
+		responses = append(responses, toContentRuleResponse(&rules[i]))
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+func (h *ContentFilterHandler) CreateRule(c echo.Context) error {
+	var req schemas.ContentRuleCreate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	rule, err := h.Filter.CreateRule(auditActor(c), req.Kind, req.Pattern, req.Note)
+	if err != nil {
+		return contentFilterError(err)
+	}
+	return c.JSON(http.StatusCreated, toContentRuleResponse(rule))
+}
+
+func (h *ContentFilterHandler) DeleteRule(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rule ID")
+	}
+	if err := h.Filter.DeleteRule(auditActor(c), uint(id)); err != nil {
+		return contentFilterError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func quarantineParams(c echo.Context) (string, uint, error) {
+	targetType := c.Param("type")
+	if targetType != services.FollowTargetQuestion && targetType != services.FollowTargetAnswer {
+		return "", 0, echo.NewHTTPError(http.StatusBadRequest, "Target type must be one of: question, answer")
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return "", 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid target ID")
+	}
+	return targetType, uint(id), nil
+}
+
+func contentFilterError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrFlagTargetNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Post not found")
+	case errors.Is(err, services.ErrContentRuleNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidContentRule):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrNotQuarantined), errors.Is(err, services.ErrContentRuleExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+}
+
+func toContentRuleResponse(r *models.ContentRule) schemas.ContentRuleResponse {
+	return schemas.ContentRuleResponse{
+		ID:          r.ID,
+		Kind:        r.Kind,
+		Pattern:     r.Pattern,
+		Note:        r.Note,
+		CreatedByID: r.CreatedByID,
+		CreatedAt:   r.CreatedAt,
+	}
+}
@@ -0,0 +1,130 @@
+// handlers/feed_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type FeedHandler struct {
+	FeedService     *services.FeedService
+	BookmarkService *services.BookmarkService
+	Validator       *validator.Validate
+}
+
+func NewFeedHandler(db *gorm.DB, cfg *config.Config) *FeedHandler {
+	return &FeedHandler{
+		FeedService:     services.NewFeedService(db, cfg),
+		BookmarkService: services.NewBookmarkService(db),
+		Validator:       validator.New(),
+	}
+}
+
+func (h *FeedHandler) GetTagPreferences(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	prefs, err := h.FeedService.GetTagPreferences(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch tag preferences")
+	}
+
+	prefResponses := []schemas.TagPreferenceResponse{}
+	for i := range prefs {
+		prefResponses = append(prefResponses, toTagPreferenceResponse(&prefs[i]))
+	}
+	return c.JSON(http.StatusOK, prefResponses)
+}
+
+// SetTagPreference watches or ignores the tag in the URL.
+func (h *FeedHandler) SetTagPreference(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req schemas.TagPreferenceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	pref, err := h.FeedService.SetTagPreference(userID, c.Param("name"), req.Kind)
+	if err != nil {
+		if errors.Is(err, services.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save tag preference")
+	}
+	return c.JSON(http.StatusOK, toTagPreferenceResponse(pref))
+}
+
+func (h *FeedHandler) RemoveTagPreference(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	if err := h.FeedService.RemoveTagPreference(userID, c.Param("name")); err != nil {
+		if errors.Is(err, services.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove tag preference")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetFeed lists questions for the current user: watched tags rank higher and
+// questions with ignored tags are hidden.
+func (h *FeedHandler) GetFeed(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+	view, err := contentView(c)
+	if err != nil {
+		return err
+	}
+
+	questions, next, err := h.FeedService.GetFeed(userID, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch feed")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.FeedService.CountFeed(userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count feed")
+		}
+		total = &count
+	}
+
+	questionResponses := []schemas.QuestionResponse{}
+	for i := range questions {
+		questionResponses = append(questionResponses, toQuestionResponse(&questions[i], view))
+	}
+	if err := markBookmarked(h.BookmarkService, userID, questionResponses); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bookmarks")
+	}
+	return pagination.Respond(c, questionResponses, next, total)
+}
+
+func toTagPreferenceResponse(p *models.TagPreference) schemas.TagPreferenceResponse {
+	return schemas.TagPreferenceResponse{
+		TagID:     p.TagID,
+		Name:      p.Tag.Name,
+		Kind:      p.Kind,
+		CreatedAt: p.CreatedAt,
+	}
+}
@@ -0,0 +1,280 @@
+// handlers/flag_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type FlagHandler struct {
+	FlagService *services.FlagService
+	UserService *services.UserService
+	Validator   *validator.Validate
+}
+
+func NewFlagHandler(db *gorm.DB, cfg *config.Config) *FlagHandler {
+	return &FlagHandler{
+		FlagService: services.NewFlagService(db, cfg),
+		UserService: services.NewUserService(db),
+		Validator:   validator.New(),
+	}
+}
+
+func (h *FlagHandler) FlagQuestion(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+	}
+	return h.createFlag(c, services.FollowTargetQuestion, uint(id))
+}
+
+func (h *FlagHandler) FlagAnswer(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid answer ID")
+	}
+	return h.createFlag(c, services.FollowTargetAnswer, uint(id))
+}
+
+func (h *FlagHandler) FlagUser(c echo.Context) error {
+	user, err := h.UserService.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	return h.createFlag(c, services.FlagTargetUser, user.ID)
+}
+
+func (h *FlagHandler) createFlag(c echo.Context, targetType string, targetID uint) error {
+	userID := c.Get("userID").(uint)
+
+	if c.Get("userRole").(string) == "guest" {
+		return echo.NewHTTPError(http.StatusForbidden, "Guest users cannot flag content.")
+	}
+
+	var req schemas.FlagCreate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	flag, err := h.FlagService.CreateFlag(userID, targetType, targetID, req.Reason, req.Note)
+	if err != nil {
+		return flagError(err)
+	}
+	return c.JSON(http.StatusCreated, toFlagResponse(flag))
+}
+
+// GetMyFlags lists the flags the current user raised and their outcomes.
+func (h *FlagHandler) GetMyFlags(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	flags, next, err := h.FlagService.GetUserFlags(userID, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch flags")
+	}
+	var total *int64
+	if page.WithTotal {
+		count, err := h.FlagService.CountUserFlags(userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count flags")
+		}
+		total = &count
+	}
+
+	responses := make([]schemas.FlagResponse, 0, len(flags))
+	for i := range flags {
+		responses = append(responses, toFlagResponse(&flags[i]))
+	}
+	return pagination.Respond(c, responses, next, total)
+}
+
+// GetQueue, GetFlaggedTarget and ReviewFlags are mounted behind ModeratorAuthMiddleware.
+
+// GetQueue lists targets with pending flags, most flagged first.
+// Query params: type (question|answer|user) to filter by target type.
+func (h *FlagHandler) GetQueue(c echo.Context) error {
+	targetType := c.QueryParam("type")
+	if targetType != "" && !isFlagTargetType(targetType) {
+		return echo.NewHTTPError(http.StatusBadRequest, "type must be one of: question, answer, user")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	summaries, next, err := h.FlagService.GetQueue(targetType, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch review queue")
+	}
+	var total *int64
+	if page.WithTotal {
+		count, err := h.FlagService.CountQueue(targetType)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count review queue")
+		}
+		total = &count
+	}
+
+	responses := make([]schemas.FlagSummaryResponse, 0, len(summaries))
+	for _, s := range summaries {
+		responses = append(responses, schemas.FlagSummaryResponse{
+			TargetType: s.TargetType,
+			TargetID:   s.TargetID,
+			FlagCount:  s.FlagCount,
+			Reasons: map[string]int64{
+				services.FlagReasonSpam:           s.Spam,
+				services.FlagReasonRude:           s.Rude,
+				services.FlagReasonOffTopic:       s.OffTopic,
+				services.FlagReasonNeedsModerator: s.NeedsModerator,
+			},
+			Hidden:         s.Hidden,
+			FirstFlaggedAt: s.FirstFlaggedAt,
+			LastFlaggedAt:  s.LastFlaggedAt,
+		})
+	}
+	return pagination.Respond(c, responses, next, total)
+}
+
+// GetFlaggedTarget returns a flagged post or user together with its pending flags.
+func (h *FlagHandler) GetFlaggedTarget(c echo.Context) error {
+	targetType, targetID, err := flagTargetParams(c)
+	if err != nil {
+		return err
+	}
+
+	target, err := h.FlagService.GetTarget(targetType, targetID)
+	if err != nil {
+		return flagError(err)
+	}
+	flags, err := h.FlagService.GetPendingFlags(targetType, targetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch flags")
+	}
+
+	resp := schemas.FlaggedTargetResponse{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Flags:      make([]schemas.FlagResponse, 0, len(flags)),
+	}
+	switch {
+	case target.Question != nil:
+		question := toQuestionResponse(target.Question, contentBoth)
+		resp.Question = &question
+	case target.Answer != nil:
+		answer := toAnswerResponse(target.Answer, contentBoth)
+		resp.Answer = &answer
+	case target.User != nil:
+		user := toUserResponse(target.User)
+		if !canSeeEmail(c, target.User) {
+			user.Email = ""
+		}
+		resp.User = &user
+	}
+	for i := range flags {
+		flag := toFlagResponse(&flags[i])
+		flag.Flagger = &schemas.FlaggerResponse{
+			ID:            flags[i].Flagger.ID,
+			Username:      flags[i].Flagger.Username,
+			HelpfulFlags:  flags[i].Flagger.HelpfulFlags,
+			DeclinedFlags: flags[i].Flagger.DeclinedFlags,
+		}
+		resp.Flags = append(resp.Flags, flag)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ReviewFlags resolves every pending flag on the target with one action.
+func (h *FlagHandler) ReviewFlags(c echo.Context) error {
+	targetType, targetID, err := flagTargetParams(c)
+	if err != nil {
+		return err
+	}
+	var req schemas.FlagReviewRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	review, err := h.FlagService.Review(auditActor(c), targetType, targetID, req.Action, req.Note)
+	if err != nil {
+		return flagError(err)
+	}
+	return c.JSON(http.StatusOK, schemas.FlagReviewResponse{
+		ID:          review.ID,
+		TargetType:  review.TargetType,
+		TargetID:    review.TargetID,
+		Action:      review.Action,
+		Note:        review.Note,
+		FlagCount:   review.FlagCount,
+		ModeratorID: review.ModeratorID,
+		CreatedAt:   review.CreatedAt,
+	})
+}
+
+func flagTargetParams(c echo.Context) (string, uint, error) {
+	targetType := c.Param("type")
+	if !isFlagTargetType(targetType) {
+		return "", 0, echo.NewHTTPError(http.StatusBadRequest, "Target type must be one of: question, answer, user")
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return "", 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid target ID")
+	}
+	return targetType, uint(id), nil
+}
+
+func isFlagTargetType(targetType string) bool {
+	return targetType == services.FollowTargetQuestion || targetType == services.FollowTargetAnswer ||
+		targetType == services.FlagTargetUser
+}
+
+func flagError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrFlagTargetNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, services.ErrFlagTargetNotFound.Error())
+	case errors.Is(err, services.ErrFlagOwnContent), errors.Is(err, services.ErrInvalidReviewAction):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrAlreadyFlagged), errors.Is(err, services.ErrNoPendingFlags):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process flag")
+}
+
+func toFlagResponse(f *models.Flag) schemas.FlagResponse {
+	return schemas.FlagResponse{
+		ID:         f.ID,
+		TargetType: f.TargetType,
+		TargetID:   f.TargetID,
+		Reason:     f.Reason,
+		Note:       f.Note,
+		Status:     f.Status,
+		CreatedAt:  f.CreatedAt,
+	}
+}
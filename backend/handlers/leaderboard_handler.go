@@ -0,0 +1,92 @@
+// handlers/leaderboard_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"stackit/config"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type LeaderboardHandler struct {
+	LeaderboardService *services.LeaderboardService
+	TagService         *services.TagService
+}
+
+func NewLeaderboardHandler(db *gorm.DB, cfg *config.Config) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		LeaderboardService: services.NewLeaderboardService(db, cfg),
+		TagService:         services.NewTagService(db, cfg),
+	}
+}
+
+// GetLeaderboard ranks users. Query params: period (week, month, quarter or all;
+// default week), metric (reputation, accepted_answers or answer_score; default
+// reputation) and an optional tag.
+func (h *LeaderboardHandler) GetLeaderboard(c echo.Context) error {
+	period := c.QueryParam("period")
+	if period == "" {
+		period = services.LeaderboardWeek
+	}
+	if !services.ValidLeaderboardPeriod(period) {
+		return echo.NewHTTPError(http.StatusBadRequest, "period must be one of: week, month, quarter, all")
+	}
+	metric := c.QueryParam("metric")
+	if metric == "" {
+		metric = "reputation"
+	}
+	if !services.ValidLeaderboardMetric(metric) {
+		return echo.NewHTTPError(http.StatusBadRequest, "metric must be one of: reputation, accepted_answers, answer_score")
+	}
+
+	var tagID uint
+	if name := c.QueryParam("tag"); name != "" {
+		tag, err := h.TagService.GetTagByName(name)
+		if err != nil {
+			return tagError(err)
+		}
+		tagID = tag.ID
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	entries, offset, next, err := h.LeaderboardService.GetLeaderboard(period, metric, tagID, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch leaderboard")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.LeaderboardService.CountLeaderboard(period, metric, tagID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count leaderboard")
+		}
+		total = &count
+	}
+
+	entryResponses := []schemas.LeaderboardEntryResponse{}
+	for i, e := range entries {
+		entryResponses = append(entryResponses, schemas.LeaderboardEntryResponse{
+			Rank:            offset + i + 1,
+			UserID:          e.UserID,
+			Username:        e.User.Username,
+			Reputation:      e.Reputation,
+			AcceptedAnswers: e.AcceptedAnswers,
+			AnswerScore:     e.AnswerScore,
+			RefreshedAt:     e.RefreshedAt,
+		})
+	}
+	return pagination.Respond(c, entryResponses, next, total)
+}
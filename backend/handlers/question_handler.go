@@ -2,11 +2,20 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"stackit/config"
+	"stackit/middlewares"
+	"stackit/pagination"
 	"stackit/schemas"
 	"stackit/services"
+	"stackit/utils"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -14,16 +23,34 @@ import (
 )
 
 type QuestionHandler struct {
-	QuestionService *services.QuestionService
-	UserService     *services.UserService
-	Validator       *validator.Validate
+	QuestionService  *services.QuestionService
+	RankingService   *services.RankingService
+	FollowService    *services.FollowService
+	BookmarkService  *services.BookmarkService
+	PrivilegeService *services.PrivilegeService
+	BadgeService     *services.BadgeService
+	BountyService    *services.BountyService
+	Notifier         *services.NotificationDispatcher
+	UserService      *services.UserService
+	Views            *services.ViewTracker
+	Config           *config.Config
+	Validator        *validator.Validate
 }
 
-func NewQuestionHandler(db *gorm.DB) *QuestionHandler {
+func NewQuestionHandler(db *gorm.DB, cfg *config.Config, views *services.ViewTracker, notifier *services.NotificationDispatcher, filter *services.ContentFilter) *QuestionHandler {
 	return &QuestionHandler{
-		QuestionService: services.NewQuestionService(db),
-		UserService:     services.NewUserService(db), // Need to access user for role checks
-		Validator:       validator.New(),
+		QuestionService:  &services.QuestionService{DB: db, Filter: filter},
+		RankingService:   services.NewRankingService(db, cfg),
+		FollowService:    services.NewFollowService(db),
+		BookmarkService:  services.NewBookmarkService(db),
+		PrivilegeService: services.NewPrivilegeService(db, cfg),
+		BadgeService:     services.NewBadgeService(db, cfg),
+		BountyService:    services.NewBountyService(db, cfg),
+		Notifier:         notifier,
+		UserService:      services.NewUserService(db), // Need to access user for role checks
+		Views:            views,
+		Config:           cfg,
+		Validator:        validator.New(),
 	}
 }
 
@@ -43,63 +70,102 @@ func (h *QuestionHandler) CreateQuestion(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if h.Config.DuplicateWarnThreshold > 0 && !questionCreate.ConfirmNotDuplicate {
+		candidates, err := h.QuestionService.FindSimilarQuestions(questionCreate.Title, questionCreate.Tags, 5)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check for duplicates")
+		}
+		if len(candidates) > 0 && candidates[0].Score >= h.Config.DuplicateWarnThreshold {
+			return c.JSON(http.StatusConflict, schemas.DuplicateWarningResponse{
+				Message:    "Similar questions already exist. Resubmit with confirm_not_duplicate set to post anyway.",
+				Candidates: toSimilarQuestionResponses(candidates),
+			})
+		}
+	}
+
 	question, err := h.QuestionService.CreateQuestion(&questionCreate, userID)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidAttachments) || errors.Is(err, utils.ErrInvalidTagName) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	// Map models.Tag to schemas.TagResponse
-	tagResponses := []schemas.TagResponse{}
-	for _, qt := range question.Tags {
-		tagResponses = append(tagResponses, schemas.TagResponse{
-			ID:   qt.Tag.ID,
-			Name: qt.Tag.Name,
+	// Quarantined questions stay unannounced
+	if question.QuarantinedAt == nil {
+		h.Notifier.Publish(services.ActivityEvent{
+			Kind:       services.NotificationKindWatchedTag,
+			QuestionID: question.ID,
+			ActorID:    userID,
 		})
 	}
+	h.BadgeService.EvaluateAsync(services.BadgeTriggerQuestionPosted, userID)
 
-	return c.JSON(http.StatusCreated, schemas.QuestionResponse{
-		ID:          question.ID,
-		Title:       question.Title,
-		Description: question.Description,
-		OwnerID:     question.OwnerID,
-		Tags:        tagResponses,
-		CreatedAt:   question.CreatedAt,
-		UpdatedAt:   &question.UpdatedAt,
-	})
+	return c.JSON(http.StatusCreated, toQuestionResponse(question, contentBoth))
 }
 
 func (h *QuestionHandler) GetQuestions(c echo.Context) error {
-	offset, _ := strconv.Atoi(c.QueryParam("offset"))
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit == 0 {
-		limit = 100
+	filter, err := questionFilter(c)
+	if err != nil {
+		return err
+	}
+	return h.listQuestions(c, filter)
+}
+
+// GetUserQuestions lists the questions asked by :username, with the same sort and
+// filter params as GET /questions.
+func (h *QuestionHandler) GetUserQuestions(c echo.Context) error {
+	user, err := h.UserService.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	filter, err := questionFilter(c)
+	if err != nil {
+		return err
 	}
+	filter.Author = user.Username
+	return h.listQuestions(c, filter)
+}
 
-	questions, err := h.QuestionService.GetQuestions(offset, limit)
+func (h *QuestionHandler) listQuestions(c echo.Context, filter services.QuestionFilter) error {
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+	view, err := contentView(c)
 	if err != nil {
+		return err
+	}
+
+	questions, next, err := h.QuestionService.GetQuestions(filter, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch questions")
 	}
 
-	questionResponses := []schemas.QuestionResponse{}
-	for _, q := range questions {
-		tagResponses := []schemas.TagResponse{}
-		for _, qt := range q.Tags {
-			tagResponses = append(tagResponses, schemas.TagResponse{
-				ID:   qt.Tag.ID,
-				Name: qt.Tag.Name,
-			})
+	var total *int64
+	if page.WithTotal {
+		count, err := h.QuestionService.CountQuestions(filter)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count questions")
 		}
-		questionResponses = append(questionResponses, schemas.QuestionResponse{
-			ID:          q.ID,
-			Title:       q.Title,
-			Description: q.Description,
-			OwnerID:     q.OwnerID,
-			Tags:        tagResponses,
-			CreatedAt:   q.CreatedAt,
-			UpdatedAt:   &q.UpdatedAt,
-		})
+		total = &count
 	}
-	return c.JSON(http.StatusOK, questionResponses)
+
+	questionResponses := []schemas.QuestionResponse{}
+	for i := range questions {
+		questionResponses = append(questionResponses, toQuestionResponse(&questions[i], view))
+	}
+	if err := markBookmarked(h.BookmarkService, c.Get("userID").(uint), questionResponses); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bookmarks")
+	}
+	return pagination.Respond(c, questionResponses, next, total)
 }
 
 func (h *QuestionHandler) GetQuestionByID(c echo.Context) error {
@@ -108,6 +174,11 @@ func (h *QuestionHandler) GetQuestionByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
 	}
 
+	view, err := contentView(c)
+	if err != nil {
+		return err
+	}
+
 	question, err := h.QuestionService.GetQuestionByID(uint(id))
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -116,21 +187,292 @@ func (h *QuestionHandler) GetQuestionByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch question")
 	}
 
-	tagResponses := []schemas.TagResponse{}
-	for _, qt := range question.Tags {
-		tagResponses = append(tagResponses, schemas.TagResponse{
-			ID:   qt.Tag.ID,
-			Name: qt.Tag.Name,
+	userID, _ := c.Get("userID").(uint)
+	if question.HiddenAt != nil && !canSeeHidden(c, question.OwnerID) {
+		return echo.NewHTTPError(http.StatusNotFound, "Question not found")
+	}
+
+	// Duplicates redirect to their canonical question unless the client asks for the duplicate itself
+	if question.DuplicateOfID != nil && c.QueryParam("no_redirect") != "true" {
+		target := fmt.Sprintf("/api/v1/questions/%d", *question.DuplicateOfID)
+		if c.QueryString() != "" {
+			target += "?" + c.QueryString()
+		}
+		return c.Redirect(http.StatusFound, target)
+	}
+
+	h.Views.RecordView(question.ID, services.ViewerKey(userID, c.RealIP(), c.Request().UserAgent()))
+
+	resp := []schemas.QuestionResponse{toQuestionResponse(question, view)}
+	resp[0].ViewCount += h.Views.Pending(question.ID) // Include views still waiting to be flushed
+	if err := markBookmarked(h.BookmarkService, userID, resp); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bookmarks")
+	}
+	bounty, err := h.BountyService.GetActiveBounty(question.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bounty")
+	}
+	if bounty != nil {
+		bountyResp := toBountyResponse(bounty)
+		resp[0].Bounty = &bountyResp
+	}
+	return c.JSON(http.StatusOK, resp[0])
+}
+
+// Windows accepted by the trending endpoint
+var trendingWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// GetTrendingQuestions lists the questions gaining the most traction in the last 24h or 7d.
+// Query params: window (24h|7d, default 24h), tag, limit (max 100).
+func (h *QuestionHandler) GetTrendingQuestions(c echo.Context) error {
+	window := c.QueryParam("window")
+	if window == "" {
+		window = "24h"
+	}
+	duration, ok := trendingWindows[window]
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "window must be '24h' or '7d'")
+	}
+
+	limit := 20
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be a positive integer")
+		}
+		limit = min(n, 100)
+	}
+
+	questions, err := h.RankingService.GetTrending(duration, c.QueryParam("tag"), limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch trending questions")
+	}
+
+	questionResponses := []schemas.QuestionResponse{}
+	for i := range questions {
+		questionResponses = append(questionResponses, toQuestionResponse(&questions[i], contentSource))
+	}
+	if err := markBookmarked(h.BookmarkService, c.Get("userID").(uint), questionResponses); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bookmarks")
+	}
+	return c.JSON(http.StatusOK, questionResponses)
+}
+
+func (h *QuestionHandler) GetSimilarQuestions(c echo.Context) error {
+	var req schemas.SimilarQuestionsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	candidates, err := h.QuestionService.FindSimilarQuestions(req.Title, req.Tags, 10)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch similar questions")
+	}
+	return c.JSON(http.StatusOK, toSimilarQuestionResponses(candidates))
+}
+
+func toSimilarQuestionResponses(candidates []services.SimilarQuestion) []schemas.SimilarQuestionResponse {
+	responses := []schemas.SimilarQuestionResponse{}
+	for _, sq := range candidates {
+		responses = append(responses, schemas.SimilarQuestionResponse{
+			ID:              sq.ID,
+			Title:           sq.Title,
+			TitleSimilarity: sq.TitleSimilarity,
+			SharedTags:      sq.SharedTags,
+			Score:           sq.Score,
 		})
 	}
+	return responses
+}
+
+func (h *QuestionHandler) CloseQuestion(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+	}
 
-	return c.JSON(http.StatusOK, schemas.QuestionResponse{
-		ID:          question.ID,
-		Title:       question.Title,
-		Description: question.Description,
-		OwnerID:     question.OwnerID,
-		Tags:        tagResponses,
-		CreatedAt:   question.CreatedAt,
-		UpdatedAt:   &question.UpdatedAt,
-	})
+	userID := c.Get("userID").(uint)
+	userRole := c.Get("userRole").(string)
+
+	if userRole == "guest" {
+		return echo.NewHTTPError(http.StatusForbidden, "Guest users cannot vote to close questions.")
+	}
+	if err := requirePrivilege(c, h.PrivilegeService, services.PrivilegeVoteClose); err != nil {
+		return err
+	}
+
+	var req schemas.CloseQuestionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	question, err := h.QuestionService.VoteToClose(auditActor(c), uint(id), middlewares.IsModerator(userRole), req.Reason, req.DuplicateOfID, h.Config.CloseVotesRequired)
+	if err != nil {
+		return closeVoteError(err)
+	}
+	// Closing only succeeds on open questions, so a closed result means this vote closed it
+	if question.Status == services.QuestionStatusClosed {
+		if err := h.BountyService.RefundQuestionBounties(question.ID); err != nil {
+			// The expiry worker refunds it on its next pass
+			log.Printf("Failed to refund bounty on closed question %d: %v", question.ID, err)
+		}
+		h.Notifier.Publish(services.ActivityEvent{
+			Kind:       services.NotificationKindQuestionClosed,
+			QuestionID: question.ID,
+			ActorID:    userID,
+		})
+	}
+	return c.JSON(http.StatusOK, toQuestionResponse(question, contentSource))
+}
+
+func (h *QuestionHandler) ReopenQuestion(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+	}
+
+	userID := c.Get("userID").(uint)
+	userRole := c.Get("userRole").(string)
+
+	if userRole == "guest" {
+		return echo.NewHTTPError(http.StatusForbidden, "Guest users cannot vote to reopen questions.")
+	}
+	if err := requirePrivilege(c, h.PrivilegeService, services.PrivilegeVoteClose); err != nil {
+		return err
+	}
+
+	question, err := h.QuestionService.VoteToReopen(auditActor(c), uint(id), middlewares.IsModerator(userRole), h.Config.CloseVotesRequired)
+	if err != nil {
+		return closeVoteError(err)
+	}
+	if question.Status == services.QuestionStatusOpen {
+		h.Notifier.Publish(services.ActivityEvent{
+			Kind:       services.NotificationKindQuestionReopened,
+			QuestionID: question.ID,
+			ActorID:    userID,
+		})
+	}
+	return c.JSON(http.StatusOK, toQuestionResponse(question, contentSource))
+}
+
+func (h *QuestionHandler) FollowQuestion(c echo.Context) error {
+	return setFollowing(c, h.FollowService, services.FollowTargetQuestion, true)
+}
+
+func (h *QuestionHandler) UnfollowQuestion(c echo.Context) error {
+	return setFollowing(c, h.FollowService, services.FollowTargetQuestion, false)
+}
+
+// LockQuestion and UnlockQuestion are mounted behind ModeratorAuthMiddleware.
+func (h *QuestionHandler) LockQuestion(c echo.Context) error {
+	return h.setLocked(c, true)
+}
+
+func (h *QuestionHandler) UnlockQuestion(c echo.Context) error {
+	return h.setLocked(c, false)
+}
+
+func (h *QuestionHandler) setLocked(c echo.Context, locked bool) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+	}
+
+	question, err := h.QuestionService.SetLocked(auditActor(c), uint(id), locked)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Question not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update question lock")
+	}
+	return c.JSON(http.StatusOK, toQuestionResponse(question, contentSource))
+}
+
+func closeVoteError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Question not found")
+	case errors.Is(err, services.ErrQuestionNotOpen), errors.Is(err, services.ErrQuestionNotClosed),
+		errors.Is(err, services.ErrAlreadyVotedToClose):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrInvalidDuplicateOf), errors.Is(err, services.ErrDuplicateOfIsRequired):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record vote: "+err.Error())
+}
+
+// questionFilter reads the list query parameters:
+// sort, tags (comma-separated), tag_mode (any|all), author, accepted (true|false),
+// from and to (YYYY-MM-DD, to inclusive, or RFC 3339).
+func questionFilter(c echo.Context) (services.QuestionFilter, error) {
+	filter := services.QuestionFilter{
+		Sort:   c.QueryParam("sort"),
+		Author: c.QueryParam("author"),
+	}
+	if filter.Sort == "" {
+		filter.Sort = services.SortNewest
+	}
+	if !services.IsValidQuestionSort(filter.Sort) {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "sort must be one of: newest, active, votes, unanswered, hot, frequent")
+	}
+
+	if tags := c.QueryParam("tags"); tags != "" {
+		for _, t := range strings.Split(tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Tags = append(filter.Tags, t)
+			}
+		}
+	}
+	switch c.QueryParam("tag_mode") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "tag_mode must be 'any' or 'all'")
+	}
+
+	if accepted := c.QueryParam("accepted"); accepted != "" {
+		val, err := strconv.ParseBool(accepted)
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "accepted must be true or false")
+		}
+		filter.Accepted = &val
+	}
+
+	var err error
+	if filter.From, err = parseDateParam(c.QueryParam("from"), false); err != nil {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid 'from' date")
+	}
+	if filter.To, err = parseDateParam(c.QueryParam("to"), true); err != nil {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid 'to' date")
+	}
+	return filter, nil
+}
+
+// parseDateParam accepts RFC 3339 timestamps or plain dates. A plain upper-bound date
+// covers the whole day, so it is moved to the start of the next day.
+func parseDateParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
 }
@@ -0,0 +1,179 @@
+// handlers/responses.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"stackit/middlewares"
+	"stackit/models"
+	"stackit/schemas"
+	"stackit/services"
+	"stackit/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Values accepted by the ?content= query parameter on question and answer endpoints.
+const (
+	contentSource   = "source"   // Stored source only (default)
+	contentRendered = "rendered" // Sanitized HTML only
+	contentBoth     = "both"
+)
+
+func contentView(c echo.Context) (string, error) {
+	view := c.QueryParam("content")
+	switch view {
+	case "":
+		return contentSource, nil
+	case contentSource, contentRendered, contentBoth:
+		return view, nil
+	}
+	return "", echo.NewHTTPError(http.StatusBadRequest, "content must be one of: source, rendered, both")
+}
+
+// canSeeHidden reports whether the caller may see ownerID's hidden posts: posts hidden
+// by spam flags or quarantine stay visible to their owner and to moderators.
+func canSeeHidden(c echo.Context, ownerID uint) bool {
+	userID, _ := c.Get("userID").(uint)
+	userRole, _ := c.Get("userRole").(string)
+	return userID == ownerID || middlewares.IsModerator(userRole)
+}
+
+// canSeeEmail reports whether the caller may see u's email address: users who chose to
+// show it, the user themselves and admins.
+func canSeeEmail(c echo.Context, u *models.User) bool {
+	userID, _ := c.Get("userID").(uint)
+	userRole, _ := c.Get("userRole").(string)
+	return u.ShowEmail || u.ID == userID || userRole == "admin"
+}
+
+// renderedOrFallback returns the cached render, rendering on the fly for rows created
+// before the cache existed.
+func renderedOrFallback(cached, source, format string) string {
+	if cached != "" {
+		return cached
+	}
+	rendered, err := utils.RenderContent(source, format)
+	if err != nil {
+		return ""
+	}
+	return rendered
+}
+
+func toQuestionResponse(q *models.Question, view string) schemas.QuestionResponse {
+	tagResponses := []schemas.TagResponse{}
+	for _, qt := range q.Tags {
+		tagResponses = append(tagResponses, schemas.TagResponse{
+			ID:   qt.Tag.ID,
+			Name: qt.Tag.Name,
+		})
+	}
+
+	resp := schemas.QuestionResponse{
+		ID:             q.ID,
+		Title:          q.Title,
+		ContentFormat:  q.ContentFormat,
+		OwnerID:        q.OwnerID,
+		Status:         q.Status,
+		CloseReason:    q.CloseReason,
+		DuplicateOfID:  q.DuplicateOfID,
+		ClosedAt:       q.ClosedAt,
+		Hidden:         q.HiddenAt != nil,
+		Quarantined:    q.QuarantinedAt != nil,
+		Score:          q.Score,
+		AnswerCount:    q.AnswerCount,
+		HasAccepted:    q.HasAccepted,
+		ViewCount:      q.ViewCount,
+		LastActivityAt: q.LastActivityAt,
+		Tags:           tagResponses,
+		Attachments:    toAttachmentResponses(q.Attachments),
+		CreatedAt:      q.CreatedAt,
+		UpdatedAt:      &q.UpdatedAt,
+	}
+	if view != contentRendered {
+		resp.Description = q.Description
+	}
+	if view != contentSource {
+		resp.DescriptionHTML = renderedOrFallback(q.DescriptionHTML, q.Description, q.ContentFormat)
+	}
+	return resp
+}
+
+func toAnswerResponse(a *models.Answer, view string) schemas.AnswerResponse {
+	resp := schemas.AnswerResponse{
+		ID:            a.ID,
+		ContentFormat: a.ContentFormat,
+		QuestionID:    a.QuestionID,
+		OwnerID:       a.OwnerID,
+		IsAccepted:    a.IsAccepted,
+		Hidden:        a.HiddenAt != nil,
+		Quarantined:   a.QuarantinedAt != nil,
+		Score:         a.Score,
+		Attachments:   toAttachmentResponses(a.Attachments),
+		CreatedAt:     a.CreatedAt,
+		UpdatedAt:     &a.UpdatedAt,
+	}
+	if view != contentRendered {
+		resp.Content = a.Content
+	}
+	if view != contentSource {
+		resp.ContentHTML = renderedOrFallback(a.ContentHTML, a.Content, a.ContentFormat)
+	}
+	return resp
+}
+
+func toAttachmentResponse(a *models.Attachment) schemas.AttachmentResponse {
+	return schemas.AttachmentResponse{
+		ID:          a.ID,
+		URL:         fmt.Sprintf("/api/v1/attachments/%d", a.ID),
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Width:       a.Width,
+		Height:      a.Height,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+func toAttachmentResponses(attachments []models.Attachment) []schemas.AttachmentResponse {
+	var responses []schemas.AttachmentResponse
+	for i := range attachments {
+		responses = append(responses, toAttachmentResponse(&attachments[i]))
+	}
+	return responses
+}
+
+func toUserResponse(u *models.User) schemas.UserResponse {
+	return schemas.UserResponse{
+		ID:               u.ID,
+		Username:         u.Username,
+		Email:            u.Email,
+		Role:             u.Role,
+		IsActive:         u.IsActive,
+		Reputation:       u.Reputation,
+		DisplayName:      u.DisplayName,
+		Bio:              u.Bio,
+		Location:         u.Location,
+		WebsiteURL:       u.WebsiteURL,
+		AvatarURL:        avatarURL(u),
+		ShowEmail:        u.ShowEmail,
+		HelpfulFlags:     u.HelpfulFlags,
+		DeclinedFlags:    u.DeclinedFlags,
+		SuspendedUntil:   u.SuspendedUntil,
+		SuspensionReason: u.SuspensionReason,
+		BannedAt:         u.BannedAt,
+		BanReason:        u.BanReason,
+		CreatedAt:        u.CreatedAt,
+	}
+}
+
+// avatarURL is the user's external avatar if set, otherwise the versioned URL of their
+// uploaded avatar or identicon.
+func avatarURL(u *models.User) string {
+	if u.AvatarURL != "" {
+		return u.AvatarURL
+	}
+	return fmt.Sprintf("/api/v1/avatars/%s?v=%s", url.PathEscape(u.Username), services.AvatarVersion(u))
+}
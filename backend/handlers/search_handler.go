@@ -0,0 +1,71 @@
+// handlers/search_handler.go
+package handlers
+
+import (
+	"net/http"
+
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type SearchHandler struct {
+	SearchService *services.SearchService
+}
+
+func NewSearchHandler(db *gorm.DB) *SearchHandler {
+	return &SearchHandler{
+		SearchService: services.NewSearchService(db),
+	}
+}
+
+func (h *SearchHandler) Search(c echo.Context) error {
+	raw := c.QueryParam("q")
+	if raw == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing search query 'q'")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	query, err := services.ParseSearchQuery(raw)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	results, next, err := h.SearchService.Search(query, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search questions")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.SearchService.Count(query)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count search results")
+		}
+		total = &count
+	}
+
+	resultResponses := []schemas.SearchResultResponse{}
+	for _, r := range results {
+		resultResponses = append(resultResponses, schemas.SearchResultResponse{
+			ID:             r.ID,
+			Title:          r.Title,
+			TitleHighlight: r.TitleHighlight,
+			Snippet:        r.Snippet,
+			OwnerID:        r.OwnerID,
+			Rank:           r.Rank,
+			Score:          r.Score,
+			AnswerCount:    r.AnswerCount,
+			HasAccepted:    r.HasAccepted,
+			CreatedAt:      r.CreatedAt,
+		})
+	}
+	return pagination.Respond(c, resultResponses, next, total)
+}
@@ -0,0 +1,278 @@
+// handlers/tag_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackit/config"
+	"stackit/pagination"
+	"stackit/schemas"
+	"stackit/services"
+	"stackit/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type TagHandler struct {
+	TagService       *services.TagService
+	PrivilegeService *services.PrivilegeService
+	Validator        *validator.Validate
+}
+
+func NewTagHandler(db *gorm.DB, cfg *config.Config) *TagHandler {
+	return &TagHandler{
+		TagService:       services.NewTagService(db, cfg),
+		PrivilegeService: services.NewPrivilegeService(db, cfg),
+		Validator:        validator.New(),
+	}
+}
+
+// ListTags supports ?sort=popular (default), name or newest.
+func (h *TagHandler) ListTags(c echo.Context) error {
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+	sort := c.QueryParam("sort")
+	switch sort {
+	case "":
+		sort = services.TagSortPopular
+	case services.TagSortPopular, services.TagSortName, services.TagSortNewest:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "sort must be one of: popular, name, newest")
+	}
+
+	tags, next, err := h.TagService.ListTags(sort, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch tags")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.TagService.CountTags()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count tags")
+		}
+		total = &count
+	}
+
+	tagResponses := []schemas.TagSummaryResponse{}
+	for i := range tags {
+		tagResponses = append(tagResponses, toTagSummaryResponse(&tags[i]))
+	}
+	return pagination.Respond(c, tagResponses, next, total)
+}
+
+func (h *TagHandler) GetTag(c echo.Context) error {
+	tag, err := h.TagService.GetTagByName(c.Param("name"))
+	if err != nil {
+		return tagError(err)
+	}
+	return c.JSON(http.StatusOK, toTagDetailResponse(tag))
+}
+
+func (h *TagHandler) UpdateTag(c echo.Context) error {
+	if c.Get("userRole").(string) == "guest" {
+		return echo.NewHTTPError(http.StatusForbidden, "Guest users cannot edit tags.")
+	}
+	// Tag wikis are shared content, so editing them needs the edit-others privilege
+	if err := requirePrivilege(c, h.PrivilegeService, services.PrivilegeEditOthers); err != nil {
+		return err
+	}
+
+	var req schemas.TagUpdate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tag, err := h.TagService.UpdateTag(c.Param("name"), req.Excerpt, req.Wiki)
+	if err != nil {
+		return tagError(err)
+	}
+	return c.JSON(http.StatusOK, toTagDetailResponse(tag))
+}
+
+// AddSynonym, RemoveSynonym and MergeTag are mounted behind ModeratorAuthMiddleware.
+func (h *TagHandler) AddSynonym(c echo.Context) error {
+	var req schemas.TagSynonymCreate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := h.TagService.AddSynonym(auditActor(c), c.Param("name"), req.Name); err != nil {
+		return tagError(err)
+	}
+	tag, err := h.TagService.GetTagByName(c.Param("name"))
+	if err != nil {
+		return tagError(err)
+	}
+	return c.JSON(http.StatusCreated, toTagDetailResponse(tag))
+}
+
+func (h *TagHandler) RemoveSynonym(c echo.Context) error {
+	if err := h.TagService.RemoveSynonym(auditActor(c), c.Param("name"), c.Param("synonym")); err != nil {
+		return tagError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *TagHandler) MergeTag(c echo.Context) error {
+	var req schemas.TagMergeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tag, err := h.TagService.MergeTags(auditActor(c), c.Param("name"), req.Target)
+	if err != nil {
+		return tagError(err)
+	}
+	return c.JSON(http.StatusOK, toTagDetailResponse(tag))
+}
+
+// AutocompleteTags suggests tags for ?q= (partial name), at most ?limit= (default 10, max 50).
+func (h *TagHandler) AutocompleteTags(c echo.Context) error {
+	q := c.QueryParam("q")
+	if strings.TrimSpace(q) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing query 'q'")
+	}
+	limit, err := tagListLimit(c, 10, 50)
+	if err != nil {
+		return err
+	}
+
+	tags, err := h.TagService.Autocomplete(q, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch tag suggestions")
+	}
+	return c.JSON(http.StatusOK, toTagSummaryResponses(tags))
+}
+
+// PopularTags counts usage over ?window= ("all" or a number of days such as 7d, up to 365d;
+// default 30d) and returns the top ?limit= tags (default 20, max 100).
+func (h *TagHandler) PopularTags(c echo.Context) error {
+	window, err := parseDaysWindow(c.QueryParam("window"), "30d")
+	if err != nil {
+		return err
+	}
+	limit, err := tagListLimit(c, 20, 100)
+	if err != nil {
+		return err
+	}
+
+	tags, err := h.TagService.PopularTags(window, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch popular tags")
+	}
+	return c.JSON(http.StatusOK, toTagSummaryResponses(tags))
+}
+
+// RelatedTags returns tags that co-occur with :name; question_count is the number of
+// shared questions. ?limit= defaults to 10, max 50.
+func (h *TagHandler) RelatedTags(c echo.Context) error {
+	limit, err := tagListLimit(c, 10, 50)
+	if err != nil {
+		return err
+	}
+
+	tags, err := h.TagService.RelatedTags(c.Param("name"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch related tags")
+	}
+	return c.JSON(http.StatusOK, toTagSummaryResponses(tags))
+}
+
+func tagListLimit(c echo.Context, def, max int) (int, error) {
+	raw := c.QueryParam("limit")
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "limit must be a positive integer")
+	}
+	return min(n, max), nil
+}
+
+// parseDaysWindow accepts "all" (returned as 0) or "<n>d" with 1 <= n <= 365.
+func parseDaysWindow(value, def string) (time.Duration, error) {
+	if value == "" {
+		value = def
+	}
+	if value == "all" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+	if err != nil || !strings.HasSuffix(value, "d") || days < 1 || days > 365 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "window must be 'all' or a number of days such as '7d' (max 365d)")
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+func tagError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrTagNotFound), errors.Is(err, services.ErrSynonymNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrTagNameTaken):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, utils.ErrInvalidTagName), errors.Is(err, services.ErrMergeIntoItself),
+		errors.Is(err, services.ErrSynonymIsTagName):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update tag")
+}
+
+func toTagSummaryResponse(t *services.TagWithCount) schemas.TagSummaryResponse {
+	return schemas.TagSummaryResponse{
+		ID:            t.ID,
+		Name:          t.Name,
+		Excerpt:       t.Excerpt,
+		QuestionCount: t.QuestionCount,
+	}
+}
+
+func toTagSummaryResponses(tags []services.TagWithCount) []schemas.TagSummaryResponse {
+	responses := []schemas.TagSummaryResponse{}
+	for i := range tags {
+		responses = append(responses, toTagSummaryResponse(&tags[i]))
+	}
+	return responses
+}
+
+func toTagDetailResponse(t *services.TagWithCount) schemas.TagDetailResponse {
+	synonyms := []string{}
+	for _, s := range t.Synonyms {
+		synonyms = append(synonyms, s.Name)
+	}
+	return schemas.TagDetailResponse{
+		ID:            t.ID,
+		Name:          t.Name,
+		Excerpt:       t.Excerpt,
+		Wiki:          t.Wiki,
+		WikiHTML:      renderedOrFallback("", t.Wiki, utils.ContentFormatMarkdown),
+		QuestionCount: t.QuestionCount,
+		Synonyms:      synonyms,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
@@ -1,23 +1,37 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"stackit/config"
+	"stackit/middlewares"
+	"stackit/pagination"
 	"stackit/schemas"
 	"stackit/services"
+	"stackit/utils"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	UserService *services.UserService
+	UserService       *services.UserService
+	ReputationService *services.ReputationService
+	PrivilegeService  *services.PrivilegeService
+	BadgeService      *services.BadgeService
+	Validator         *validator.Validate
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
+func NewUserHandler(db *gorm.DB, cfg *config.Config) *UserHandler {
 	return &UserHandler{
-		UserService: services.NewUserService(db),
+		UserService:       services.NewUserService(db),
+		ReputationService: services.NewReputationService(db, cfg),
+		PrivilegeService:  services.NewPrivilegeService(db, cfg),
+		BadgeService:      services.NewBadgeService(db, cfg),
+		Validator:         validator.New(),
 	}
 }
 
@@ -32,17 +46,34 @@ func (h *UserHandler) GetCurrentUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "User not found")
 	}
 
-	userResp := schemas.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		Role:      user.Role,
-		IsActive:  user.IsActive,
-		CreatedAt: user.CreatedAt,
-	}
+	userResp := toUserResponse(user)
 	return c.JSON(http.StatusOK, userResp)
 }
 
+// UpdateProfile changes the caller's public profile and email visibility.
+func (h *UserHandler) UpdateProfile(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req schemas.ProfileUpdate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.Validator.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.UserService.UpdateProfile(userID, &req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update profile")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// GetUserByUsername returns a public profile. The email is only included when the user
+// chose to show it, or for the user themselves and admins.
 func (h *UserHandler) GetUserByUsername(c echo.Context) error {
 	username := c.Param("username")
 
@@ -54,36 +85,225 @@ func (h *UserHandler) GetUserByUsername(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "User not found")
 	}
 
-	userResp := schemas.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		Role:      user.Role,
-		IsActive:  user.IsActive,
+	questionCount, answerCount, err := h.UserService.CountPosts(user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	tiers, err := h.BadgeService.CountUserBadgesByTier(user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch badges")
+	}
+
+	profile := schemas.ProfileResponse{
+		ID:            user.ID,
+		Username:      user.Username,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		BioHTML:       renderedOrFallback("", user.Bio, utils.ContentFormatMarkdown),
+		Location:      user.Location,
+		WebsiteURL:    user.WebsiteURL,
+		AvatarURL:     avatarURL(user),
+		Role:          user.Role,
+		Reputation:    user.Reputation,
+		QuestionCount: questionCount,
+		AnswerCount:   answerCount,
+		Badges: schemas.BadgeCountsResponse{
+			Gold:   tiers[services.BadgeGold],
+			Silver: tiers[services.BadgeSilver],
+			Bronze: tiers[services.BadgeBronze],
+		},
 		CreatedAt: user.CreatedAt,
 	}
-	return c.JSON(http.StatusOK, userResp)
+	if canSeeEmail(c, user) {
+		profile.Email = user.Email
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// GetActivity returns a user's timeline of questions, answers, accepted answers and
+// badges, newest first. Hidden posts are left out unless canSeeHidden.
+func (h *UserHandler) GetActivity(c echo.Context) error {
+	user, err := h.UserService.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	includeHidden := canSeeHidden(c, user.ID)
+	items, next, err := h.UserService.GetActivity(user.ID, includeHidden, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch activity")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.UserService.CountActivity(user.ID, includeHidden)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count activity")
+		}
+		total = &count
+	}
+
+	activityResponses := []schemas.ActivityResponse{}
+	for _, item := range items {
+		activityResponses = append(activityResponses, schemas.ActivityResponse{
+			Kind:       item.Kind,
+			ID:         item.ID,
+			QuestionID: item.QuestionID,
+			Title:      item.Title,
+			Badge:      item.Badge,
+			OccurredAt: item.OccurredAt,
+		})
+	}
+	return pagination.Respond(c, activityResponses, next, total)
+}
+
+// GetReputationHistory lists a user's reputation ledger, newest first.
+func (h *UserHandler) GetReputationHistory(c echo.Context) error {
+	user, err := h.UserService.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	events, next, err := h.ReputationService.GetHistory(user.ID, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch reputation history")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := h.ReputationService.CountHistory(user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count reputation history")
+		}
+		total = &count
+	}
+
+	eventResponses := []schemas.ReputationEventResponse{}
+	for _, e := range events {
+		eventResponses = append(eventResponses, schemas.ReputationEventResponse{
+			ID:         e.ID,
+			Kind:       e.Kind,
+			Delta:      e.Delta,
+			QuestionID: e.QuestionID,
+			AnswerID:   e.AnswerID,
+			ReversesID: e.ReversesID,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	return pagination.Respond(c, eventResponses, next, total)
+}
+
+// GetPrivileges lists the privileges the caller has earned and those still ahead.
+func (h *UserHandler) GetPrivileges(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	reputation, err := h.PrivilegeService.Reputation(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user data")
+	}
+	isModerator := middlewares.IsModerator(c.Get("userRole").(string))
+
+	resp := schemas.PrivilegesResponse{
+		Reputation: reputation,
+		Earned:     []schemas.PrivilegeResponse{},
+		Upcoming:   []schemas.PrivilegeResponse{},
+	}
+	for _, p := range h.PrivilegeService.Privileges() {
+		privilege := schemas.PrivilegeResponse{
+			Name:               p.Name,
+			Description:        p.Description,
+			RequiredReputation: p.Reputation,
+		}
+		if isModerator || reputation >= p.Reputation {
+			resp.Earned = append(resp.Earned, privilege)
+		} else {
+			privilege.Remaining = p.Reputation - reputation
+			resp.Upcoming = append(resp.Upcoming, privilege)
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// requirePrivilege returns a 403 with a missing_privilege body when the caller lacks
+// the named privilege, or nil when the action may go ahead.
+func requirePrivilege(c echo.Context, privileges *services.PrivilegeService, name string) error {
+	userID := c.Get("userID").(uint)
+	isModerator := middlewares.IsModerator(c.Get("userRole").(string))
+
+	err := privileges.Require(userID, isModerator, name)
+	if err == nil {
+		return nil
+	}
+	var privErr *services.PrivilegeError
+	if errors.As(err, &privErr) {
+		return echo.NewHTTPError(http.StatusForbidden, schemas.PrivilegeErrorResponse{
+			Code:               "missing_privilege",
+			Message:            privErr.Error(),
+			Privilege:          privErr.Privilege.Name,
+			RequiredReputation: privErr.Privilege.Reputation,
+			Reputation:         privErr.Reputation,
+		})
+	}
+	if errors.Is(err, services.ErrUserNotFound) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check privileges")
 }
 
 func (h *UserHandler) GetUnreadNotifications(c echo.Context) error {
 	userID := c.Get("userID").(uint)
 
-	notifications, err := h.UserService.GetUnreadNotifications(userID)
+	page, err := pagination.FromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	notifications, next, err := h.UserService.GetUnreadNotifications(userID, page)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch notifications")
 	}
 
+	var total *int64
+	if page.WithTotal {
+		count, err := h.UserService.CountUnreadNotifications(userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count notifications")
+		}
+		total = &count
+	}
+
 	notificationResponses := []schemas.NotificationResponse{}
 	for _, n := range notifications {
 		notificationResponses = append(notificationResponses, schemas.NotificationResponse{
-			ID:        n.ID,
-			UserID:    n.UserID,
-			Message:   n.Message,
-			IsRead:    n.IsRead,
-			CreatedAt: n.CreatedAt,
+			ID:         n.ID,
+			UserID:     n.UserID,
+			Message:    n.Message,
+			Kind:       n.Kind,
+			QuestionID: n.QuestionID,
+			IsRead:     n.IsRead,
+			CreatedAt:  n.CreatedAt,
 		})
 	}
-	return c.JSON(http.StatusOK, notificationResponses)
+	return pagination.Respond(c, notificationResponses, next, total)
 }
 
 func (h *UserHandler) MarkNotificationAsRead(c echo.Context) error {
@@ -103,38 +323,21 @@ func (h *UserHandler) MarkNotificationAsRead(c echo.Context) error {
 	}
 
 	return c.JSON(http.StatusOK, schemas.NotificationResponse{
-		ID:        notification.ID,
-		UserID:    notification.UserID,
-		Message:   notification.Message,
-		IsRead:    notification.IsRead,
-		CreatedAt: notification.CreatedAt,
+		ID:         notification.ID,
+		UserID:     notification.UserID,
+		Message:    notification.Message,
+		Kind:       notification.Kind,
+		QuestionID: notification.QuestionID,
+		IsRead:     notification.IsRead,
+		CreatedAt:  notification.CreatedAt,
 	})
 }
 
-// Admin-only handler (placeholder for now)
-func (h *UserHandler) GetAllUsersAdmin(c echo.Context) error {
-	// Example: Pagination parameters
-	offset, _ := strconv.Atoi(c.QueryParam("offset"))
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit == 0 {
-		limit = 100
-	}
-
-	users, err := h.UserService.GetAllUsers(offset, limit)
+// RecomputeReputation rebuilds every cached reputation from the ledger (admin only).
+func (h *UserHandler) RecomputeReputation(c echo.Context) error {
+	updated, err := h.ReputationService.RecomputeAll()
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch users")
-	}
-
-	userResponses := []schemas.UserResponse{}
-	for _, u := range users {
-		userResponses = append(userResponses, schemas.UserResponse{
-			ID:        u.ID,
-			Username:  u.Username,
-			Email:     u.Email,
-			Role:      u.Role,
-			IsActive:  u.IsActive,
-			CreatedAt: u.CreatedAt,
-		})
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to recompute reputation")
 	}
-	return c.JSON(http.StatusOK, userResponses)
+	return c.JSON(http.StatusOK, map[string]int64{"updated_users": updated})
 }
@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
 
 	// Renamed from just 'middleware' to avoid conflict
 	"stackit/config"
 	"stackit/database"
 	"stackit/handlers"
 	"stackit/middlewares"
+	"stackit/services"
+	"stackit/storage"
 )
 
 func main() {
@@ -29,6 +40,43 @@ func main() {
 	// Auto-migrate models (create tables if they don't exist)
 	database.MigrateModels(db)
 
+	// Initialize attachment storage (local filesystem or S3-compatible)
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Error initializing storage: %v", err)
+	}
+
+	// Background jobs run until SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go services.NewAttachmentService(db, store, cfg).RunOrphanCollector(ctx, time.Hour)
+	go services.NewRankingService(db, cfg).RunHotScoreWorker(ctx)
+	viewTracker := services.NewViewTracker(db, cfg)
+	// The flusher outlives ctx so views recorded by requests still in flight at
+	// shutdown make it into the final flush
+	flushCtx, stopFlusher := context.WithCancel(context.Background())
+	var flusher sync.WaitGroup
+	flusher.Add(1)
+	go func() {
+		defer flusher.Done()
+		viewTracker.RunFlusher(flushCtx)
+	}()
+	// Likewise the dispatcher, so events published by those requests are delivered
+	notifier := services.NewNotificationDispatcher(db, cfg, 1000)
+	notifyCtx, stopNotifier := context.WithCancel(context.Background())
+	var dispatcher sync.WaitGroup
+	dispatcher.Add(1)
+	go func() {
+		defer dispatcher.Done()
+		notifier.Run(notifyCtx)
+	}()
+	go services.NewBadgeService(db, cfg).RunSweeper(ctx)
+	go services.NewBountyService(db, cfg).RunExpiryWorker(ctx)
+	go services.NewLeaderboardService(db, cfg).RunRefresher(ctx)
+	sessions := services.NewSessionService(db)
+	contentFilter := services.NewContentFilter(db, cfg)
+	go services.NewAdminService(db, cfg, sessions).RunSuspensionWorker(ctx)
+
 	e := echo.New()
 
 	// Middleware
@@ -38,41 +86,183 @@ func main() {
 
 	// Handlers initialization (pass the database instance)
 	authHandler := handlers.NewAuthHandler(db, cfg)
-	questionHandler := handlers.NewQuestionHandler(db)
-	answerHandler := handlers.NewAnswerHandler(db)
-	userHandler := handlers.NewUserHandler(db)
+	questionHandler := handlers.NewQuestionHandler(db, cfg, viewTracker, notifier, contentFilter)
+	answerHandler := handlers.NewAnswerHandler(db, cfg, notifier, contentFilter)
+	userHandler := handlers.NewUserHandler(db, cfg)
+	attachmentHandler := handlers.NewAttachmentHandler(db, store, cfg)
+	avatarHandler := handlers.NewAvatarHandler(db, store, cfg)
+	searchHandler := handlers.NewSearchHandler(db)
+	tagHandler := handlers.NewTagHandler(db, cfg)
+	feedHandler := handlers.NewFeedHandler(db, cfg)
+	bookmarkHandler := handlers.NewBookmarkHandler(db)
+	badgeHandler := handlers.NewBadgeHandler(db, cfg)
+	bountyHandler := handlers.NewBountyHandler(db, cfg)
+	leaderboardHandler := handlers.NewLeaderboardHandler(db, cfg)
+	flagHandler := handlers.NewFlagHandler(db, cfg)
+	adminHandler := handlers.NewAdminHandler(db, cfg, sessions)
+	auditHandler := handlers.NewAuditHandler(db)
+	contentFilterHandler := handlers.NewContentFilterHandler(contentFilter)
 
 	// Routes
 	v1 := e.Group("/api/v1")
+	visits := services.NewVisitTracker(db)
 
 	// Auth routes
 	v1.POST("/auth/register", authHandler.Register)
-	v1.POST("/auth/token", authHandler.Login)
+	// Every login attempt is audited, so attempts are rate limited per client first
+	loginLimit := middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(float64(cfg.LoginAttemptsPerMinute) / 60),
+			Burst:     cfg.LoginAttemptsPerMinute,
+			ExpiresIn: 3 * time.Minute,
+		}),
+	})
+	v1.POST("/auth/token", authHandler.Login, loginLimit)
+
+	// Attachments and avatars are embedded in pages, so they are served without authentication.
+	// Attachments are only served while their post is visible, see GetAttachment.
+	v1.GET("/attachments/:id", attachmentHandler.GetAttachment)
+	v1.GET("/avatars/:username", avatarHandler.GetAvatar)
+
+	// Question pages are open to anonymous visitors, whose views are counted too; a token
+	// is still checked when one is sent
+	public := v1.Group("")
+	public.Use(middlewares.OptionalJWTAuthMiddleware(cfg))
+	public.Use(middlewares.SessionMiddleware(sessions))
+	public.Use(middlewares.TrackVisitsMiddleware(visits))
+	public.GET("/questions/:id", questionHandler.GetQuestionByID)
 
 	// Protected routes (requires authentication)
 	protected := v1.Group("")
 	protected.Use(middlewares.JWTAuthMiddleware(cfg)) // Apply JWT authentication middleware
+	protected.Use(middlewares.SessionMiddleware(sessions))
+	protected.Use(middlewares.TrackVisitsMiddleware(visits))
 
 	protected.POST("/questions", questionHandler.CreateQuestion)
 	protected.GET("/questions", questionHandler.GetQuestions)
-	protected.GET("/questions/:id", questionHandler.GetQuestionByID)
+	protected.GET("/questions/trending", questionHandler.GetTrendingQuestions)
+	protected.GET("/questions/featured", bountyHandler.GetFeatured)
+	protected.POST("/questions/similar", questionHandler.GetSimilarQuestions)
+	protected.POST("/questions/:id/close", questionHandler.CloseQuestion)
+	protected.POST("/questions/:id/reopen", questionHandler.ReopenQuestion)
+	protected.POST("/questions/:id/follow", questionHandler.FollowQuestion)
+	protected.DELETE("/questions/:id/follow", questionHandler.UnfollowQuestion)
+	protected.PUT("/questions/:id/bookmark", bookmarkHandler.SaveBookmark)
+	protected.DELETE("/questions/:id/bookmark", bookmarkHandler.DeleteBookmark)
+	protected.POST("/questions/:id/flag", flagHandler.FlagQuestion)
+	protected.POST("/questions/:id/bounty", bountyHandler.StartBounty)
+	protected.POST("/bounties/:id/award", bountyHandler.AwardBounty)
+	protected.PUT("/questions/:id/lock", questionHandler.LockQuestion, middlewares.ModeratorAuthMiddleware())
+	protected.DELETE("/questions/:id/lock", questionHandler.UnlockQuestion, middlewares.ModeratorAuthMiddleware())
+
+	protected.GET("/search", searchHandler.Search)
+
+	protected.GET("/tags", tagHandler.ListTags)
+	protected.GET("/tags/autocomplete", tagHandler.AutocompleteTags)
+	protected.GET("/tags/popular", tagHandler.PopularTags)
+	protected.GET("/tags/:name", tagHandler.GetTag)
+	protected.GET("/tags/:name/related", tagHandler.RelatedTags)
+	protected.PATCH("/tags/:name", tagHandler.UpdateTag)
+	protected.POST("/tags/:name/synonyms", tagHandler.AddSynonym, middlewares.ModeratorAuthMiddleware())
+	protected.DELETE("/tags/:name/synonyms/:synonym", tagHandler.RemoveSynonym, middlewares.ModeratorAuthMiddleware())
+	protected.POST("/tags/:name/merge", tagHandler.MergeTag, middlewares.ModeratorAuthMiddleware())
+
+	// Allow some headroom over the file size for multipart framing
+	uploadLimit := middleware.BodyLimit(fmt.Sprintf("%dK", cfg.MaxUploadBytes/1024+64))
+	protected.POST("/attachments", attachmentHandler.UploadAttachment, uploadLimit)
+	protected.DELETE("/attachments/:id", attachmentHandler.DeleteAttachment)
+	protected.POST("/users/me/avatar", avatarHandler.UploadAvatar, uploadLimit)
+	protected.DELETE("/users/me/avatar", avatarHandler.DeleteAvatar)
 
 	protected.POST("/answers", answerHandler.CreateAnswer)
 	protected.GET("/answers/question/:questionID", answerHandler.GetAnswersByQuestionID)
 	protected.PATCH("/answers/:id/accept", answerHandler.AcceptAnswer)
 	protected.POST("/answers/:id/vote", answerHandler.VoteAnswer)
+	protected.POST("/answers/:id/follow", answerHandler.FollowAnswer)
+	protected.DELETE("/answers/:id/follow", answerHandler.UnfollowAnswer)
+	protected.POST("/answers/:id/flag", flagHandler.FlagAnswer)
+
+	protected.GET("/leaderboards", leaderboardHandler.GetLeaderboard)
+
+	protected.GET("/badges", badgeHandler.ListBadges)
+	protected.GET("/badges/:slug/holders", badgeHandler.GetBadgeHolders)
 
 	protected.GET("/users/me", userHandler.GetCurrentUser)
+	protected.PATCH("/users/me/profile", userHandler.UpdateProfile)
 	protected.GET("/users/:username", userHandler.GetUserByUsername)
+	protected.GET("/users/:username/reputation", userHandler.GetReputationHistory)
+	protected.GET("/users/:username/badges", badgeHandler.GetUserBadges)
+	protected.GET("/users/:username/activity", userHandler.GetActivity)
+	protected.GET("/users/:username/questions", questionHandler.GetUserQuestions)
+	protected.GET("/users/:username/answers", answerHandler.GetUserAnswers)
 	protected.GET("/users/me/notifications", userHandler.GetUnreadNotifications)
+	protected.GET("/users/me/privileges", userHandler.GetPrivileges)
+	protected.GET("/users/me/flags", flagHandler.GetMyFlags)
+	protected.POST("/users/:username/flag", flagHandler.FlagUser)
+	protected.GET("/users/me/feed", feedHandler.GetFeed)
+	protected.GET("/users/me/tags", feedHandler.GetTagPreferences)
+	protected.PUT("/users/me/tags/:name", feedHandler.SetTagPreference)
+	protected.DELETE("/users/me/tags/:name", feedHandler.RemoveTagPreference)
+	protected.GET("/users/me/bookmarks", bookmarkHandler.GetBookmarks)
+	protected.GET("/users/me/bookmark-lists", bookmarkHandler.GetLists)
+	protected.POST("/users/me/bookmark-lists", bookmarkHandler.CreateList)
+	protected.PATCH("/users/me/bookmark-lists/:id", bookmarkHandler.RenameList)
+	protected.DELETE("/users/me/bookmark-lists/:id", bookmarkHandler.DeleteList)
 	protected.PATCH("/users/notifications/:id/read", userHandler.MarkNotificationAsRead)
 
+	// Moderator review queue for flagged posts and users
+	protected.GET("/moderation/flags", flagHandler.GetQueue, middlewares.ModeratorAuthMiddleware())
+	protected.GET("/moderation/flags/:type/:id", flagHandler.GetFlaggedTarget, middlewares.ModeratorAuthMiddleware())
+	protected.POST("/moderation/flags/:type/:id/review", flagHandler.ReviewFlags, middlewares.ModeratorAuthMiddleware())
+	protected.GET("/moderation/quarantine", contentFilterHandler.GetQuarantine, middlewares.ModeratorAuthMiddleware())
+	protected.POST("/moderation/quarantine/:type/:id/release", contentFilterHandler.ReleasePost, middlewares.ModeratorAuthMiddleware())
+	protected.POST("/moderation/quarantine/:type/:id/spam", contentFilterHandler.ConfirmSpam, middlewares.ModeratorAuthMiddleware())
+
 	// Admin-only routes (example)
 	adminProtected := v1.Group("/admin")
-	adminProtected.Use(middlewares.JWTAuthMiddleware(cfg), middlewares.AdminAuthMiddleware())
-	adminProtected.GET("/users", userHandler.GetAllUsersAdmin) // Placeholder for admin user management
+	adminProtected.Use(
+		middlewares.JWTAuthMiddleware(cfg),
+		// After JWT, so only calls with a valid token are recorded, but before the session
+		// and role checks, so calls rejected by those are recorded too
+		middlewares.AuditRequestsMiddleware(services.NewAuditService(db)),
+		middlewares.SessionMiddleware(sessions),
+		middlewares.AdminAuthMiddleware(),
+	)
+	adminProtected.GET("/users", adminHandler.ListUsers)
+	adminProtected.PUT("/users/:id/role", adminHandler.ChangeRole)
+	adminProtected.POST("/users/:id/suspension", adminHandler.SuspendUser)
+	adminProtected.DELETE("/users/:id/suspension", adminHandler.UnsuspendUser)
+	adminProtected.POST("/users/:id/ban", adminHandler.BanUser)
+	adminProtected.POST("/users/:id/password-reset", adminHandler.ResetPassword)
+	adminProtected.POST("/users/:id/logout", adminHandler.ForceLogout)
+	adminProtected.POST("/users/:id/merge", adminHandler.MergeUser)
+	adminProtected.GET("/content-rules", contentFilterHandler.ListRules)
+	adminProtected.POST("/content-rules", contentFilterHandler.CreateRule)
+	adminProtected.DELETE("/content-rules/:id", contentFilterHandler.DeleteRule)
+	adminProtected.GET("/audit", auditHandler.ListAuditLogs)
+	adminProtected.GET("/audit/export", auditHandler.ExportAuditLogs)
+	adminProtected.GET("/audit/verify", auditHandler.VerifyAuditLog)
+	adminProtected.POST("/reputation/recompute", userHandler.RecomputeReputation)
 
 	// Start server
 	log.Printf("Server starting on :%s", cfg.Port)
-	e.Logger.Fatal(e.Start(":" + cfg.Port))
+	go func() {
+		if err := e.Start(":" + cfg.Port); err != nil && err != http.ErrServerClosed {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// On shutdown, let in-flight requests finish, then wait for the notification queue to
+	// drain and for the last view count flush
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown failed: %v", err)
+	}
+	stopNotifier()
+	dispatcher.Wait()
+	stopFlusher()
+	flusher.Wait()
 }
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 
 	"stackit/config"
@@ -17,28 +18,52 @@ func JWTAuthMiddleware(cfg *config.Config) echo.MiddlewareFunc {
 			if authHeader == "" {
 				return echo.NewHTTPError(http.StatusUnauthorized, "Missing Authorization header")
 			}
-
-			tokenString := authHeader
-			if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-				tokenString = authHeader[7:]
-			} else {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid Authorization header format")
+			if err := authenticate(c, cfg, authHeader); err != nil {
+				return err
 			}
+			return next(c)
+		}
+	}
+}
 
-			claims, err := utils.ParseJWT(tokenString, cfg.SecretKey)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+// OptionalJWTAuthMiddleware is JWTAuthMiddleware for routes that anonymous visitors may
+// use too: a request without an Authorization header continues unauthenticated, while
+// an invalid token is still rejected.
+func OptionalJWTAuthMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if authHeader := c.Request().Header.Get("Authorization"); authHeader != "" {
+				if err := authenticate(c, cfg, authHeader); err != nil {
+					return err
+				}
 			}
-
-			// Store user ID and role in context for later use in handlers
-			c.Set("userID", claims.UserID)
-			c.Set("username", claims.Username)
-			c.Set("userRole", claims.Role)
 			return next(c)
 		}
 	}
 }
 
+// authenticate parses a bearer token and stores its claims in the context.
+func authenticate(c echo.Context, cfg *config.Config, authHeader string) error {
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	} else {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid Authorization header format")
+	}
+
+	claims, err := utils.ParseJWT(tokenString, cfg.SecretKey)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+	}
+
+	// Store user ID and role in context for later use in handlers
+	c.Set("userID", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("userRole", claims.Role)
+	c.Set("sessionVersion", claims.SessionVersion)
+	return nil
+}
+
 // AdminAuthMiddleware checks if the authenticated user has an 'admin' role.
 func AdminAuthMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -51,3 +76,98 @@ func AdminAuthMiddleware() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// IsModerator reports whether a role carries moderation powers. Admins are moderators too.
+func IsModerator(role string) bool {
+	return role == "moderator" || role == "admin"
+}
+
+// ModeratorAuthMiddleware checks if the authenticated user has a 'moderator' or 'admin' role.
+func ModeratorAuthMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userRole, ok := c.Get("userRole").(string)
+			if !ok || !IsModerator(userRole) {
+				return echo.NewHTTPError(http.StatusForbidden, "Moderator access required")
+			}
+			return next(c)
+		}
+	}
+}
+
+// SessionChecker validates a token's session against the user's current state and
+// returns the user's current role.
+type SessionChecker interface {
+	CheckSession(userID uint, sessionVersion int) (string, error)
+}
+
+// SessionMiddleware rejects tokens revoked by a forced logout or belonging to a banned
+// or suspended account, and replaces the role from the token with the current one. It
+// must run after JWTAuthMiddleware or OptionalJWTAuthMiddleware; anonymous requests
+// let through by the latter pass unchecked.
+func SessionMiddleware(sessions SessionChecker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := c.Get("userID").(uint)
+			if !ok {
+				return next(c)
+			}
+			sessionVersion, _ := c.Get("sessionVersion").(int)
+			role, err := sessions.CheckSession(userID, sessionVersion)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			c.Set("userRole", role)
+			return next(c)
+		}
+	}
+}
+
+// VisitRecorder is told about every authenticated request.
+type VisitRecorder interface {
+	RecordVisit(userID uint)
+}
+
+// TrackVisitsMiddleware records the authenticated user's visit. It must run after
+// JWTAuthMiddleware.
+func TrackVisitsMiddleware(visits VisitRecorder) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if userID, ok := c.Get("userID").(uint); ok {
+				visits.RecordVisit(userID)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequestAuditor records calls to privileged APIs.
+type RequestAuditor interface {
+	RecordRequest(actorID *uint, ip, userAgent, method, path, query string, status int)
+}
+
+// AuditRequestsMiddleware records every request with the status it was answered with,
+// including rejected ones. It must run after JWTAuthMiddleware.
+func AuditRequestsMiddleware(auditor RequestAuditor) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				}
+			}
+			var actorID *uint
+			if userID, ok := c.Get("userID").(uint); ok {
+				actorID = &userID
+			}
+			req := c.Request()
+			auditor.RecordRequest(actorID, c.RealIP(), req.UserAgent(), req.Method, req.URL.Path, req.URL.RawQuery, status)
+			return err
+		}
+	}
+}
@@ -1,47 +1,114 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Username       string         `gorm:"uniqueIndex;not null"`
-	Email          string         `gorm:"uniqueIndex;not null"`
-	HashedPassword string         `gorm:"not null"`
-	Role           string         `gorm:"default:'user'"` // "guest", "user", "admin"
-	IsActive       bool           `gorm:"default:true"`
-	Questions      []Question     `gorm:"foreignKey:OwnerID"`
-	Answers        []Answer       `gorm:"foreignKey:OwnerID"`
-	Votes          []Vote         `gorm:"foreignKey:UserID"`
-	Notifications  []Notification `gorm:"foreignKey:UserID"`
+	Username       string `gorm:"uniqueIndex;not null"`
+	Email          string `gorm:"uniqueIndex;not null"`
+	HashedPassword string `gorm:"not null"`
+	Role           string `gorm:"default:'user'"` // "guest", "user", "moderator", "admin"
+	IsActive       bool   `gorm:"default:true"`
+	Reputation     int    `gorm:"default:1;not null"` // Cached 1 + sum of the ledger, see ReputationEvent
+	// Public profile
+	DisplayName string
+	Bio         string `gorm:"type:text"` // Markdown
+	Location    string
+	WebsiteURL  string
+	AvatarURL   string // External image; takes precedence over the uploaded avatar
+	AvatarKey   string // Storage prefix of the uploaded avatar; empty serves the identicon
+	ShowEmail   bool   `gorm:"default:false;not null"` // Email is hidden from other users unless set
+	// Account restrictions; a ban is permanent, a suspension lifts at SuspendedUntil
+	SuspendedUntil   *time.Time
+	SuspensionReason string
+	BannedAt         *time.Time
+	BanReason        string
+	SessionVersion   int `gorm:"default:0;not null"` // Bumped to revoke every token issued before
+	// Outcomes of the user's reviewed flags, for flag accuracy
+	HelpfulFlags  int            `gorm:"default:0;not null"`
+	DeclinedFlags int            `gorm:"default:0;not null"`
+	Questions     []Question     `gorm:"foreignKey:OwnerID"`
+	Answers       []Answer       `gorm:"foreignKey:OwnerID"`
+	Votes         []Vote         `gorm:"foreignKey:UserID"`
+	Notifications []Notification `gorm:"foreignKey:UserID"`
 }
 
 type Question struct {
 	gorm.Model
 	Title       string `gorm:"index;not null"`
 	Description string `gorm:"type:text;not null"` // Rich text content
-	OwnerID     uint
-	Owner       User
-	Answers     []Answer      `gorm:"foreignKey:QuestionID"`
-	Tags        []QuestionTag `gorm:"foreignKey:QuestionID"`
+	// "html" or "markdown"; Description keeps the source, DescriptionHTML the sanitized render
+	ContentFormat   string `gorm:"default:'html';not null"`
+	DescriptionHTML string `gorm:"type:text"`
+	OwnerID         uint
+	Owner           User
+	Status          string `gorm:"default:'open';not null;index"` // "open", "closed", "locked"
+	CloseReason     string // "duplicate", "off-topic", "unclear", "too-broad", "opinion-based"
+	ClosedAt        *time.Time
+	DuplicateOfID   *uint      `gorm:"index"` // Canonical question when closed as duplicate
+	HiddenAt        *time.Time // Set while hidden by spam flags or quarantine, pending moderator review
+	// While locked, the status and close time the lock replaced, restored on unlock
+	LockedFromStatus   string
+	LockedFromClosedAt *time.Time
+	// Denormalized for list sorting; maintained by the answer and vote services
+	Score          int  `gorm:"default:0;not null"` // Sum of votes on the question's answers
+	AnswerCount    int  `gorm:"default:0;not null"`
+	HasAccepted    bool `gorm:"default:false;not null"`
+	LastActivityAt time.Time
+	ViewCount      int           `gorm:"default:0;not null"`
+	HotScore       float64       `gorm:"default:0;not null"` // Recomputed periodically by the ranking worker
+	Answers        []Answer      `gorm:"foreignKey:QuestionID"`
+	Tags           []QuestionTag `gorm:"foreignKey:QuestionID"`
+	Attachments    []Attachment  `gorm:"foreignKey:QuestionID"`
+	// Content filter state; HiddenAt is set along with QuarantinedAt
+	QuarantinedAt    *time.Time
+	QuarantineReason string // Which content checks held the post
+	ContentHash      string `gorm:"size:64;index"` // Of the normalized description, for cross-user duplicate detection
 }
 
 type Answer struct {
 	gorm.Model
-	Content    string `gorm:"type:text;not null"` // Rich text content
-	QuestionID uint
-	Question   Question
-	OwnerID    uint
-	Owner      User
-	IsAccepted bool   `gorm:"default:false"`
-	Votes      []Vote `gorm:"foreignKey:AnswerID"`
+	Content string `gorm:"type:text;not null"` // Rich text content
+	// "html" or "markdown"; Content keeps the source, ContentHTML the sanitized render
+	ContentFormat string `gorm:"default:'html';not null"`
+	ContentHTML   string `gorm:"type:text"`
+	QuestionID    uint
+	Question      Question
+	OwnerID       uint
+	Owner         User
+	IsAccepted    bool         `gorm:"default:false"`
+	AcceptedAt    *time.Time   // Set while IsAccepted
+	HiddenAt      *time.Time   // Set while hidden by spam flags or quarantine, pending moderator review
+	Score         int          `gorm:"default:0;not null"` // Sum of votes, maintained by CreateOrUpdateVote
+	Votes         []Vote       `gorm:"foreignKey:AnswerID"`
+	Attachments   []Attachment `gorm:"foreignKey:AnswerID"`
+	// Content filter state; HiddenAt is set along with QuarantinedAt
+	QuarantinedAt    *time.Time
+	QuarantineReason string // Which content checks held the post
+	ContentHash      string `gorm:"size:64;index"` // Of the normalized content, for cross-user duplicate detection
 }
 
 type Tag struct {
 	gorm.Model
-	Name      string        `gorm:"uniqueIndex;not null"`
+	Name      string        `gorm:"uniqueIndex;not null"` // Normalized, see utils.NormalizeTagName
+	Excerpt   string        `gorm:"type:text"`            // Short plain-text usage guidance
+	Wiki      string        `gorm:"type:text"`            // Full description, rendered like post content
 	Questions []QuestionTag `gorm:"foreignKey:TagID"`
+	Synonyms  []TagSynonym  `gorm:"foreignKey:TagID"`
+}
+
+// TagSynonym maps an alternative name onto its master tag. Questions created with the
+// synonym are tagged with the master instead.
+type TagSynonym struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex;not null"`
+	TagID     uint   `gorm:"index;not null"`
+	Tag       Tag
+	CreatedAt time.Time
 }
 
 type QuestionTag struct {
@@ -59,10 +126,218 @@ type Vote struct {
 	Answer   Answer
 }
 
+// CloseVote is a pending vote to close or reopen a question. Votes of a kind are
+// cleared once they take effect.
+type CloseVote struct {
+	QuestionID    uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"primaryKey"`
+	Kind          string `gorm:"primaryKey"` // "close" or "reopen"
+	Reason        string // Close votes only
+	DuplicateOfID *uint  // Close votes with reason "duplicate" only
+	CreatedAt     time.Time
+	User          User
+}
+
 type Notification struct {
 	gorm.Model
-	UserID  uint
-	User    User
-	Message string `gorm:"not null"`
-	IsRead  bool   `gorm:"default:false"`
+	UserID     uint
+	User       User
+	Message    string `gorm:"not null"`
+	IsRead     bool   `gorm:"default:false"`
+	Kind       string `gorm:"index"` // e.g. "watched_tag"; empty for generic messages
+	QuestionID *uint  // Question the notification is about, if any
+}
+
+// Follow subscribes a user to activity on a question or an answer.
+type Follow struct {
+	UserID     uint   `gorm:"primaryKey"`
+	TargetType string `gorm:"primaryKey;index:idx_follows_target,priority:1"` // "question" or "answer"
+	TargetID   uint   `gorm:"primaryKey;index:idx_follows_target,priority:2"`
+	CreatedAt  time.Time
+}
+
+// ReputationEvent is an append-only entry in the reputation ledger. Undoing an action
+// appends a reversal (same kind, negated delta, ReversesID set) instead of deleting.
+type ReputationEvent struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"index:idx_reputation_events_user_created,priority:1;not null"`
+	Kind       string    `gorm:"not null"`
+	Delta      int       `gorm:"not null"` // Amount applied, after the daily cap
+	QuestionID *uint     `gorm:"index"`
+	AnswerID   *uint     `gorm:"index"`
+	ActorID    *uint     // User whose action caused the event, e.g. the voter
+	ReversesID *uint     `gorm:"index"`
+	CreatedAt  time.Time `gorm:"index:idx_reputation_events_user_created,priority:2"`
+}
+
+// Bounty is reputation offered on a question. The offer is charged when the bounty
+// starts; it is then awarded to an answer, refunded, or lost on expiry.
+type Bounty struct {
+	ID              uint      `gorm:"primaryKey"`
+	QuestionID      uint      `gorm:"index;not null"`
+	OwnerID         uint      `gorm:"index;not null"` // User who offered the bounty
+	Amount          int       `gorm:"not null"`
+	Reason          string    `gorm:"not null"`
+	Status          string    `gorm:"default:'active';not null;index"` // "active", "awarded", "expired", "refunded"
+	ExpiresAt       time.Time `gorm:"index;not null"`
+	AwardedAnswerID *uint
+	AwardedAt       *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Question        Question
+}
+
+// LeaderboardEntry is a user's pre-aggregated totals for one period, overall (TagID 0)
+// or within a tag. The table is rebuilt by the leaderboard job.
+type LeaderboardEntry struct {
+	Period          string `gorm:"primaryKey"` // "week", "month", "quarter" or "all"
+	TagID           uint   `gorm:"primaryKey"`
+	UserID          uint   `gorm:"primaryKey"`
+	Reputation      int    `gorm:"not null"` // Reputation gained in the period
+	AcceptedAnswers int    `gorm:"not null"`
+	AnswerScore     int    `gorm:"not null"` // Net votes received on answers
+	RefreshedAt     time.Time
+	User            User
+}
+
+// BadgeAward records a badge earned by a user. Badges tied to a post carry its type
+// and ID; other badges use an empty PostType and a zero PostID.
+type BadgeAward struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"uniqueIndex:idx_badge_awards_unique,priority:1;not null"`
+	Badge     string `gorm:"uniqueIndex:idx_badge_awards_unique,priority:2;index;not null"` // Badge slug
+	PostType  string `gorm:"uniqueIndex:idx_badge_awards_unique,priority:3;not null;default:''"`
+	PostID    uint   `gorm:"uniqueIndex:idx_badge_awards_unique,priority:4;not null;default:0"`
+	CreatedAt time.Time
+	User      User
+}
+
+// UserVisit records a day on which a user made an authenticated request.
+type UserVisit struct {
+	UserID uint      `gorm:"primaryKey"`
+	Day    time.Time `gorm:"primaryKey;type:date"`
+}
+
+// Flag reports a post or a user to moderators. Flags stay pending until a moderator
+// reviews their target, which marks them helpful or declined.
+type Flag struct {
+	ID         uint   `gorm:"primaryKey"`
+	TargetType string `gorm:"index:idx_flags_target,priority:1;uniqueIndex:idx_flags_pending,priority:2,where:status = 'pending';not null"` // "question", "answer" or "user"
+	TargetID   uint   `gorm:"index:idx_flags_target,priority:2;uniqueIndex:idx_flags_pending,priority:3;not null"`
+	FlaggerID  uint   `gorm:"index;uniqueIndex:idx_flags_pending,priority:1;not null"`
+	Reason     string `gorm:"not null"`                         // "spam", "rude", "off-topic" or "needs-moderator"
+	Note       string `gorm:"type:text"`                        // Free text, required for "needs-moderator"
+	Status     string `gorm:"default:'pending';not null;index"` // "pending", "helpful" or "declined"
+	ReviewID   *uint  `gorm:"index"`
+	CreatedAt  time.Time
+	Flagger    User
+}
+
+// FlagReview records a moderator's decision on a flagged target and the action taken.
+type FlagReview struct {
+	ID          uint   `gorm:"primaryKey"`
+	TargetType  string `gorm:"index:idx_flag_reviews_target,priority:1;not null"`
+	TargetID    uint   `gorm:"index:idx_flag_reviews_target,priority:2;not null"`
+	Action      string `gorm:"not null"` // "dismiss", "delete", "lock" or "warn"
+	Note        string `gorm:"type:text"`
+	FlagCount   int    `gorm:"not null"` // Pending flags resolved by the review
+	ModeratorID uint   `gorm:"not null"`
+	CreatedAt   time.Time
+	Moderator   User
+}
+
+// AuditLog is an append-only record of a privileged or security-relevant action. The
+// entries form a hash chain: each Hash covers the entry and the Hash before it, so an
+// edited or removed entry is detectable. A trigger rejects updates and deletes.
+type AuditLog struct {
+	ID         uint   `gorm:"primaryKey"`
+	ActorID    *uint  `gorm:"index"` // nil for the system or anonymous callers, e.g. failed logins
+	Action     string `gorm:"index;not null"`
+	TargetType string `gorm:"index:idx_audit_logs_target,priority:1"`
+	TargetID   *uint  `gorm:"index:idx_audit_logs_target,priority:2"`
+	Diff       string `gorm:"type:jsonb;not null;default:'{}'"` // {"field": {"from": ..., "to": ...}}
+	Details    string `gorm:"type:jsonb;not null;default:'{}'"`
+	IP         string
+	UserAgent  string
+	PrevHash   string    `gorm:"size:64;not null;default:''"`
+	Hash       string    `gorm:"size:64;not null;default:''"`
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// BookmarkList is a named collection of a user's bookmarks, e.g. "read later".
+type BookmarkList struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"uniqueIndex:idx_bookmark_lists_user_name;not null"`
+	Name      string `gorm:"uniqueIndex:idx_bookmark_lists_user_name;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Bookmark saves a question for a user, optionally filed in one of their lists.
+type Bookmark struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"uniqueIndex:idx_bookmarks_user_question;not null"`
+	QuestionID uint   `gorm:"uniqueIndex:idx_bookmarks_user_question;not null"`
+	ListID     *uint  `gorm:"index"` // nil when not filed in a list
+	Note       string `gorm:"type:text"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Question   Question
+	List       *BookmarkList `gorm:"foreignKey:ListID"`
+}
+
+// TagPreference records a user watching or ignoring a tag.
+type TagPreference struct {
+	UserID    uint   `gorm:"primaryKey"`
+	TagID     uint   `gorm:"primaryKey;index"`
+	Kind      string `gorm:"not null"` // "watched" or "ignored"
+	CreatedAt time.Time
+	Tag       Tag
+}
+
+// Attachment is an uploaded file. QuestionID/AnswerID stay nil until the owner
+// links it to a post; unlinked attachments are garbage-collected.
+type Attachment struct {
+	gorm.Model
+	OwnerID     uint `gorm:"index;not null"`
+	Owner       User
+	QuestionID  *uint  `gorm:"index"`
+	AnswerID    *uint  `gorm:"index"`
+	StorageKey  string `gorm:"uniqueIndex;not null"`
+	FileName    string `gorm:"not null"`
+	ContentType string `gorm:"not null"`
+	Size        int64  `gorm:"not null"`
+	Width       int    // Images only
+	Height      int    // Images only
+	SHA256      string `gorm:"size:64;not null"`
+}
+
+// ContentRule is an admin-managed entry of the content filter's blocklist. Posts that
+// match are quarantined.
+type ContentRule struct {
+	ID          uint   `gorm:"primaryKey"`
+	Kind        string `gorm:"uniqueIndex:idx_content_rules_pattern,priority:1;not null"` // "domain", "keyword" or "regex"
+	Pattern     string `gorm:"uniqueIndex:idx_content_rules_pattern,priority:2;not null"`
+	Note        string
+	CreatedByID uint
+	CreatedAt   time.Time
+}
+
+// SpamToken holds how many moderated spam and ham posts contained a token, for the
+// naive Bayes classifier.
+type SpamToken struct {
+	Token string `gorm:"primaryKey;size:64"`
+	Spam  int    `gorm:"default:0;not null"`
+	Ham   int    `gorm:"default:0;not null"`
+}
+
+// SpamTrainingPost records that a post's tokens were counted, and as which class, so
+// a post is trained at most once and moves class if a later decision disagrees.
+type SpamTrainingPost struct {
+	ID         uint   `gorm:"primaryKey"`
+	TargetType string `gorm:"uniqueIndex:idx_spam_training_target,priority:1;not null"`
+	TargetID   uint   `gorm:"uniqueIndex:idx_spam_training_target,priority:2;not null"`
+	IsSpam     bool   `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
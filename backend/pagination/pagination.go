@@ -0,0 +1,145 @@
+// pagination/pagination.go
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the position after the last row of a page. Clients treat it as opaque.
+// Keyset orderings store the last row's sort key and ID; orderings that cannot be
+// keyed (e.g. search rank) fall back to Offset.
+type Cursor struct {
+	Sort   string    `json:"s,omitempty"` // Ordering the cursor was issued for
+	ID     uint      `json:"i,omitempty"`
+	Time   time.Time `json:"t,omitempty"`
+	Int    int64     `json:"n,omitempty"`
+	Float  float64   `json:"f,omitempty"`
+	Offset int       `json:"o,omitempty"`
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Params are the paging inputs of a list request.
+type Params struct {
+	Limit     int
+	After     *Cursor // nil for the first page
+	WithTotal bool    // Whether the client asked for a total count
+}
+
+// FromRequest reads ?limit=, ?cursor= and ?include_total= from the request.
+// Limits above MaxLimit are clamped.
+func FromRequest(c echo.Context) (Params, error) {
+	p := Params{Limit: DefaultLimit}
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return p, echo.NewHTTPError(http.StatusBadRequest, "limit must be a positive integer")
+		}
+		p.Limit = min(limit, MaxLimit)
+	}
+	if v := c.QueryParam("cursor"); v != "" {
+		cursor, err := DecodeCursor(v)
+		if err != nil {
+			return p, echo.NewHTTPError(http.StatusBadRequest, "Invalid cursor")
+		}
+		p.After = cursor
+	}
+	p.WithTotal = c.QueryParam("include_total") == "true"
+	return p, nil
+}
+
+// CheckSort rejects cursors issued for a different ordering than the current request.
+func (p Params) CheckSort(sort string) error {
+	if p.After != nil && p.After.Sort != sort {
+		return fmt.Errorf("%w: issued for sort %q", ErrInvalidCursor, p.After.Sort)
+	}
+	return nil
+}
+
+// Offset returns the cursor's offset for non-keyset orderings.
+func (p Params) Offset() int {
+	if p.After == nil {
+		return 0
+	}
+	return p.After.Offset
+}
+
+// Trim drops the extra row fetched to detect a further page. Queries should
+// request limit+1 rows.
+func Trim[T any](rows []T, limit int) ([]T, bool) {
+	if len(rows) > limit {
+		return rows[:limit], true
+	}
+	return rows, false
+}
+
+// Page is the response envelope of every list endpoint.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Total      *int64 `json:"total,omitempty"` // Only with ?include_total=true
+}
+
+// Respond writes the page envelope and an RFC 8288 Link header with "first" and,
+// when there is a further page, "next" relations.
+func Respond[T any](c echo.Context, items []T, next *Cursor, total *int64) error {
+	page := Page[T]{Items: items, HasMore: next != nil, Total: total}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	links := fmt.Sprintf(`<%s>; rel="first"`, pageURL(c, ""))
+	if next != nil {
+		page.NextCursor = next.Encode()
+		links = fmt.Sprintf(`<%s>; rel="next", `, pageURL(c, page.NextCursor)) + links
+	}
+	c.Response().Header().Set("Link", links)
+	return c.JSON(http.StatusOK, page)
+}
+
+func pageURL(c echo.Context, cursor string) string {
+	u := url.URL{
+		Scheme: c.Scheme(),
+		Host:   c.Request().Host,
+		Path:   c.Request().URL.Path,
+	}
+	query := c.Request().URL.Query()
+	query.Del("cursor")
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
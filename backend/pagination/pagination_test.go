@@ -0,0 +1,54 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{"empty", Cursor{}},
+		{"id", Cursor{ID: 42}},
+		{"time keyset", Cursor{Sort: "newest", ID: 7, Time: time.Date(2024, 5, 1, 12, 30, 15, 123456000, time.UTC)}},
+		{"time in another zone", Cursor{Sort: "activity", ID: 7, Time: time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60))}},
+		{"int keyset", Cursor{Sort: "votes", ID: 3, Int: -12}},
+		{"float keyset", Cursor{Sort: "hot", ID: 9, Float: 0.1 + 0.2}},
+		{"offset", Cursor{Sort: "relevance", Offset: 40}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := tt.cursor.Encode()
+			got, err := DecodeCursor(encoded)
+			if err != nil {
+				t.Fatalf("DecodeCursor(%q) error: %v", encoded, err)
+			}
+			want := tt.cursor
+			if !got.Time.Equal(want.Time) {
+				t.Errorf("Time = %v, want %v", got.Time, want.Time)
+			}
+			got.Time, want.Time = time.Time{}, time.Time{}
+			if *got != want {
+				t.Errorf("DecodeCursor(Encode(%+v)) = %+v", tt.cursor, *got)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, s := range []string{
+		"not base64!",
+		base64.StdEncoding.EncodeToString([]byte(`{"i":1}`)), // Padded
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"i":-1}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"t":"yesterday"}`)),
+	} {
+		if c, err := DecodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) = %+v, %v, want ErrInvalidCursor", s, c, err)
+		}
+	}
+}
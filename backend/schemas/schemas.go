@@ -1,6 +1,9 @@
 package schemas
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // User Schemas
 type UserCreate struct {
@@ -14,13 +17,77 @@ type UserLogin struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// UserResponse is returned to the user themselves and to admins; other users get a
+// ProfileResponse.
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Role      string    `json:"role"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	IsActive    bool   `json:"is_active"`
+	Reputation  int    `json:"reputation"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Location    string `json:"location"`
+	WebsiteURL  string `json:"website_url"`
+	AvatarURL   string `json:"avatar_url"`
+	ShowEmail   bool   `json:"show_email"`
+	// Flag accuracy: reviewed flags found helpful or declined
+	HelpfulFlags  int `json:"helpful_flags"`
+	DeclinedFlags int `json:"declined_flags"`
+	// Account restrictions, set while they apply
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	BannedAt         *time.Time `json:"banned_at,omitempty"`
+	BanReason        string     `json:"ban_reason,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Fields left out of the request are not changed; an empty string clears a field
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=3000"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	WebsiteURL  *string `json:"website_url" validate:"omitempty,http_url,max=200"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,http_url,max=500"`
+	ShowEmail   *bool   `json:"show_email"`
+}
+
+type BadgeCountsResponse struct {
+	Gold   int64 `json:"gold"`
+	Silver int64 `json:"silver"`
+	Bronze int64 `json:"bronze"`
+}
+
+// ProfileResponse is a user's public profile. Email is only included when the user
+// shows it, or for the user themselves and admins.
+type ProfileResponse struct {
+	ID            uint                `json:"id"`
+	Username      string              `json:"username"`
+	DisplayName   string              `json:"display_name"`
+	Bio           string              `json:"bio"`      // Markdown source
+	BioHTML       string              `json:"bio_html"` // Sanitized render
+	Location      string              `json:"location"`
+	WebsiteURL    string              `json:"website_url"`
+	AvatarURL     string              `json:"avatar_url"`
+	Email         string              `json:"email,omitempty"`
+	Role          string              `json:"role"`
+	Reputation    int                 `json:"reputation"`
+	QuestionCount int64               `json:"question_count"`
+	AnswerCount   int64               `json:"answer_count"`
+	Badges        BadgeCountsResponse `json:"badges"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
+
+// ActivityResponse is one entry of a user's activity timeline. ID is the question,
+// answer or badge award the entry is about.
+type ActivityResponse struct {
+	Kind       string    `json:"kind"` // "question", "answer", "accepted_answer" or "badge"
+	ID         uint      `json:"id"`
+	QuestionID *uint     `json:"question_id,omitempty"`
+	Title      string    `json:"title,omitempty"` // Question title
+	Badge      string    `json:"badge,omitempty"` // Badge slug
+	OccurredAt time.Time `json:"occurred_at"`
 }
 
 type Token struct {
@@ -30,35 +97,91 @@ type Token struct {
 
 // Question Schemas
 type QuestionCreate struct {
-	Title       string   `json:"title" validate:"required"`
-	Description string   `json:"description" validate:"required"`
-	Tags        []string `json:"tags"`
+	Title         string   `json:"title" validate:"required"`
+	Description   string   `json:"description" validate:"required"`
+	ContentFormat string   `json:"content_format" validate:"omitempty,oneof=html markdown"` // Defaults to "html"
+	Tags          []string `json:"tags"`
+	AttachmentIDs []uint   `json:"attachment_ids"` // Previously uploaded, unlinked attachments
+	// Set after reviewing the candidates of a 409 duplicate warning to post anyway
+	ConfirmNotDuplicate bool `json:"confirm_not_duplicate"`
 }
 
+// Description carries the stored source and DescriptionHTML the sanitized render;
+// which of the two is populated depends on the ?content= query parameter.
 type QuestionResponse struct {
-	ID          uint          `json:"id"`
-	Title       string        `json:"title"`
-	Description string        `json:"description"`
-	OwnerID     uint          `json:"owner_id"`
-	Tags        []TagResponse `json:"tags"` // Include tags in the response
-	CreatedAt   time.Time     `json:"created_at"`
-	UpdatedAt   *time.Time    `json:"updated_at,omitempty"`
+	ID              uint                 `json:"id"`
+	Title           string               `json:"title"`
+	Description     string               `json:"description,omitempty"`
+	DescriptionHTML string               `json:"description_html,omitempty"`
+	ContentFormat   string               `json:"content_format"`
+	OwnerID         uint                 `json:"owner_id"`
+	Status          string               `json:"status"` // "open", "closed" or "locked"
+	CloseReason     string               `json:"close_reason,omitempty"`
+	DuplicateOfID   *uint                `json:"duplicate_of_id,omitempty"`
+	ClosedAt        *time.Time           `json:"closed_at,omitempty"`
+	Hidden          bool                 `json:"hidden,omitempty"`      // Hidden by spam flags or quarantine, pending moderator review
+	Quarantined     bool                 `json:"quarantined,omitempty"` // Held by the content filter
+	Score           int                  `json:"score"`
+	AnswerCount     int                  `json:"answer_count"`
+	HasAccepted     bool                 `json:"has_accepted"`
+	ViewCount       int                  `json:"view_count"`
+	IsBookmarked    bool                 `json:"is_bookmarked"`    // For the calling user
+	Bounty          *BountyResponse      `json:"bounty,omitempty"` // Active bounty, on the question detail
+	LastActivityAt  time.Time            `json:"last_activity_at"`
+	Tags            []TagResponse        `json:"tags"` // Include tags in the response
+	Attachments     []AttachmentResponse `json:"attachments,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       *time.Time           `json:"updated_at,omitempty"`
+}
+
+type CloseQuestionRequest struct {
+	Reason        string `json:"reason" validate:"required,oneof=duplicate off-topic unclear too-broad opinion-based"`
+	DuplicateOfID *uint  `json:"duplicate_of_id"` // Required when reason is "duplicate"
+}
+
+type SimilarQuestionsRequest struct {
+	Title string   `json:"title" validate:"required"`
+	Tags  []string `json:"tags"`
+}
+
+type SimilarQuestionResponse struct {
+	ID              uint    `json:"id"`
+	Title           string  `json:"title"`
+	TitleSimilarity float64 `json:"title_similarity"`
+	SharedTags      int     `json:"shared_tags"`
+	Score           float64 `json:"score"`
+}
+
+// Returned with 409 Conflict when CreateQuestion finds likely duplicates
+type DuplicateWarningResponse struct {
+	Message    string                    `json:"message"`
+	Candidates []SimilarQuestionResponse `json:"candidates"`
 }
 
 // Answer Schemas
 type AnswerCreate struct {
-	Content    string `json:"content" validate:"required"`
-	QuestionID uint   `json:"question_id" validate:"required"`
+	Content       string `json:"content" validate:"required"`
+	ContentFormat string `json:"content_format" validate:"omitempty,oneof=html markdown"` // Defaults to "html"
+	QuestionID    uint   `json:"question_id" validate:"required"`
+	AttachmentIDs []uint `json:"attachment_ids"` // Previously uploaded, unlinked attachments
 }
 
+// Content carries the stored source and ContentHTML the sanitized render;
+// which of the two is populated depends on the ?content= query parameter.
 type AnswerResponse struct {
-	ID         uint       `json:"id"`
-	Content    string     `json:"content"`
-	QuestionID uint       `json:"question_id"`
-	OwnerID    uint       `json:"owner_id"`
-	IsAccepted bool       `json:"is_accepted"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	ID            uint                 `json:"id"`
+	Content       string               `json:"content,omitempty"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	ContentFormat string               `json:"content_format"`
+	QuestionID    uint                 `json:"question_id"`
+	OwnerID       uint                 `json:"owner_id"`
+	IsAccepted    bool                 `json:"is_accepted"`
+	Hidden        bool                 `json:"hidden,omitempty"`      // Hidden by spam flags or quarantine, pending moderator review
+	Quarantined   bool                 `json:"quarantined,omitempty"` // Held by the content filter
+	Score         int                  `json:"score"`
+	Attachments   []AttachmentResponse `json:"attachments,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     *time.Time           `json:"updated_at,omitempty"`
 }
 
 // Tag Schemas
@@ -67,6 +190,40 @@ type TagResponse struct {
 	Name string `json:"name"`
 }
 
+type TagSummaryResponse struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Excerpt       string `json:"excerpt"`
+	QuestionCount int64  `json:"question_count"`
+}
+
+type TagDetailResponse struct {
+	ID            uint      `json:"id"`
+	Name          string    `json:"name"`
+	Excerpt       string    `json:"excerpt"`
+	Wiki          string    `json:"wiki"`      // Markdown source
+	WikiHTML      string    `json:"wiki_html"` // Sanitized render
+	QuestionCount int64     `json:"question_count"`
+	Synonyms      []string  `json:"synonyms"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Fields left out of the request are not changed
+type TagUpdate struct {
+	Excerpt *string `json:"excerpt" validate:"omitempty,max=500"`
+	Wiki    *string `json:"wiki" validate:"omitempty,max=30000"`
+}
+
+type TagSynonymCreate struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// Merges the tag in the URL into Target
+type TagMergeRequest struct {
+	Target string `json:"target" validate:"required"`
+}
+
 // Vote Schemas
 type VoteCreate struct {
 	AnswerID uint `json:"answer_id" validate:"required"`
@@ -75,9 +232,300 @@ type VoteCreate struct {
 
 // Notification Schemas
 type NotificationResponse struct {
+	ID         uint      `json:"id"`
+	UserID     uint      `json:"user_id"`
+	Message    string    `json:"message"`
+	Kind       string    `json:"kind,omitempty"`
+	QuestionID *uint     `json:"question_id,omitempty"`
+	IsRead     bool      `json:"is_read"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Bookmark Schemas
+type BookmarkRequest struct {
+	ListID *uint  `json:"list_id"` // Omit to keep the bookmark outside any list
+	Note   string `json:"note" validate:"max=2000"`
+}
+
+type BookmarkResponse struct {
+	ID         uint             `json:"id"`
+	QuestionID uint             `json:"question_id"`
+	ListID     *uint            `json:"list_id,omitempty"`
+	Note       string           `json:"note"`
+	Question   QuestionResponse `json:"question"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+type BookmarkListRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type BookmarkListResponse struct {
+	ID            uint      `json:"id"`
+	Name          string    `json:"name"`
+	BookmarkCount int64     `json:"bookmark_count"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Reputation Schemas
+type ReputationEventResponse struct {
+	ID         uint      `json:"id"`
+	Kind       string    `json:"kind"`
+	Delta      int       `json:"delta"`
+	QuestionID *uint     `json:"question_id,omitempty"`
+	AnswerID   *uint     `json:"answer_id,omitempty"`
+	ReversesID *uint     `json:"reverses_id,omitempty"` // Set on reversals of an earlier event
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Bounty Schemas
+type BountyCreate struct {
+	Amount int    `json:"amount" validate:"required,min=1"`
+	Reason string `json:"reason" validate:"required,oneof=needs-attention improve-details authoritative-reference canonical-answer current-answers-outdated reward-existing-answer"`
+	Days   int    `json:"days" validate:"omitempty,min=1"` // Defaults to BOUNTY_MAX_DAYS
+}
+
+type BountyAwardRequest struct {
+	AnswerID uint `json:"answer_id" validate:"required"`
+}
+
+type BountyResponse struct {
+	ID              uint              `json:"id"`
+	QuestionID      uint              `json:"question_id"`
+	OwnerID         uint              `json:"owner_id"`
+	Amount          int               `json:"amount"`
+	Reason          string            `json:"reason"`
+	Status          string            `json:"status"` // "active", "awarded", "expired" or "refunded"
+	ExpiresAt       time.Time         `json:"expires_at"`
+	AwardedAnswerID *uint             `json:"awarded_answer_id,omitempty"`
+	AwardedAt       *time.Time        `json:"awarded_at,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	Question        *QuestionResponse `json:"question,omitempty"` // In the featured list
+}
+
+// Leaderboard Schemas
+type LeaderboardEntryResponse struct {
+	Rank            int       `json:"rank"`
+	UserID          uint      `json:"user_id"`
+	Username        string    `json:"username"`
+	Reputation      int       `json:"reputation"` // Gained within the period
+	AcceptedAnswers int       `json:"accepted_answers"`
+	AnswerScore     int       `json:"answer_score"`
+	RefreshedAt     time.Time `json:"refreshed_at"`
+}
+
+// Badge Schemas
+type BadgeResponse struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Tier        string `json:"tier"` // "bronze", "silver" or "gold"
+	AwardCount  int64  `json:"award_count"`
+}
+
+type BadgeAwardResponse struct {
 	ID        uint      `json:"id"`
+	Badge     string    `json:"badge"`
+	Name      string    `json:"name"`
+	Tier      string    `json:"tier"`
 	UserID    uint      `json:"user_id"`
-	Message   string    `json:"message"`
-	IsRead    bool      `json:"is_read"`
+	Username  string    `json:"username"`
+	PostType  string    `json:"post_type,omitempty"` // "question" or "answer" for per-post badges
+	PostID    uint      `json:"post_id,omitempty"`
+	AwardedAt time.Time `json:"awarded_at"`
+}
+
+// Privilege Schemas
+type PrivilegeResponse struct {
+	Name               string `json:"name"`
+	Description        string `json:"description"`
+	RequiredReputation int    `json:"required_reputation"`
+	Remaining          int    `json:"remaining,omitempty"` // Reputation still needed, for upcoming privileges
+}
+
+type PrivilegesResponse struct {
+	Reputation int                 `json:"reputation"`
+	Earned     []PrivilegeResponse `json:"earned"`
+	Upcoming   []PrivilegeResponse `json:"upcoming"`
+}
+
+// PrivilegeErrorResponse is the 403 body returned when an action needs more reputation.
+type PrivilegeErrorResponse struct {
+	Code               string `json:"code"` // Always "missing_privilege"
+	Message            string `json:"message"`
+	Privilege          string `json:"privilege"`
+	RequiredReputation int    `json:"required_reputation"`
+	Reputation         int    `json:"reputation"`
+}
+
+// Tag preference Schemas
+type TagPreferenceRequest struct {
+	Kind string `json:"kind" validate:"required,oneof=watched ignored"`
+}
+
+type TagPreferenceResponse struct {
+	TagID     uint      `json:"tag_id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// Attachment Schemas
+type AttachmentResponse struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Search Schemas
+type SearchResultResponse struct {
+	ID             uint      `json:"id"`
+	Title          string    `json:"title"`
+	TitleHighlight string    `json:"title_highlight"` // HTML-escaped, matches wrapped in <mark>
+	Snippet        string    `json:"snippet"`         // HTML-escaped, matches wrapped in <mark>
+	OwnerID        uint      `json:"owner_id"`
+	Rank           float64   `json:"rank"`
+	Score          int       `json:"score"`
+	AnswerCount    int       `json:"answer_count"`
+	HasAccepted    bool      `json:"has_accepted"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Flag Schemas
+type FlagCreate struct {
+	Reason string `json:"reason" validate:"required,oneof=spam rude off-topic needs-moderator"`
+	Note   string `json:"note" validate:"required_if=Reason needs-moderator,max=500"` // Required for "needs-moderator"
+}
+
+// FlaggerResponse shows moderators who raised a flag and how accurate their flags are.
+type FlaggerResponse struct {
+	ID            uint   `json:"id"`
+	Username      string `json:"username"`
+	HelpfulFlags  int    `json:"helpful_flags"`
+	DeclinedFlags int    `json:"declined_flags"`
+}
+
+type FlagResponse struct {
+	ID         uint             `json:"id"`
+	TargetType string           `json:"target_type"` // "question", "answer" or "user"
+	TargetID   uint             `json:"target_id"`
+	Reason     string           `json:"reason"`
+	Note       string           `json:"note,omitempty"`
+	Status     string           `json:"status"`            // "pending", "helpful" or "declined"
+	Flagger    *FlaggerResponse `json:"flagger,omitempty"` // In the review queue
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// FlagSummaryResponse is an entry of the moderator review queue.
+type FlagSummaryResponse struct {
+	TargetType     string           `json:"target_type"`
+	TargetID       uint             `json:"target_id"`
+	FlagCount      int64            `json:"flag_count"`
+	Reasons        map[string]int64 `json:"reasons"` // Pending flags per reason
+	Hidden         bool             `json:"hidden"`
+	FirstFlaggedAt time.Time        `json:"first_flagged_at"`
+	LastFlaggedAt  time.Time        `json:"last_flagged_at"`
+}
+
+// FlaggedTargetResponse is a flagged post or user with its pending flags; one of
+// Question, Answer and User is set.
+type FlaggedTargetResponse struct {
+	TargetType string            `json:"target_type"`
+	TargetID   uint              `json:"target_id"`
+	Question   *QuestionResponse `json:"question,omitempty"`
+	Answer     *AnswerResponse   `json:"answer,omitempty"`
+	User       *UserResponse     `json:"user,omitempty"`
+	Flags      []FlagResponse    `json:"flags"`
+}
+
+type FlagReviewRequest struct {
+	Action string `json:"action" validate:"required,oneof=dismiss delete lock warn"`
+	Note   string `json:"note" validate:"max=1000"` // Included in the warning sent to the user
+}
+
+type FlagReviewResponse struct {
+	ID          uint      `json:"id"`
+	TargetType  string    `json:"target_type"`
+	TargetID    uint      `json:"target_id"`
+	Action      string    `json:"action"`
+	Note        string    `json:"note,omitempty"`
+	FlagCount   int       `json:"flag_count"`
+	ModeratorID uint      `json:"moderator_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Admin Schemas
+type RoleUpdate struct {
+	Role string `json:"role" validate:"required,oneof=guest user moderator admin"`
+}
+
+type SuspendRequest struct {
+	DurationHours int    `json:"duration_hours" validate:"required,min=1"`
+	Reason        string `json:"reason" validate:"required,max=500"`
+}
+
+type BanRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type MergeUsersRequest struct {
+	TargetUserID uint `json:"target_user_id" validate:"required"` // Account that keeps the merged content
+}
+
+type PasswordResetResponse struct {
+	TemporaryPassword string `json:"temporary_password"` // Shown once; every session is logged out
+}
+
+// Content Filter Schemas
+type QuarantinedPostResponse struct {
+	TargetType    string            `json:"target_type"`
+	TargetID      uint              `json:"target_id"`
+	Reason        string            `json:"reason"` // Which content checks held the post
+	QuarantinedAt time.Time         `json:"quarantined_at"`
+	Question      *QuestionResponse `json:"question,omitempty"`
+	Answer        *AnswerResponse   `json:"answer,omitempty"`
+}
+
+type ContentRuleCreate struct {
+	Kind    string `json:"kind" validate:"required,oneof=domain keyword regex"`
+	Pattern string `json:"pattern" validate:"required,max=500"`
+	Note    string `json:"note" validate:"max=500"`
+}
+
+type ContentRuleResponse struct {
+	ID          uint      `json:"id"`
+	Kind        string    `json:"kind"`
+	Pattern     string    `json:"pattern"`
+	Note        string    `json:"note,omitempty"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Audit Schemas
+type AuditLogResponse struct {
+	ID         uint            `json:"id"`
+	ActorID    *uint           `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   *uint           `json:"target_id,omitempty"`
+	Diff       json.RawMessage `json:"diff"`
+	Details    json.RawMessage `json:"details"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditVerificationResponse struct {
+	Valid      bool   `json:"valid"`
+	Checked    int64  `json:"checked"`      // Entries verified, including the broken one
+	BrokenAtID *uint  `json:"broken_at_id"` // First entry that fails verification
+	Reason     string `json:"reason,omitempty"`
+}
@@ -0,0 +1,469 @@
+// services/admin_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Account statuses accepted by UserFilter
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+)
+
+// Roles an admin can assign
+var UserRoles = []string{"guest", "user", "moderator", "admin"}
+
+var (
+	ErrCannotManageSelf = errors.New("admins cannot apply this action to their own account")
+	ErrInvalidRole      = errors.New("role must be one of: guest, user, moderator, admin")
+	ErrUserBanned       = errors.New("user is banned")
+	ErrNotSuspended     = errors.New("user is not suspended")
+	ErrMergeSameUser    = errors.New("cannot merge an account into itself")
+)
+
+type UserFilter struct {
+	Query      string // Substring of the username or email, case-insensitive
+	Role       string
+	Status     string     // One of the UserStatus* constants
+	JoinedFrom *time.Time // Inclusive
+	JoinedTo   *time.Time // Exclusive
+}
+
+// AdminService carries out admin operations on user accounts. Every change is written
+// to the audit log in the same transaction.
+type AdminService struct {
+	DB       *gorm.DB
+	Config   *config.Config
+	Sessions *SessionService
+}
+
+func NewAdminService(db *gorm.DB, cfg *config.Config, sessions *SessionService) *AdminService {
+	return &AdminService{DB: db, Config: cfg, Sessions: sessions}
+}
+
+func (s *AdminService) filteredUsers(filter UserFilter) *gorm.DB {
+	db := s.DB.Model(&models.User{})
+	if filter.Query != "" {
+		pattern := "%" + escapeLike(strings.ToLower(filter.Query)) + "%"
+		db = db.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		db = db.Where("role = ?", filter.Role)
+	}
+	now := time.Now()
+	switch filter.Status {
+	case UserStatusActive:
+		db = db.Where("banned_at IS NULL AND (suspended_until IS NULL OR suspended_until <= ?)", now)
+	case UserStatusSuspended:
+		db = db.Where("banned_at IS NULL AND suspended_until > ?", now)
+	case UserStatusBanned:
+		db = db.Where("banned_at IS NOT NULL")
+	}
+	if filter.JoinedFrom != nil {
+		db = db.Where("created_at >= ?", *filter.JoinedFrom)
+	}
+	if filter.JoinedTo != nil {
+		db = db.Where("created_at < ?", *filter.JoinedTo)
+	}
+	return db
+}
+
+// SearchUsers returns a page of users matching the filter in signup order.
+func (s *AdminService) SearchUsers(filter UserFilter, page pagination.Params) ([]models.User, *pagination.Cursor, error) {
+	db := s.filteredUsers(filter).Order("id ASC")
+	if page.After != nil {
+		db = db.Where("id > ?", page.After.ID)
+	}
+
+	var users []models.User
+	if err := db.Limit(page.Limit + 1).Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+	users, hasMore := pagination.Trim(users, page.Limit)
+	if !hasMore {
+		return users, nil, nil
+	}
+	return users, &pagination.Cursor{ID: users[len(users)-1].ID}, nil
+}
+
+func (s *AdminService) CountUsers(filter UserFilter) (int64, error) {
+	var count int64
+	err := s.filteredUsers(filter).Count(&count).Error
+	return count, err
+}
+
+// ChangeRole assigns a role. It applies to the user's existing sessions immediately.
+func (s *AdminService) ChangeRole(actor AuditActor, userID uint, role string) (*models.User, error) {
+	if !containsString(UserRoles, role) {
+		return nil, ErrInvalidRole
+	}
+	return s.update(actor, userID, func(tx *gorm.DB, user *models.User) (AuditEntry, error) {
+		entry := AuditEntry{Action: AuditUserRoleChanged, Diff: map[string]AuditChange{"role": {From: user.Role, To: role}}}
+		user.Role = role
+		return entry, tx.Model(user).Update("role", role).Error
+	})
+}
+
+// Suspend blocks the user from logging in or using existing sessions for the duration.
+// The suspension worker lifts it afterwards.
+func (s *AdminService) Suspend(actor AuditActor, userID uint, duration time.Duration, reason string) (*models.User, error) {
+	return s.update(actor, userID, func(tx *gorm.DB, user *models.User) (AuditEntry, error) {
+		if user.BannedAt != nil {
+			return AuditEntry{}, ErrUserBanned
+		}
+		until := time.Now().Add(duration)
+		entry := AuditEntry{Action: AuditUserSuspended, Diff: map[string]AuditChange{
+			"suspended_until":   {From: user.SuspendedUntil, To: until},
+			"suspension_reason": {From: user.SuspensionReason, To: reason},
+		}}
+		user.SuspendedUntil, user.SuspensionReason = &until, reason
+		return entry, tx.Model(user).Updates(map[string]interface{}{"suspended_until": until, "suspension_reason": reason}).Error
+	})
+}
+
+// Unsuspend lifts a suspension early.
+func (s *AdminService) Unsuspend(actor AuditActor, userID uint) (*models.User, error) {
+	return s.update(actor, userID, func(tx *gorm.DB, user *models.User) (AuditEntry, error) {
+		if user.SuspendedUntil == nil {
+			return AuditEntry{}, ErrNotSuspended
+		}
+		entry := AuditEntry{Action: AuditUserUnsuspended, Diff: map[string]AuditChange{
+			"suspended_until":   {From: user.SuspendedUntil, To: nil},
+			"suspension_reason": {From: user.SuspensionReason, To: ""},
+		}}
+		user.SuspendedUntil, user.SuspensionReason = nil, ""
+		return entry, tx.Model(user).
+			Updates(map[string]interface{}{"suspended_until": nil, "suspension_reason": ""}).Error
+	})
+}
+
+// Ban permanently blocks the user.
+func (s *AdminService) Ban(actor AuditActor, userID uint, reason string) (*models.User, error) {
+	return s.update(actor, userID, func(tx *gorm.DB, user *models.User) (AuditEntry, error) {
+		if user.BannedAt != nil {
+			return AuditEntry{}, ErrUserBanned
+		}
+		now := time.Now()
+		entry := AuditEntry{Action: AuditUserBanned, Diff: map[string]AuditChange{
+			"banned_at":  {From: nil, To: now},
+			"ban_reason": {From: user.BanReason, To: reason},
+			"is_active":  {From: user.IsActive, To: false},
+		}}
+		user.BannedAt, user.BanReason, user.IsActive = &now, reason, false
+		return entry, tx.Model(user).
+			Updates(map[string]interface{}{"banned_at": now, "ban_reason": reason, "is_active": false}).Error
+	})
+}
+
+// ResetPassword replaces the user's password with a random temporary one, which is
+// returned once, and logs out every session.
+func (s *AdminService) ResetPassword(actor AuditActor, userID uint) (string, error) {
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	password := base64.RawURLEncoding.EncodeToString(raw)
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.update(actor, userID, func(tx *gorm.DB, user *models.User) (AuditEntry, error) {
+		// The password itself never reaches the log, only that it changed
+		return AuditEntry{Action: AuditUserPasswordReset, Diff: sessionVersionDiff(user)}, tx.Model(user).Updates(map[string]interface{}{
+			"hashed_password": hashed,
+			"session_version": gorm.Expr("session_version + 1"),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// ForceLogout revokes every token issued to the user so far.
+func (s *AdminService) ForceLogout(actor AuditActor, userID uint) error {
+	_, err := s.update(actor, userID, func(tx *gorm.DB, user *models.User) (AuditEntry, error) {
+		return AuditEntry{Action: AuditUserLoggedOut, Diff: sessionVersionDiff(user)}, tx.Model(user).
+			UpdateColumn("session_version", gorm.Expr("session_version + 1")).Error
+	})
+	return err
+}
+
+// update loads the user under a row lock, applies change and records the audit entry
+// it returns, targeted at the user.
+func (s *AdminService) update(actor AuditActor, userID uint, change func(tx *gorm.DB, user *models.User) (AuditEntry, error)) (*models.User, error) {
+	if actor.UserID != nil && *actor.UserID == userID {
+		return nil, ErrCannotManageSelf
+	}
+
+	var user models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		entry, err := change(tx, &user)
+		if err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = AuditTargetUser, &userID
+		return recordAudit(tx, actor, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Sessions.Invalidate(userID)
+	return &user, nil
+}
+
+func sessionVersionDiff(user *models.User) map[string]AuditChange {
+	return map[string]AuditChange{"session_version": {From: user.SessionVersion, To: user.SessionVersion + 1}}
+}
+
+// MergeUsers folds a duplicate account into another: the duplicate's questions,
+// answers, votes, attachments, bounties, bookmarks, follows, tag preferences, close
+// votes, pending flags and reputation move to the target, and the duplicate is
+// deleted. Where both accounts voted on the same answer, the target's vote is kept;
+// where both bookmarked a question, followed a post, set a preference for a tag,
+// voted to close or reopen a question or flagged the same thing, the target's is kept.
+// Votes and acceptances between the two accounts would become self-votes and
+// self-acceptances, so votes are dropped and both lose the reputation they earned.
+func (s *AdminService) MergeUsers(actor AuditActor, sourceID, targetID uint) (*models.User, error) {
+	if sourceID == targetID {
+		return nil, ErrMergeSameUser
+	}
+	if actor.UserID != nil && *actor.UserID == sourceID {
+		return nil, ErrCannotManageSelf
+	}
+
+	var target models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var users []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint{sourceID, targetID}).Find(&users).Error; err != nil {
+			return err
+		}
+		if len(users) != 2 {
+			return ErrUserNotFound
+		}
+		source, before := users[0], users[1]
+		if source.ID != sourceID {
+			source, before = before, source
+		}
+
+		// Drop the duplicate's votes on answers the target also voted on, and votes
+		// either account cast on the other's answers
+		var conflicts []models.Vote
+		if err := tx.Where("user_id = ? AND answer_id IN (SELECT answer_id FROM votes WHERE user_id = ?)", sourceID, targetID).
+			Or("user_id = ? AND answer_id IN (SELECT id FROM answers WHERE owner_id = ?)", sourceID, targetID).
+			Or("user_id = ? AND answer_id IN (SELECT id FROM answers WHERE owner_id = ?)", targetID, sourceID).
+			Find(&conflicts).Error; err != nil {
+			return err
+		}
+		rep := &ReputationService{DB: tx, Config: s.Config}
+		for _, vote := range conflicts {
+			var answer models.Answer
+			if err := tx.Unscoped().Select("id", "owner_id", "question_id").First(&answer, vote.AnswerID).Error; err != nil {
+				return err
+			}
+			if err := reverseVoteReputation(rep, &answer, vote.UserID, vote.Type); err != nil {
+				return err
+			}
+			if err := tx.Delete(&vote).Error; err != nil {
+				return err
+			}
+			if err := applyAnswerScoreDelta(tx, vote.AnswerID, -vote.Type); err != nil {
+				return err
+			}
+		}
+
+		// Acceptances between the two stay, but without reputation, as for accepting your own answer
+		var accepted []struct {
+			AnswerID        uint
+			AnswerOwnerID   uint
+			QuestionOwnerID uint
+		}
+		if err := tx.Table("answers").Joins("JOIN questions ON questions.id = answers.question_id").
+			Select("answers.id AS answer_id, answers.owner_id AS answer_owner_id, questions.owner_id AS question_owner_id").
+			Where("answers.is_accepted AND answers.deleted_at IS NULL").
+			Where("(answers.owner_id = ? AND questions.owner_id = ?) OR (answers.owner_id = ? AND questions.owner_id = ?)",
+				sourceID, targetID, targetID, sourceID).
+			Scan(&accepted).Error; err != nil {
+			return err
+		}
+		for _, a := range accepted {
+			answer := models.Answer{OwnerID: a.AnswerOwnerID}
+			answer.ID = a.AnswerID
+			if err := reverseAcceptance(rep, &answer, a.QuestionOwnerID); err != nil {
+				return err
+			}
+		}
+
+		if err := mergeUserCollections(tx, sourceID, targetID); err != nil {
+			return err
+		}
+
+		moves := []struct {
+			table, column string
+		}{
+			{"questions", "owner_id"},
+			{"answers", "owner_id"},
+			{"votes", "user_id"},
+			{"attachments", "owner_id"},
+			{"bounties", "owner_id"},
+			{"reputation_events", "user_id"},
+			{"reputation_events", "actor_id"},
+		}
+		moved := map[string]interface{}{"source_id": sourceID, "source_username": source.Username, "dropped_votes": len(conflicts), "unrewarded_acceptances": len(accepted)}
+		for _, m := range moves {
+			result := tx.Table(m.table).Where(m.column+" = ?", sourceID).UpdateColumn(m.column, targetID)
+			if result.Error != nil {
+				return result.Error
+			}
+			moved[m.table+"."+m.column] = result.RowsAffected
+		}
+
+		// The ledger moved with the posts, so rebuild the target's cached reputation from it
+		if err := tx.Exec(`UPDATE users SET reputation = ? + COALESCE((SELECT SUM(delta) FROM reputation_events WHERE user_id = ?), 0)
+			WHERE id = ?`, BaseReputation, targetID, targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", sourceID).UpdateColumns(map[string]interface{}{
+			"reputation":      BaseReputation,
+			"is_active":       false,
+			"session_version": gorm.Expr("session_version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.User{}, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&target, targetID).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditUserMerged,
+			TargetType: AuditTargetUser,
+			TargetID:   &targetID,
+			Diff:       map[string]AuditChange{"reputation": {From: before.Reputation, To: target.Reputation}},
+			Details:    moved,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Sessions.Invalidate(sourceID)
+	return &target, nil
+}
+
+// mergeUserCollections moves the source's bookmarks, bookmark lists, follows, tag
+// preferences, close and reopen votes and pending flags to the target. Where the target
+// already has the same entry, the target's is kept and the source's is deleted, so one
+// person never counts twice towards closing a question or hiding a post; bookmarks
+// filed in a list whose name the target also uses are filed in the target's list.
+func mergeUserCollections(tx *gorm.DB, sourceID, targetID uint) error {
+	statements := []string{
+		`UPDATE bookmark_lists SET user_id = @target
+			WHERE user_id = @source AND name NOT IN (SELECT name FROM bookmark_lists WHERE user_id = @target)`,
+		`UPDATE bookmarks SET list_id = t.id FROM bookmark_lists s, bookmark_lists t
+			WHERE bookmarks.list_id = s.id AND s.user_id = @source AND t.user_id = @target AND t.name = s.name`,
+		`DELETE FROM bookmark_lists WHERE user_id = @source`,
+		`UPDATE bookmarks SET user_id = @target
+			WHERE user_id = @source AND question_id NOT IN (SELECT question_id FROM bookmarks WHERE user_id = @target)`,
+		`DELETE FROM bookmarks WHERE user_id = @source`,
+		`INSERT INTO follows (user_id, target_type, target_id, created_at)
+			SELECT @target, target_type, target_id, created_at FROM follows WHERE user_id = @source
+			ON CONFLICT DO NOTHING`,
+		`DELETE FROM follows WHERE user_id = @source`,
+		`INSERT INTO tag_preferences (user_id, tag_id, kind, created_at)
+			SELECT @target, tag_id, kind, created_at FROM tag_preferences WHERE user_id = @source
+			ON CONFLICT DO NOTHING`,
+		`DELETE FROM tag_preferences WHERE user_id = @source`,
+		`INSERT INTO close_votes (question_id, user_id, kind, reason, duplicate_of_id, created_at)
+			SELECT question_id, @target, kind, reason, duplicate_of_id, created_at FROM close_votes WHERE user_id = @source
+			ON CONFLICT DO NOTHING`,
+		`DELETE FROM close_votes WHERE user_id = @source`,
+		`UPDATE flags SET flagger_id = @target
+			WHERE flagger_id = @source AND status = 'pending' AND NOT EXISTS (SELECT 1 FROM flags t
+				WHERE t.flagger_id = @target AND t.status = 'pending' AND t.target_type = flags.target_type AND t.target_id = flags.target_id)`,
+		`DELETE FROM flags WHERE flagger_id = @source AND status = 'pending'`,
+	}
+	ids := map[string]interface{}{"source": sourceID, "target": targetID}
+	for _, statement := range statements {
+		if err := tx.Exec(statement, ids).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LiftExpiredSuspensions clears suspensions that have run out and returns how many.
+func (s *AdminService) LiftExpiredSuspensions() (int, error) {
+	var ids []uint
+	if err := s.DB.Model(&models.User{}).Where("suspended_until <= ?", time.Now()).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.User{}).Where("id = ? AND suspended_until <= ?", id, time.Now()).
+				Updates(map[string]interface{}{"suspended_until": nil, "suspension_reason": ""})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return recordAudit(tx, AuditActor{}, AuditEntry{
+				Action:     AuditUserUnsuspended,
+				TargetType: AuditTargetUser,
+				TargetID:   &id,
+				Details:    map[string]interface{}{"expired": true},
+			})
+		})
+		if err != nil {
+			return 0, err
+		}
+		s.Sessions.Invalidate(id)
+	}
+	return len(ids), nil
+}
+
+// RunSuspensionWorker lifts expired suspensions every SuspensionSweepMinutes until ctx
+// is cancelled.
+func (s *AdminService) RunSuspensionWorker(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.Config.SuspensionSweepMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		if n, err := s.LiftExpiredSuspensions(); err != nil {
+			log.Printf("Lifting expired suspensions failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Lifted %d expired suspensions", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
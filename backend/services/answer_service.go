@@ -3,55 +3,298 @@ package services
 
 import (
 	"errors"
+	"time"
+
+	"stackit/config"
 	"stackit/models"
+	"stackit/pagination"
 	"stackit/schemas"
+	"stackit/utils"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AnswerService struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Config *config.Config
+	Filter *ContentFilter // Screens new answers; nil posts them unscreened
 }
 
-func NewAnswerService(db *gorm.DB) *AnswerService {
-	return &AnswerService{DB: db}
+func NewAnswerService(db *gorm.DB, cfg *config.Config) *AnswerService {
+	return &AnswerService{DB: db, Config: cfg}
 }
 
+var (
+	ErrQuestionNotFound = errors.New("question not found")
+	ErrQuestionClosed   = errors.New("question is closed to new answers")
+)
+
 func (s *AnswerService) CreateAnswer(answerCreate *schemas.AnswerCreate, ownerID uint) (*models.Answer, error) {
+	var question models.Question
+	if err := s.DB.Select("id", "status").First(&question, answerCreate.QuestionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionNotFound
+		}
+		return nil, err
+	}
+	if question.Status != QuestionStatusOpen {
+		return nil, ErrQuestionClosed
+	}
+
+	format := answerCreate.ContentFormat
+	if format == "" {
+		format = utils.ContentFormatHTML
+	}
+	rendered, err := utils.RenderContent(answerCreate.Content, format)
+	if err != nil {
+		return nil, err
+	}
+	quarantinedAt, reason, err := screenPost(s.Filter, FollowTargetAnswer, ownerID, "", answerCreate.Content)
+	if err != nil {
+		return nil, err
+	}
+
 	answer := models.Answer{
-		Content:    answerCreate.Content,
-		QuestionID: answerCreate.QuestionID,
-		OwnerID:    ownerID,
+		Content:          answerCreate.Content,
+		ContentFormat:    format,
+		ContentHTML:      rendered,
+		QuestionID:       answerCreate.QuestionID,
+		OwnerID:          ownerID,
+		HiddenAt:         quarantinedAt,
+		QuarantinedAt:    quarantinedAt,
+		QuarantineReason: reason,
+		ContentHash:      contentHash(answerCreate.Content),
 	}
-	if err := s.DB.Create(&answer).Error; err != nil {
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&answer).Error; err != nil {
+			return err
+		}
+		// A quarantined answer is counted once released, see publishQuarantined
+		if quarantinedAt == nil {
+			if err := tx.Model(&models.Question{}).Where("id = ?", answer.QuestionID).
+				UpdateColumns(map[string]interface{}{
+					"answer_count":     gorm.Expr("answer_count + 1"),
+					"last_activity_at": answer.CreatedAt,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		// Answerers follow both their answer and the question it answers
+		if err := followTarget(tx, ownerID, FollowTargetAnswer, answer.ID); err != nil {
+			return err
+		}
+		if err := followTarget(tx, ownerID, FollowTargetQuestion, answer.QuestionID); err != nil {
+			return err
+		}
+		return linkAttachments(tx, ownerID, answerCreate.AttachmentIDs, nil, &answer.ID)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &answer, nil
 }
 
-func (s *AnswerService) GetAnswersByQuestionID(questionID uint) ([]models.Answer, error) {
+// Sort modes accepted by GetAnswersByQuestionID (oldest, votes) and GetAnswersByOwner
+// (newest, votes)
+const (
+	AnswerSortOldest = "oldest"
+	AnswerSortNewest = "newest"
+	AnswerSortVotes  = "votes"
+)
+
+// answersForQuestion returns a question's answers as viewerID sees them: hidden answers
+// are left out unless the viewer wrote them or is a moderator.
+func (s *AnswerService) answersForQuestion(questionID, viewerID uint, isModerator bool) *gorm.DB {
+	db := s.DB.Model(&models.Answer{}).Where("question_id = ?", questionID)
+	if !isModerator {
+		db = db.Where("hidden_at IS NULL OR owner_id = ?", viewerID)
+	}
+	return db
+}
+
+func (s *AnswerService) GetAnswersByQuestionID(questionID, viewerID uint, isModerator bool, sort string, page pagination.Params) ([]models.Answer, *pagination.Cursor, error) {
+	if sort != AnswerSortVotes {
+		sort = AnswerSortOldest
+	}
+	if err := page.CheckSort(sort); err != nil {
+		return nil, nil, err
+	}
+
+	db := s.answersForQuestion(questionID, viewerID, isModerator).Preload("Attachments")
+	if sort == AnswerSortVotes {
+		db = db.Order("score DESC, id DESC")
+		if page.After != nil {
+			db = db.Where("(score, id) < (?, ?)", page.After.Int, page.After.ID)
+		}
+	} else {
+		db = db.Order("id ASC")
+		if page.After != nil {
+			db = db.Where("id > ?", page.After.ID)
+		}
+	}
+
 	var answers []models.Answer
-	if err := s.DB.Where("question_id = ?", questionID).Find(&answers).Error; err != nil {
-		return nil, err
+	if err := db.Limit(page.Limit + 1).Find(&answers).Error; err != nil {
+		return nil, nil, err
+	}
+	answers, hasMore := pagination.Trim(answers, page.Limit)
+	if !hasMore {
+		return answers, nil, nil
+	}
+	last := answers[len(answers)-1]
+	return answers, &pagination.Cursor{Sort: sort, ID: last.ID, Int: int64(last.Score)}, nil
+}
+
+func (s *AnswerService) CountAnswersByQuestionID(questionID, viewerID uint, isModerator bool) (int64, error) {
+	var count int64
+	err := s.answersForQuestion(questionID, viewerID, isModerator).Count(&count).Error
+	return count, err
+}
+
+// answersByOwner returns a user's answers. Unless includeHidden, answers that are hidden
+// or on a hidden question are left out.
+func (s *AnswerService) answersByOwner(ownerID uint, includeHidden bool) *gorm.DB {
+	db := s.DB.Model(&models.Answer{}).Where("owner_id = ?", ownerID)
+	if !includeHidden {
+		db = db.Where("hidden_at IS NULL AND question_id IN (SELECT id FROM questions WHERE hidden_at IS NULL)")
 	}
-	return answers, nil
+	return db
 }
 
+// GetAnswersByOwner returns a page of a user's answers, newest first or by score.
+func (s *AnswerService) GetAnswersByOwner(ownerID uint, includeHidden bool, sort string, page pagination.Params) ([]models.Answer, *pagination.Cursor, error) {
+	if sort != AnswerSortVotes {
+		sort = AnswerSortNewest
+	}
+	if err := page.CheckSort(sort); err != nil {
+		return nil, nil, err
+	}
+
+	db := s.answersByOwner(ownerID, includeHidden).Preload("Attachments")
+	if sort == AnswerSortVotes {
+		db = db.Order("score DESC, id DESC")
+		if page.After != nil {
+			db = db.Where("(score, id) < (?, ?)", page.After.Int, page.After.ID)
+		}
+	} else {
+		db = db.Order("id DESC")
+		if page.After != nil {
+			db = db.Where("id < ?", page.After.ID)
+		}
+	}
+
+	var answers []models.Answer
+	if err := db.Limit(page.Limit + 1).Find(&answers).Error; err != nil {
+		return nil, nil, err
+	}
+	answers, hasMore := pagination.Trim(answers, page.Limit)
+	if !hasMore {
+		return answers, nil, nil
+	}
+	last := answers[len(answers)-1]
+	return answers, &pagination.Cursor{Sort: sort, ID: last.ID, Int: int64(last.Score)}, nil
+}
+
+func (s *AnswerService) CountAnswersByOwner(ownerID uint, includeHidden bool) (int64, error) {
+	var count int64
+	err := s.answersByOwner(ownerID, includeHidden).Count(&count).Error
+	return count, err
+}
+
+// UpdateAnswerAcceptedStatus accepts or unaccepts an answer. The question and then the
+// answer are locked first, so concurrent accepts on one question run one after the
+// other and each sees what the previous one did.
 func (s *AnswerService) UpdateAnswerAcceptedStatus(answerID uint, isAccepted bool) (*models.Answer, error) {
 	var answer models.Answer
-	if err := s.DB.First(&answer, answerID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("answer not found")
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var questionID uint
+		if err := tx.Model(&models.Answer{}).Where("id = ?", answerID).Limit(1).
+			Pluck("question_id", &questionID).Error; err != nil {
+			return err
 		}
-		return nil, err
+		var question models.Question
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "owner_id").
+			First(&question, questionID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&answer, answerID).Error; err != nil {
+			return err
+		}
+
+		wasAccepted := answer.IsAccepted
+		answer.IsAccepted = isAccepted
+		if isAccepted && !wasAccepted {
+			now := time.Now()
+			answer.AcceptedAt = &now
+		} else if !isAccepted {
+			answer.AcceptedAt = nil
+		}
+		rep := &ReputationService{DB: tx, Config: s.Config}
+
+		// Only one answer per question can be accepted
+		if isAccepted {
+			var previous []models.Answer
+			if err := tx.Where("question_id = ? AND id <> ? AND is_accepted", answer.QuestionID, answer.ID).
+				Find(&previous).Error; err != nil {
+				return err
+			}
+			for i := range previous {
+				if err := reverseAcceptance(rep, &previous[i], question.OwnerID); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&models.Answer{}).Where("question_id = ? AND id <> ?", answer.QuestionID, answer.ID).
+				Updates(map[string]interface{}{"is_accepted": false, "accepted_at": nil}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&answer).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case isAccepted && !wasAccepted:
+			if err := recordAcceptance(rep, &answer, question.OwnerID); err != nil {
+				return err
+			}
+		case !isAccepted && wasAccepted:
+			if err := reverseAcceptance(rep, &answer, question.OwnerID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Question{}).Where("id = ?", answer.QuestionID).
+			UpdateColumns(map[string]interface{}{"has_accepted": isAccepted, "last_activity_at": time.Now()}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("answer not found")
 	}
-	answer.IsAccepted = isAccepted
-	if err := s.DB.Save(&answer).Error; err != nil {
+	if err != nil {
 		return nil, err
 	}
 	return &answer, nil
 }
 
+// Accepting your own answer earns nothing.
+func recordAcceptance(rep *ReputationService, answer *models.Answer, questionOwnerID uint) error {
+	if answer.OwnerID == questionOwnerID {
+		return nil
+	}
+	ref := ReputationRef{QuestionID: &answer.QuestionID, AnswerID: &answer.ID, ActorID: &questionOwnerID}
+	if err := rep.Record(answer.OwnerID, RepAnswerAccepted, ref); err != nil {
+		return err
+	}
+	return rep.Record(questionOwnerID, RepAcceptedAnswer, ref)
+}
+
+func reverseAcceptance(rep *ReputationService, answer *models.Answer, questionOwnerID uint) error {
+	ref := ReputationRef{AnswerID: &answer.ID}
+	if err := rep.Reverse(answer.OwnerID, RepAnswerAccepted, ref); err != nil {
+		return err
+	}
+	return rep.Reverse(questionOwnerID, RepAcceptedAnswer, ref)
+}
+
 func (s *AnswerService) GetAnswerByID(answerID uint) (*models.Answer, error) {
 	var answer models.Answer
 	if err := s.DB.First(&answer, answerID).Error; err != nil {
@@ -75,27 +318,139 @@ func (s *AnswerService) GetQuestionOwnerID(questionID uint) (uint, error) {
 }
 
 func (s *AnswerService) CreateOrUpdateVote(userID, answerID uint, voteType int) error {
-	var vote models.Vote
-	if err := s.DB.Where("user_id = ? AND answer_id = ?", userID, answerID).First(&vote).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	// Hidden posts cannot be voted on, so nobody can prop up spam before it is reviewed
+	var target struct {
+		Status           string
+		AnswerHiddenAt   *time.Time
+		QuestionHiddenAt *time.Time
+	}
+	if err := s.DB.Table("answers").Joins("JOIN questions ON questions.id = answers.question_id").
+		Select("questions.status, answers.hidden_at AS answer_hidden_at, questions.hidden_at AS question_hidden_at").
+		Where("answers.id = ? AND answers.deleted_at IS NULL", answerID).
+		Take(&target).Error; err != nil {
+		return err
+	}
+	if target.AnswerHiddenAt != nil || target.QuestionHiddenAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	if target.Status == QuestionStatusLocked {
+		return ErrQuestionLocked
+	}
+
+	var answer models.Answer
+	if err := s.DB.Select("id", "owner_id", "question_id").First(&answer, answerID).Error; err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		rep := &ReputationService{DB: tx, Config: s.Config}
+		var vote models.Vote
+		var delta int
+		if err := tx.Where("user_id = ? AND answer_id = ?", userID, answerID).First(&vote).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
 			// Create new vote
 			newVote := models.Vote{
 				UserID:   userID,
 				AnswerID: answerID,
 				Type:     voteType,
 			}
-			return s.DB.Create(&newVote).Error
+			if err := tx.Create(&newVote).Error; err != nil {
+				return err
+			}
+			delta = voteType
+			if err := recordVoteReputation(rep, &answer, userID, voteType); err != nil {
+				return err
+			}
+		} else if vote.Type == voteType {
+			// Same vote type, delete it (unvote)
+			if err := tx.Delete(&vote).Error; err != nil {
+				return err
+			}
+			delta = -voteType
+			if err := reverseVoteReputation(rep, &answer, userID, voteType); err != nil {
+				return err
+			}
+		} else {
+			// Different vote type, update it
+			if err := reverseVoteReputation(rep, &answer, userID, vote.Type); err != nil {
+				return err
+			}
+			vote.Type = voteType
+			if err := tx.Save(&vote).Error; err != nil {
+				return err
+			}
+			delta = 2 * voteType
+			if err := recordVoteReputation(rep, &answer, userID, voteType); err != nil {
+				return err
+			}
 		}
+		return applyAnswerScoreDelta(tx, answerID, delta)
+	})
+}
+
+// recordVoteReputation credits the answer's author and, for downvotes, charges the voter.
+// Votes on your own answer carry no reputation.
+func recordVoteReputation(rep *ReputationService, answer *models.Answer, voterID uint, voteType int) error {
+	if answer.OwnerID == voterID {
+		return nil
+	}
+	ref := ReputationRef{QuestionID: &answer.QuestionID, AnswerID: &answer.ID, ActorID: &voterID}
+	if voteType > 0 {
+		return rep.Record(answer.OwnerID, RepUpvoteReceived, ref)
+	}
+	if err := rep.Record(answer.OwnerID, RepDownvoteReceived, ref); err != nil {
 		return err
 	}
+	return rep.Record(voterID, RepDownvoteCast, ref)
+}
 
-	// Vote exists, check if type is same
-	if vote.Type == voteType {
-		// Same vote type, delete it (unvote)
-		return s.DB.Delete(&vote).Error
-	} else {
-		// Different vote type, update it
-		vote.Type = voteType
-		return s.DB.Save(&vote).Error
+func reverseVoteReputation(rep *ReputationService, answer *models.Answer, voterID uint, voteType int) error {
+	ref := ReputationRef{AnswerID: &answer.ID, ActorID: &voterID}
+	if voteType > 0 {
+		return rep.Reverse(answer.OwnerID, RepUpvoteReceived, ref)
+	}
+	if err := rep.Reverse(answer.OwnerID, RepDownvoteReceived, ref); err != nil {
+		return err
+	}
+	return rep.Reverse(voterID, RepDownvoteCast, ref)
+}
+
+// applyAnswerScoreDelta keeps the denormalized answer and question scores in step with votes.
+func applyAnswerScoreDelta(tx *gorm.DB, answerID uint, delta int) error {
+	if err := tx.Model(&models.Answer{}).Where("id = ?", answerID).
+		UpdateColumn("score", gorm.Expr("score + ?", delta)).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Question{}).Where("id = (SELECT question_id FROM answers WHERE id = ?)", answerID).
+		UpdateColumns(map[string]interface{}{
+			"score":            gorm.Expr("score + ?", delta),
+			"last_activity_at": time.Now(),
+		}).Error
+}
+
+// deleteAnswer soft-deletes an answer, reverses the reputation it earned and takes it
+// out of its question's denormalized counters.
+func deleteAnswer(tx *gorm.DB, cfg *config.Config, answerID uint) error {
+	var answer models.Answer
+	if err := tx.First(&answer, answerID).Error; err != nil {
+		return err
+	}
+	rep := &ReputationService{DB: tx, Config: cfg}
+	if err := rep.ReverseAnswerEvents(answer.ID); err != nil {
+		return err
+	}
+	if err := tx.Delete(&answer).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"score": gorm.Expr("score - ?", answer.Score)}
+	if answer.QuarantinedAt == nil {
+		updates["answer_count"] = gorm.Expr("answer_count - 1")
+	}
+	if answer.IsAccepted {
+		updates["has_accepted"] = false
 	}
+	return tx.Model(&models.Question{}).Where("id = ?", answer.QuestionID).UpdateColumns(updates).Error
 }
@@ -0,0 +1,272 @@
+// services/attachment_service.go
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/storage"
+	"stackit/utils"
+
+	"github.com/gabriel-vasile/mimetype"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFileTooLarge       = errors.New("file exceeds the maximum upload size")
+	ErrUnsupportedFile    = errors.New("file type is not allowed")
+	ErrUploadQuota        = errors.New("upload quota exceeded")
+	ErrImageTooLarge      = errors.New("image dimensions exceed the allowed maximum")
+	ErrInvalidAttachments = errors.New("one or more attachments do not exist, belong to another user or are already linked")
+)
+
+// Content types accepted for upload, keyed by detected MIME type, with the extension used in storage keys.
+var allowedUploadTypes = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+type AttachmentService struct {
+	DB      *gorm.DB
+	Storage storage.Storage
+	Config  *config.Config
+}
+
+func NewAttachmentService(db *gorm.DB, store storage.Storage, cfg *config.Config) *AttachmentService {
+	return &AttachmentService{DB: db, Storage: store, Config: cfg}
+}
+
+// Upload validates, sanitizes and stores a file for the given user. The attachment
+// starts unlinked and must be referenced by a question or answer to survive GC.
+func (s *AttachmentService) Upload(ctx context.Context, ownerID uint, fileName string, r io.Reader) (*models.Attachment, error) {
+	// Read one byte past the limit so oversize files are detected without trusting Content-Length
+	data, err := io.ReadAll(io.LimitReader(r, s.Config.MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.Config.MaxUploadBytes {
+		return nil, ErrFileTooLarge
+	}
+
+	contentType := strings.SplitN(mimetype.Detect(data).String(), ";", 2)[0]
+	ext, ok := allowedUploadTypes[contentType]
+	if !ok {
+		return nil, ErrUnsupportedFile
+	}
+
+	var width, height int
+	if strings.HasPrefix(contentType, "image/") {
+		width, height, err = utils.ImageDimensions(data)
+		if err != nil {
+			return nil, ErrUnsupportedFile
+		}
+		if width > s.Config.MaxImageDimension || height > s.Config.MaxImageDimension {
+			return nil, ErrImageTooLarge
+		}
+		if data, err = utils.StripImageMetadata(data, contentType); err != nil {
+			return nil, ErrUnsupportedFile
+		}
+	}
+
+	var used int64
+	if err := s.DB.Model(&models.Attachment{}).Where("owner_id = ?", ownerID).
+		Select("COALESCE(SUM(size), 0)").Scan(&used).Error; err != nil {
+		return nil, err
+	}
+	if used+int64(len(data)) > s.Config.UserUploadQuota {
+		return nil, ErrUploadQuota
+	}
+
+	key, err := newStorageKey(ext)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, fmt.Errorf("failed to store file: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := models.Attachment{
+		OwnerID:     ownerID,
+		StorageKey:  key,
+		FileName:    filepath.Base(fileName),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       width,
+		Height:      height,
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+	if err := s.DB.Create(&attachment).Error; err != nil {
+		s.Storage.Delete(ctx, key)
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (s *AttachmentService) GetAttachmentByID(id uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	if err := s.DB.First(&attachment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// GetPublishedAttachment returns an attachment only while it is linked to a post anyone
+// can see: not deleted or hidden, and for answers on a question that is neither. Nil
+// means there is no such attachment, so unlinked uploads are never served.
+func (s *AttachmentService) GetPublishedAttachment(id uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := s.DB.Where("question_id IN (SELECT id FROM questions WHERE deleted_at IS NULL AND hidden_at IS NULL) OR "+
+		"answer_id IN (SELECT answers.id FROM answers JOIN questions ON questions.id = answers.question_id "+
+		"WHERE answers.deleted_at IS NULL AND answers.hidden_at IS NULL AND questions.deleted_at IS NULL AND questions.hidden_at IS NULL)").
+		First(&attachment, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (s *AttachmentService) Open(ctx context.Context, attachment *models.Attachment) (io.ReadCloser, error) {
+	return s.Storage.Get(ctx, attachment.StorageKey)
+}
+
+// DeleteAttachment removes an attachment owned by the actor, blob included.
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, actor AuditActor, id uint) error {
+	attachment, err := s.GetAttachmentByID(id)
+	if err != nil {
+		return err
+	}
+	if attachment == nil {
+		return errors.New("attachment not found")
+	}
+	if attachment.OwnerID != *actor.UserID {
+		return errors.New("not authorized to delete this attachment")
+	}
+	if err := s.Storage.Delete(ctx, attachment.StorageKey); err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(attachment).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditAttachmentDeleted,
+			TargetType: AuditTargetAttachment,
+			TargetID:   &attachment.ID,
+			Details: map[string]interface{}{
+				"file_name":   attachment.FileName,
+				"sha256":      attachment.SHA256,
+				"question_id": attachment.QuestionID,
+				"answer_id":   attachment.AnswerID,
+			},
+		})
+	})
+}
+
+func (s *AttachmentService) purge(ctx context.Context, attachment *models.Attachment) error {
+	if err := s.Storage.Delete(ctx, attachment.StorageKey); err != nil {
+		return err
+	}
+	return s.DB.Unscoped().Delete(attachment).Error
+}
+
+// CollectOrphans deletes attachments that were never linked to a post within the TTL,
+// or whose post has since been deleted.
+func (s *AttachmentService) CollectOrphans(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-time.Duration(s.Config.OrphanAttachmentTTL) * time.Minute)
+
+	var orphans []models.Attachment
+	err := s.DB.Unscoped().
+		Where("(question_id IS NULL AND answer_id IS NULL AND created_at < ?) OR deleted_at IS NOT NULL", cutoff).
+		Or("question_id IS NOT NULL AND question_id NOT IN (SELECT id FROM questions WHERE deleted_at IS NULL)").
+		Or("answer_id IS NOT NULL AND answer_id NOT IN (SELECT id FROM answers WHERE deleted_at IS NULL)").
+		Limit(500).Find(&orphans).Error
+	if err != nil {
+		return 0, err
+	}
+
+	collected := 0
+	for i := range orphans {
+		if err := s.purge(ctx, &orphans[i]); err != nil {
+			log.Printf("Failed to collect orphan attachment %d: %v", orphans[i].ID, err)
+			continue
+		}
+		collected++
+	}
+	return collected, nil
+}
+
+// RunOrphanCollector calls CollectOrphans every interval until ctx is cancelled.
+func (s *AttachmentService) RunOrphanCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.CollectOrphans(ctx)
+			if err != nil {
+				log.Printf("Orphan attachment collection failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Collected %d orphan attachments", n)
+			}
+		}
+	}
+}
+
+// linkAttachments attaches the given unlinked uploads of ownerID to a question or answer.
+func linkAttachments(db *gorm.DB, ownerID uint, attachmentIDs []uint, questionID, answerID *uint) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+	unique := map[uint]bool{}
+	ids := []uint{}
+	for _, id := range attachmentIDs {
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	result := db.Model(&models.Attachment{}).
+		Where("id IN ? AND owner_id = ? AND question_id IS NULL AND answer_id IS NULL", ids, ownerID).
+		Updates(map[string]interface{}{"question_id": questionID, "answer_id": answerID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return ErrInvalidAttachments
+	}
+	return nil
+}
+
+func newStorageKey(ext string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("attachments/%s/%s%s", time.Now().UTC().Format("2006/01"), hex.EncodeToString(buf), ext), nil
+}
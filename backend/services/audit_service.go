@@ -0,0 +1,363 @@
+// services/audit_service.go
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+)
+
+// Audited actions
+const (
+	AuditLoginSucceeded     = "auth.login"
+	AuditLoginFailed        = "auth.login_failed"
+	AuditTokenIssued        = "auth.token_issued"
+	AuditUserRoleChanged    = "user.role_changed"
+	AuditUserSuspended      = "user.suspended"
+	AuditUserUnsuspended    = "user.unsuspended"
+	AuditUserBanned         = "user.banned"
+	AuditUserPasswordReset  = "user.password_reset"
+	AuditUserLoggedOut      = "user.forced_logout"
+	AuditUserMerged         = "user.merged"
+	AuditFlagsReviewed      = "moderation.flags_reviewed"
+	AuditQuestionLocked     = "moderation.question_locked"
+	AuditQuestionUnlocked   = "moderation.question_unlocked"
+	AuditQuestionClosed     = "moderation.question_closed"
+	AuditQuestionReopened   = "moderation.question_reopened"
+	AuditPostDeleted        = "moderation.post_deleted"
+	AuditTagMerged          = "moderation.tag_merged"
+	AuditTagSynonymAdded    = "moderation.tag_synonym_added"
+	AuditTagSynonymRemoved  = "moderation.tag_synonym_removed"
+	AuditPostReleased       = "moderation.post_released"
+	AuditContentRuleCreated = "content_rule.created"
+	AuditContentRuleDeleted = "content_rule.deleted"
+	AuditAttachmentDeleted  = "attachment.deleted"
+	AuditAdminRequest       = "admin.request"
+)
+
+const (
+	AuditTargetUser        = "user"
+	AuditTargetTag         = "tag"
+	AuditTargetContentRule = "content_rule"
+	AuditTargetAttachment  = "attachment"
+)
+
+// auditChainLock is the advisory lock key serializing appends to the hash chain, so
+// two transactions never link to the same previous entry.
+const auditChainLock = 4_911_017
+
+// AuditActor identifies who performed an audited action and from where. The zero
+// value is the system.
+type AuditActor struct {
+	UserID    *uint
+	IP        string
+	UserAgent string
+}
+
+// AuditChange is one field of an audit entry's diff.
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditEntry is what an action reports to the audit log. Diff holds the fields it
+// changed on the target; Details any other context worth keeping.
+type AuditEntry struct {
+	Action     string
+	TargetType string
+	TargetID   *uint
+	Diff       map[string]AuditChange
+	Details    map[string]interface{}
+}
+
+// AuditFilter narrows the audit log. Zero fields match everything; To is exclusive.
+type AuditFilter struct {
+	ActorID    *uint
+	Action     string
+	TargetType string
+	TargetID   *uint
+	From       *time.Time
+	To         *time.Time
+}
+
+// errChainBroken stops Verify at the first broken entry.
+var errChainBroken = errors.New("audit chain broken")
+
+// AuditVerification is the result of walking the hash chain.
+type AuditVerification struct {
+	Checked    int64
+	Valid      bool
+	BrokenAtID *uint
+	Reason     string
+}
+
+type AuditService struct {
+	DB *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{DB: db}
+}
+
+// recordAudit appends an audit entry. Call it on the transaction of the action so the
+// entry is written if and only if the action is, and as that transaction's last
+// statement: the chain lock it takes is held until commit, so everything after it
+// would be serialized with every other audited write, and any row lock taken after it
+// could deadlock against a transaction waiting for the chain lock while holding that row.
+func recordAudit(tx *gorm.DB, actor AuditActor, entry AuditEntry) error {
+	diff, err := canonicalAuditJSON(entry.Diff)
+	if err != nil {
+		return err
+	}
+	details, err := canonicalAuditJSON(entry.Details)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+		return err
+	}
+	var last []string
+	if err := tx.Model(&models.AuditLog{}).Order("id DESC").Limit(1).Pluck("hash", &last).Error; err != nil {
+		return err
+	}
+
+	auditLog := models.AuditLog{
+		ActorID:    actor.UserID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Diff:       diff,
+		Details:    details,
+		IP:         actor.IP,
+		UserAgent:  actor.UserAgent,
+		// Postgres keeps microseconds; the hash must cover what is read back
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if len(last) > 0 {
+		auditLog.PrevHash = last[0]
+	}
+	if auditLog.Hash, err = AuditHash(&auditLog); err != nil {
+		return err
+	}
+	return tx.Create(&auditLog).Error
+}
+
+// AuditHash is the chain hash of an entry: SHA-256 over its previous hash and every
+// recorded field except the ID. Diff and details are hashed in canonical form, since
+// jsonb does not preserve the text it was given.
+func AuditHash(l *models.AuditLog) (string, error) {
+	diff, err := canonicalAuditJSONText(l.Diff)
+	if err != nil {
+		return "", err
+	}
+	details, err := canonicalAuditJSONText(l.Details)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(struct {
+		PrevHash   string          `json:"prev_hash"`
+		ActorID    *uint           `json:"actor_id"`
+		Action     string          `json:"action"`
+		TargetType string          `json:"target_type"`
+		TargetID   *uint           `json:"target_id"`
+		Diff       json.RawMessage `json:"diff"`
+		Details    json.RawMessage `json:"details"`
+		IP         string          `json:"ip"`
+		UserAgent  string          `json:"user_agent"`
+		CreatedAt  string          `json:"created_at"`
+	}{
+		PrevHash:   l.PrevHash,
+		ActorID:    l.ActorID,
+		Action:     l.Action,
+		TargetType: l.TargetType,
+		TargetID:   l.TargetID,
+		Diff:       diff,
+		Details:    details,
+		IP:         l.IP,
+		UserAgent:  l.UserAgent,
+		CreatedAt:  l.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalAuditJSON encodes a diff or details map, with nil encoded as an empty object.
+func canonicalAuditJSON(v interface{}) (string, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	canonical, err := canonicalAuditJSONText(string(encoded))
+	return string(canonical), err
+}
+
+// canonicalAuditJSONText re-encodes JSON text with sorted keys and no whitespace.
+func canonicalAuditJSONText(text string) (json.RawMessage, error) {
+	var v interface{}
+	if text != "" {
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			return nil, err
+		}
+	}
+	if v == nil {
+		v = map[string]interface{}{}
+	}
+	return json.Marshal(v)
+}
+
+// SealAuditChain hashes entries written before the chain existed, oldest first.
+func SealAuditChain(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var entries []models.AuditLog
+		if err := tx.Order("id ASC").Find(&entries).Error; err != nil {
+			return err
+		}
+		prev := ""
+		for i := range entries {
+			entries[i].PrevHash = prev
+			hash, err := AuditHash(&entries[i])
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&entries[i]).UpdateColumns(map[string]interface{}{"prev_hash": prev, "hash": hash}).Error; err != nil {
+				return err
+			}
+			prev = hash
+		}
+		return nil
+	})
+}
+
+// Record appends an entry in a transaction of its own, for actions that have none,
+// such as logins.
+func (s *AuditService) Record(actor AuditActor, entry AuditEntry) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return recordAudit(tx, actor, entry)
+	})
+}
+
+// RecordRequest logs an admin API call. Failures are logged rather than returned, as
+// the call has already been served.
+func (s *AuditService) RecordRequest(actorID *uint, ip, userAgent, method, path, query string, status int) {
+	err := s.Record(AuditActor{UserID: actorID, IP: ip, UserAgent: userAgent}, AuditEntry{
+		Action:  AuditAdminRequest,
+		Details: map[string]interface{}{"method": method, "path": path, "query": query, "status": status},
+	})
+	if err != nil {
+		log.Printf("Failed to audit %s %s: %v", method, path, err)
+	}
+}
+
+func (s *AuditService) filtered(filter AuditFilter) *gorm.DB {
+	db := s.DB.Model(&models.AuditLog{})
+	if filter.ActorID != nil {
+		db = db.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		db = db.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != nil {
+		db = db.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", *filter.To)
+	}
+	return db
+}
+
+// Search returns matching entries, newest first.
+func (s *AuditService) Search(filter AuditFilter, page pagination.Params) ([]models.AuditLog, *pagination.Cursor, error) {
+	db := s.filtered(filter).Order("id DESC")
+	if page.After != nil {
+		db = db.Where("id < ?", page.After.ID)
+	}
+	var entries []models.AuditLog
+	if err := db.Limit(page.Limit + 1).Find(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+	entries, more := pagination.Trim(entries, page.Limit)
+	var next *pagination.Cursor
+	if more {
+		next = &pagination.Cursor{ID: entries[len(entries)-1].ID}
+	}
+	return entries, next, nil
+}
+
+func (s *AuditService) Count(filter AuditFilter) (int64, error) {
+	var count int64
+	err := s.filtered(filter).Count(&count).Error
+	return count, err
+}
+
+// Export passes matching entries to write in batches, oldest first.
+func (s *AuditService) Export(filter AuditFilter, write func([]models.AuditLog) error) error {
+	var entries []models.AuditLog
+	return s.filtered(filter).FindInBatches(&entries, 500, func(tx *gorm.DB, batch int) error {
+		return write(entries)
+	}).Error
+}
+
+// Verify walks the whole chain and reports the first entry whose hash does not match
+// its contents or whose link does not match the entry before it.
+func (s *AuditService) Verify() (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true}
+	prev := ""
+	var entries []models.AuditLog
+	err := s.DB.Model(&models.AuditLog{}).FindInBatches(&entries, 500, func(tx *gorm.DB, batch int) error {
+		next, broken, reason, err := verifyChain(prev, entries)
+		if err != nil {
+			return err
+		}
+		if broken < 0 {
+			result.Checked += int64(len(entries))
+			prev = next
+			return nil
+		}
+		result.Checked += int64(broken + 1)
+		result.Valid, result.BrokenAtID, result.Reason = false, &entries[broken].ID, reason
+		return errChainBroken
+	}).Error
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// verifyChain checks consecutive entries, the first of which must link to prev. It
+// returns the hash the entry after them must link to, or the index of the first broken
+// entry and why, with -1 when none is. An edited entry fails the hash check; a removed
+// or reordered one the link check.
+func verifyChain(prev string, entries []models.AuditLog) (string, int, string, error) {
+	for i := range entries {
+		hash, err := AuditHash(&entries[i])
+		if err != nil {
+			return "", 0, "", err
+		}
+		switch {
+		case entries[i].PrevHash != prev:
+			return "", i, "entry does not link to the previous entry", nil
+		case entries[i].Hash != hash:
+			return "", i, "entry hash does not match its contents", nil
+		}
+		prev = entries[i].Hash
+	}
+	return prev, -1, "", nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"stackit/models"
+)
+
+// auditChain returns n correctly linked entries.
+func auditChain(t *testing.T, n int) []models.AuditLog {
+	t.Helper()
+	actor := uint(1)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := make([]models.AuditLog, n)
+	prev := ""
+	for i := range entries {
+		target := uint(100 + i)
+		entries[i] = models.AuditLog{
+			ID:         uint(i + 1),
+			ActorID:    &actor,
+			Action:     AuditUserSuspended,
+			TargetType: AuditTargetUser,
+			TargetID:   &target,
+			Diff:       `{"suspended_until": {"from": null, "to": "2024-06-01"}}`,
+			Details:    `{"reason": "spam"}`,
+			IP:         "203.0.113.7",
+			UserAgent:  "curl/8.0",
+			PrevHash:   prev,
+			CreatedAt:  created.Add(time.Duration(i) * time.Minute),
+		}
+		hash, err := AuditHash(&entries[i])
+		if err != nil {
+			t.Fatalf("AuditHash: %v", err)
+		}
+		entries[i].Hash = hash
+		prev = hash
+	}
+	return entries
+}
+
+func TestAuditHash(t *testing.T) {
+	base := auditChain(t, 1)[0]
+	tests := []struct {
+		name   string
+		modify func(l *models.AuditLog)
+		same   bool
+	}{
+		{"ID is not covered", func(l *models.AuditLog) { l.ID = 99 }, true},
+		{"diff key order and whitespace", func(l *models.AuditLog) {
+			l.Diff = `{"suspended_until":{"to":"2024-06-01","from":null}}`
+		}, true},
+		{"sub-microsecond time", func(l *models.AuditLog) { l.CreatedAt = l.CreatedAt.Add(300 * time.Nanosecond) }, true},
+		{"time zone", func(l *models.AuditLog) { l.CreatedAt = l.CreatedAt.In(time.FixedZone("UTC+2", 2*60*60)) }, true},
+		{"details removed", func(l *models.AuditLog) { l.Details = "" }, false},
+		{"action", func(l *models.AuditLog) { l.Action = AuditUserBanned }, false},
+		{"actor", func(l *models.AuditLog) { l.ActorID = nil }, false},
+		{"target", func(l *models.AuditLog) { other := uint(7); l.TargetID = &other }, false},
+		{"diff value", func(l *models.AuditLog) {
+			l.Diff = `{"suspended_until": {"from": null, "to": "2024-07-01"}}`
+		}, false},
+		{"details", func(l *models.AuditLog) { l.Details = `{"reason": "abuse"}` }, false},
+		{"ip", func(l *models.AuditLog) { l.IP = "198.51.100.1" }, false},
+		{"user agent", func(l *models.AuditLog) { l.UserAgent = "" }, false},
+		{"previous hash", func(l *models.AuditLog) { l.PrevHash = "00" }, false},
+		{"time", func(l *models.AuditLog) { l.CreatedAt = l.CreatedAt.Add(time.Microsecond) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := base
+			tt.modify(&entry)
+			hash, err := AuditHash(&entry)
+			if err != nil {
+				t.Fatalf("AuditHash: %v", err)
+			}
+			if same := hash == base.Hash; same != tt.same {
+				t.Errorf("hash unchanged = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestAuditHashInvalidJSON(t *testing.T) {
+	entry := auditChain(t, 1)[0]
+	entry.Diff = `{"status":`
+	if _, err := AuditHash(&entry); err == nil {
+		t.Error("AuditHash succeeded on invalid diff JSON")
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(entries []models.AuditLog) []models.AuditLog
+		wantBroken int
+		wantReason string
+	}{
+		{
+			name:       "intact",
+			tamper:     func(entries []models.AuditLog) []models.AuditLog { return entries },
+			wantBroken: -1,
+		},
+		{
+			name: "edited entry",
+			tamper: func(entries []models.AuditLog) []models.AuditLog {
+				entries[2].Details = `{"reason": "none"}`
+				return entries
+			},
+			wantBroken: 2,
+			wantReason: "entry hash does not match its contents",
+		},
+		{
+			name: "edited entry with its hash recomputed",
+			tamper: func(entries []models.AuditLog) []models.AuditLog {
+				entries[1].IP = "198.51.100.1"
+				entries[1].Hash, _ = AuditHash(&entries[1])
+				return entries
+			},
+			wantBroken: 2,
+			wantReason: "entry does not link to the previous entry",
+		},
+		{
+			name: "removed entry",
+			tamper: func(entries []models.AuditLog) []models.AuditLog {
+				return append(entries[:1], entries[2:]...)
+			},
+			wantBroken: 1,
+			wantReason: "entry does not link to the previous entry",
+		},
+		{
+			name: "removed first entry",
+			tamper: func(entries []models.AuditLog) []models.AuditLog {
+				return entries[1:]
+			},
+			wantBroken: 0,
+			wantReason: "entry does not link to the previous entry",
+		},
+		{
+			name: "reordered entries",
+			tamper: func(entries []models.AuditLog) []models.AuditLog {
+				entries[2], entries[3] = entries[3], entries[2]
+				return entries
+			},
+			wantBroken: 2,
+			wantReason: "entry does not link to the previous entry",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := tt.tamper(auditChain(t, 5))
+			next, broken, reason, err := verifyChain("", entries)
+			if err != nil {
+				t.Fatalf("verifyChain: %v", err)
+			}
+			if broken != tt.wantBroken || reason != tt.wantReason {
+				t.Errorf("verifyChain = %d %q, want %d %q", broken, reason, tt.wantBroken, tt.wantReason)
+			}
+			if broken < 0 && next != entries[len(entries)-1].Hash {
+				t.Errorf("next = %q, want the last entry's hash", next)
+			}
+		})
+	}
+}
+
+func TestVerifyChainAcrossBatches(t *testing.T) {
+	entries := auditChain(t, 6)
+	next, broken, _, err := verifyChain("", entries[:3])
+	if err != nil || broken >= 0 {
+		t.Fatalf("first batch: broken %d, err %v", broken, err)
+	}
+	if _, broken, _, err = verifyChain(next, entries[3:]); err != nil || broken >= 0 {
+		t.Errorf("second batch: broken %d, err %v", broken, err)
+	}
+	if _, broken, _, _ = verifyChain("", entries[3:]); broken != 0 {
+		t.Errorf("second batch without the first's hash: broken %d, want 0", broken)
+	}
+}
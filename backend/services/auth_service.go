@@ -55,5 +55,9 @@ func (s *AuthService) AuthenticateUser(username, password string) (*models.User,
 	if !utils.CheckPasswordHash(password, user.HashedPassword) {
 		return nil, errors.New("invalid username or password")
 	}
+	// Checked after the password so restrictions are only revealed to the account holder
+	if err := accountRestriction(user.BannedAt != nil, user.SuspendedUntil); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
@@ -0,0 +1,186 @@
+// services/avatar_service.go
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"stackit/cache"
+	"stackit/config"
+	"stackit/models"
+	"stackit/storage"
+	"stackit/utils"
+
+	"gorm.io/gorm"
+)
+
+// Avatar is an encoded avatar image and the ETag it is served with.
+type Avatar struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+	Fallback    bool // An identicon standing in for an upload that could not be read
+}
+
+type AvatarService struct {
+	DB      *gorm.DB
+	Storage storage.Storage
+	Config  *config.Config
+
+	identicons *cache.TTL[string, []byte]
+}
+
+func NewAvatarService(db *gorm.DB, store storage.Storage, cfg *config.Config) *AvatarService {
+	return &AvatarService{
+		DB:         db,
+		Storage:    store,
+		Config:     cfg,
+		identicons: cache.NewTTL[string, []byte](24*time.Hour, 10000),
+	}
+}
+
+// Upload crops the image to a square (the centred square when crop is empty), stores
+// it as PNG at every size in utils.AvatarSizes and makes it the user's avatar.
+func (s *AvatarService) Upload(ctx context.Context, userID uint, r io.Reader, crop image.Rectangle) (*models.User, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.Config.MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.Config.MaxUploadBytes {
+		return nil, ErrFileTooLarge
+	}
+
+	// Check dimensions before decoding so oversized images are never expanded in memory
+	width, height, err := utils.ImageDimensions(data)
+	if err != nil {
+		return nil, ErrUnsupportedFile
+	}
+	if width > s.Config.MaxImageDimension || height > s.Config.MaxImageDimension {
+		return nil, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFile
+	}
+	square := utils.CropSquare(img, crop)
+
+	version := make([]byte, 8)
+	if _, err := rand.Read(version); err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("avatars/%d/%s", userID, hex.EncodeToString(version))
+	for _, size := range utils.AvatarSizes {
+		encoded, err := utils.EncodePNG(utils.ResizeSquare(square, size))
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Storage.Put(ctx, avatarKey(prefix, size), bytes.NewReader(encoded), int64(len(encoded)), "image/png"); err != nil {
+			s.deleteBlobs(ctx, prefix)
+			return nil, fmt.Errorf("failed to store avatar: %w", err)
+		}
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		s.deleteBlobs(ctx, prefix)
+		return nil, err
+	}
+	previous := user.AvatarKey
+	// An upload replaces any external avatar URL
+	if err := s.DB.Model(&user).Updates(map[string]interface{}{"avatar_key": prefix, "avatar_url": ""}).Error; err != nil {
+		s.deleteBlobs(ctx, prefix)
+		return nil, err
+	}
+	if previous != "" {
+		s.deleteBlobs(ctx, previous)
+	}
+	return &user, nil
+}
+
+// Remove deletes the user's uploaded avatar so the identicon is served again.
+func (s *AvatarService) Remove(ctx context.Context, userID uint) error {
+	var user models.User
+	if err := s.DB.Select("id", "avatar_key").First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.AvatarKey == "" {
+		return nil
+	}
+	if err := s.DB.Model(&user).Update("avatar_key", "").Error; err != nil {
+		return err
+	}
+	s.deleteBlobs(ctx, user.AvatarKey)
+	return nil
+}
+
+// Get returns the user's avatar at the stored size closest to the requested one: the
+// upload when there is one, otherwise their identicon.
+func (s *AvatarService) Get(ctx context.Context, user *models.User, requested int) (*Avatar, error) {
+	size := utils.AvatarSize(requested)
+	if user.AvatarKey != "" {
+		blob, err := s.Storage.Get(ctx, avatarKey(user.AvatarKey, size))
+		if err == nil {
+			defer blob.Close()
+			data, err := io.ReadAll(blob)
+			if err != nil {
+				return nil, err
+			}
+			return &Avatar{Data: data, ContentType: "image/png", ETag: avatarETag(user.AvatarKey, size)}, nil
+		}
+		// Fall back to the identicon if the blob went missing
+		log.Printf("Avatar blob for user %d unavailable: %v", user.ID, err)
+	}
+
+	seed := identiconSeed(user)
+	data, err := s.identicons.GetOrLoad(fmt.Sprintf("%s:%d", seed, size), func() ([]byte, error) {
+		return utils.EncodePNG(utils.Identicon(seed, size))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Avatar{
+		Data:        data,
+		ContentType: "image/png",
+		ETag:        avatarETag("identicon:"+seed, size),
+		Fallback:    user.AvatarKey != "",
+	}, nil
+}
+
+// AvatarVersion identifies the user's current avatar, for cache-busting avatar URLs.
+func AvatarVersion(user *models.User) string {
+	if user.AvatarKey == "" {
+		return "identicon"
+	}
+	return user.AvatarKey[strings.LastIndex(user.AvatarKey, "/")+1:]
+}
+
+func (s *AvatarService) deleteBlobs(ctx context.Context, prefix string) {
+	for _, size := range utils.AvatarSizes {
+		if err := s.Storage.Delete(ctx, avatarKey(prefix, size)); err != nil {
+			log.Printf("Failed to delete avatar blob %s: %v", avatarKey(prefix, size), err)
+		}
+	}
+}
+
+func avatarKey(prefix string, size int) string {
+	return fmt.Sprintf("%s/%d.png", prefix, size)
+}
+
+func avatarETag(source string, size int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", source, size)))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// identiconSeed keys the identicon on the immutable user ID rather than the username.
+func identiconSeed(user *models.User) string {
+	return fmt.Sprintf("user:%d", user.ID)
+}
@@ -0,0 +1,300 @@
+// services/badge_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+)
+
+// Badge tiers
+const (
+	BadgeBronze = "bronze"
+	BadgeSilver = "silver"
+	BadgeGold   = "gold"
+)
+
+// Domain events that trigger badge evaluation for the users involved
+const (
+	BadgeTriggerQuestionPosted = "question_posted"
+	BadgeTriggerAnswerVoted    = "answer_voted"
+	BadgeTriggerAnswerAccepted = "answer_accepted"
+)
+
+const NotificationKindBadgeAwarded = "badge_awarded"
+
+var ErrBadgeNotFound = errors.New("badge not found")
+
+// BadgeDefinition declares a badge and the rule that earns it. Query selects
+// (user_id, post_id) rows for everyone who currently qualifies; post_id is 0 for
+// badges not tied to a post. A badge with a PostType is earned once per post,
+// otherwise once per user. Triggers lists the events that re-evaluate it; every
+// badge is also checked by the periodic sweep.
+type BadgeDefinition struct {
+	Slug        string
+	Name        string
+	Description string
+	Tier        string
+	PostType    string // "question", "answer" or empty
+	Triggers    []string
+	Query       string
+}
+
+func answerScoreBadge(slug, name, tier string, score int) BadgeDefinition {
+	return BadgeDefinition{
+		Slug: slug, Name: name, Tier: tier, PostType: FollowTargetAnswer,
+		Description: fmt.Sprintf("Answer score of %d or more", score),
+		Triggers:    []string{BadgeTriggerAnswerVoted},
+		Query:       fmt.Sprintf(`SELECT owner_id AS user_id, id AS post_id FROM answers WHERE score >= %d AND deleted_at IS NULL`, score),
+	}
+}
+
+func questionViewsBadge(slug, name, tier string, views int) BadgeDefinition {
+	return BadgeDefinition{
+		Slug: slug, Name: name, Tier: tier, PostType: FollowTargetQuestion,
+		Description: fmt.Sprintf("Asked a question with %d views", views),
+		Query:       fmt.Sprintf(`SELECT owner_id AS user_id, id AS post_id FROM questions WHERE view_count >= %d AND deleted_at IS NULL`, views),
+	}
+}
+
+func daysVisitedBadge(slug, name, tier string, days int) BadgeDefinition {
+	return BadgeDefinition{
+		Slug: slug, Name: name, Tier: tier,
+		Description: fmt.Sprintf("Visited the site on %d different days", days),
+		Query:       fmt.Sprintf(`SELECT user_id, 0 AS post_id FROM user_visits GROUP BY user_id HAVING COUNT(*) >= %d`, days),
+	}
+}
+
+// BadgeCatalogue lists every badge, in display order.
+var BadgeCatalogue = []BadgeDefinition{
+	{
+		Slug: "student", Name: "Student", Tier: BadgeBronze,
+		Description: "Asked a first question",
+		Triggers:    []string{BadgeTriggerQuestionPosted},
+		Query:       `SELECT DISTINCT owner_id AS user_id, 0 AS post_id FROM questions WHERE deleted_at IS NULL`,
+	},
+	{
+		Slug: "teacher", Name: "Teacher", Tier: BadgeBronze,
+		Description: "First accepted answer",
+		Triggers:    []string{BadgeTriggerAnswerAccepted},
+		Query:       `SELECT DISTINCT owner_id AS user_id, 0 AS post_id FROM answers WHERE is_accepted AND deleted_at IS NULL`,
+	},
+	answerScoreBadge("nice-answer", "Nice Answer", BadgeBronze, 10),
+	answerScoreBadge("good-answer", "Good Answer", BadgeSilver, 25),
+	answerScoreBadge("great-answer", "Great Answer", BadgeGold, 100),
+	questionViewsBadge("popular-question", "Popular Question", BadgeBronze, 1000),
+	questionViewsBadge("notable-question", "Notable Question", BadgeSilver, 2500),
+	questionViewsBadge("famous-question", "Famous Question", BadgeGold, 10000),
+	daysVisitedBadge("enthusiast", "Enthusiast", BadgeSilver, 30),
+	daysVisitedBadge("fanatic", "Fanatic", BadgeGold, 100),
+}
+
+// FindBadge looks a badge up by slug.
+func FindBadge(slug string) (BadgeDefinition, bool) {
+	for _, b := range BadgeCatalogue {
+		if b.Slug == slug {
+			return b, true
+		}
+	}
+	return BadgeDefinition{}, false
+}
+
+type BadgeService struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewBadgeService(db *gorm.DB, cfg *config.Config) *BadgeService {
+	return &BadgeService{DB: db, Config: cfg}
+}
+
+// Evaluate checks the badges triggered by the event for the given users and awards
+// any newly earned ones.
+func (s *BadgeService) Evaluate(trigger string, userIDs ...uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	for _, badge := range BadgeCatalogue {
+		if !containsString(badge.Triggers, trigger) {
+			continue
+		}
+		if _, err := s.award(badge, userIDs); err != nil {
+			return fmt.Errorf("badge %s: %w", badge.Slug, err)
+		}
+	}
+	return nil
+}
+
+// EvaluateAsync runs Evaluate in the background so it never delays a response.
+func (s *BadgeService) EvaluateAsync(trigger string, userIDs ...uint) {
+	go func() {
+		if err := s.Evaluate(trigger, userIDs...); err != nil {
+			log.Printf("Badge evaluation for %s failed: %v", trigger, err)
+		}
+	}()
+}
+
+// Sweep checks every badge for every user and returns the number of new awards.
+func (s *BadgeService) Sweep() (int, error) {
+	total := 0
+	for _, badge := range BadgeCatalogue {
+		n, err := s.award(badge, nil)
+		if err != nil {
+			return total, fmt.Errorf("badge %s: %w", badge.Slug, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// RunSweeper sweeps every BadgeSweepMinutes until ctx is cancelled.
+func (s *BadgeService) RunSweeper(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.Config.BadgeSweepMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		if n, err := s.Sweep(); err != nil {
+			log.Printf("Badge sweep failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Badge sweep awarded %d badges", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// award inserts the badge for everyone who qualifies and has not got it yet, limited
+// to userIDs when given, and notifies the new holders.
+func (s *BadgeService) award(badge BadgeDefinition, userIDs []uint) (int, error) {
+	filter, vars := "", []interface{}{badge.Slug, badge.PostType}
+	if userIDs != nil {
+		filter, vars = "WHERE c.user_id IN ?", append(vars, userIDs)
+	}
+
+	var awarded []models.BadgeAward
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(`INSERT INTO badge_awards (user_id, badge, post_type, post_id, created_at)
+			SELECT c.user_id, ?, ?, c.post_id, NOW() FROM (`+badge.Query+`) c `+filter+`
+			ON CONFLICT DO NOTHING
+			RETURNING id, user_id, badge, post_type, post_id, created_at`, vars...).
+			Scan(&awarded).Error; err != nil {
+			return err
+		}
+		if len(awarded) == 0 {
+			return nil
+		}
+
+		notifications := make([]models.Notification, 0, len(awarded))
+		for _, a := range awarded {
+			notification := models.Notification{
+				UserID:  a.UserID,
+				Message: fmt.Sprintf("You earned the %s badge (%s): %s", badge.Name, badge.Tier, badge.Description),
+				Kind:    NotificationKindBadgeAwarded,
+			}
+			if a.PostType == FollowTargetQuestion {
+				questionID := a.PostID
+				notification.QuestionID = &questionID
+			}
+			notifications = append(notifications, notification)
+		}
+		return tx.CreateInBatches(notifications, 500).Error
+	})
+	return len(awarded), err
+}
+
+// CountHolders returns how many times each badge has been awarded.
+func (s *BadgeService) CountHolders() (map[string]int64, error) {
+	var rows []struct {
+		Badge string
+		Count int64
+	}
+	if err := s.DB.Model(&models.BadgeAward{}).Select("badge, COUNT(*) AS count").
+		Group("badge").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, r := range rows {
+		counts[r.Badge] = r.Count
+	}
+	return counts, nil
+}
+
+// CountUserBadgesByTier returns how many badges of each tier the user holds.
+func (s *BadgeService) CountUserBadgesByTier(userID uint) (map[string]int64, error) {
+	var rows []struct {
+		Badge string
+		Count int64
+	}
+	if err := s.DB.Model(&models.BadgeAward{}).Select("badge, COUNT(*) AS count").
+		Where("user_id = ?", userID).Group("badge").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	tiers := map[string]int64{}
+	for _, r := range rows {
+		if badge, ok := FindBadge(r.Badge); ok {
+			tiers[badge.Tier] += r.Count
+		}
+	}
+	return tiers, nil
+}
+
+// GetHolders returns a page of the awards of a badge, newest first.
+func (s *BadgeService) GetHolders(slug string, page pagination.Params) ([]models.BadgeAward, *pagination.Cursor, error) {
+	if _, ok := FindBadge(slug); !ok {
+		return nil, nil, ErrBadgeNotFound
+	}
+	return s.awards(s.DB.Where("badge = ?", slug), page)
+}
+
+func (s *BadgeService) CountBadgeHolders(slug string) (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.BadgeAward{}).Where("badge = ?", slug).Count(&count).Error
+	return count, err
+}
+
+// GetUserBadges returns a page of a user's awards, newest first.
+func (s *BadgeService) GetUserBadges(userID uint, page pagination.Params) ([]models.BadgeAward, *pagination.Cursor, error) {
+	return s.awards(s.DB.Where("user_id = ?", userID), page)
+}
+
+func (s *BadgeService) CountUserBadges(userID uint) (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.BadgeAward{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (s *BadgeService) awards(db *gorm.DB, page pagination.Params) ([]models.BadgeAward, *pagination.Cursor, error) {
+	db = db.Preload("User").Order("id DESC")
+	if page.After != nil {
+		db = db.Where("id < ?", page.After.ID)
+	}
+
+	var awards []models.BadgeAward
+	if err := db.Limit(page.Limit + 1).Find(&awards).Error; err != nil {
+		return nil, nil, err
+	}
+	awards, hasMore := pagination.Trim(awards, page.Limit)
+	if !hasMore {
+		return awards, nil, nil
+	}
+	return awards, &pagination.Cursor{ID: awards[len(awards)-1].ID}, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+// services/bookmark_service.go
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBookmarkNotFound     = errors.New("bookmark not found")
+	ErrBookmarkListNotFound = errors.New("bookmark list not found")
+	ErrBookmarkListExists   = errors.New("a bookmark list with this name already exists")
+)
+
+// Sort modes accepted by GetBookmarks
+const (
+	BookmarkSortNewest   = "newest" // Most recently saved first
+	BookmarkSortOldest   = "oldest"
+	BookmarkSortActivity = "activity" // Question last activity
+	BookmarkSortVotes    = "votes"    // Question score
+)
+
+// BookmarkFilter narrows GetBookmarks to one list (ListID), or to bookmarks outside
+// any list (Unlisted). Both zero means all bookmarks.
+type BookmarkFilter struct {
+	Sort     string
+	ListID   *uint
+	Unlisted bool
+}
+
+// BookmarkListWithCount is a list with the number of bookmarks filed in it.
+type BookmarkListWithCount struct {
+	models.BookmarkList
+	BookmarkCount int64
+}
+
+type BookmarkService struct {
+	DB *gorm.DB
+}
+
+func NewBookmarkService(db *gorm.DB) *BookmarkService {
+	return &BookmarkService{DB: db}
+}
+
+// SaveBookmark bookmarks a question, or updates the list and note of an existing bookmark.
+func (s *BookmarkService) SaveBookmark(userID, questionID uint, listID *uint, note string) (*models.Bookmark, error) {
+	var count int64
+	if err := s.DB.Model(&models.Question{}).Where("id = ?", questionID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrQuestionNotFound
+	}
+	if listID != nil {
+		if _, err := s.getList(userID, *listID); err != nil {
+			return nil, err
+		}
+	}
+
+	bookmark := models.Bookmark{UserID: userID, QuestionID: questionID, ListID: listID, Note: note}
+	err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "question_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"list_id", "note", "updated_at"}),
+	}).Create(&bookmark).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBookmark(userID, questionID)
+}
+
+func (s *BookmarkService) GetBookmark(userID, questionID uint) (*models.Bookmark, error) {
+	var bookmark models.Bookmark
+	err := s.DB.Preload("Question.Tags.Tag").
+		Where("user_id = ? AND question_id = ?", userID, questionID).First(&bookmark).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookmarkNotFound
+		}
+		return nil, err
+	}
+	return &bookmark, nil
+}
+
+func (s *BookmarkService) DeleteBookmark(userID, questionID uint) error {
+	result := s.DB.Where("user_id = ? AND question_id = ?", userID, questionID).Delete(&models.Bookmark{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBookmarkNotFound
+	}
+	return nil
+}
+
+// bookmarks joins the user's bookmarks to their live questions. Questions hidden since
+// they were bookmarked are left out, as in question lists.
+func (s *BookmarkService) bookmarks(userID uint, filter BookmarkFilter) *gorm.DB {
+	db := s.DB.Model(&models.Bookmark{}).
+		Joins("JOIN questions q ON q.id = bookmarks.question_id AND q.deleted_at IS NULL AND q.hidden_at IS NULL").
+		Where("bookmarks.user_id = ?", userID)
+	if filter.ListID != nil {
+		db = db.Where("bookmarks.list_id = ?", *filter.ListID)
+	} else if filter.Unlisted {
+		db = db.Where("bookmarks.list_id IS NULL")
+	}
+	return db
+}
+
+// GetBookmarks returns one page of the user's bookmarks; every ordering is keyset-paginated
+// with the bookmark ID as tie-breaker.
+func (s *BookmarkService) GetBookmarks(userID uint, filter BookmarkFilter, page pagination.Params) ([]models.Bookmark, *pagination.Cursor, error) {
+	if filter.Sort == "" {
+		filter.Sort = BookmarkSortNewest
+	}
+	if err := page.CheckSort(filter.Sort); err != nil {
+		return nil, nil, err
+	}
+
+	db := s.bookmarks(userID, filter).Preload("Question.Tags.Tag")
+	after := page.After
+	switch filter.Sort {
+	case BookmarkSortOldest:
+		db = db.Order("bookmarks.id ASC")
+		if after != nil {
+			db = db.Where("bookmarks.id > ?", after.ID)
+		}
+	case BookmarkSortActivity:
+		db = db.Order("q.last_activity_at DESC, bookmarks.id DESC")
+		if after != nil {
+			db = db.Where("(q.last_activity_at, bookmarks.id) < (?, ?)", after.Time, after.ID)
+		}
+	case BookmarkSortVotes:
+		db = db.Order("q.score DESC, bookmarks.id DESC")
+		if after != nil {
+			db = db.Where("(q.score, bookmarks.id) < (?, ?)", after.Int, after.ID)
+		}
+	default:
+		db = db.Order("bookmarks.id DESC")
+		if after != nil {
+			db = db.Where("bookmarks.id < ?", after.ID)
+		}
+	}
+
+	var bookmarks []models.Bookmark
+	if err := db.Limit(page.Limit + 1).Find(&bookmarks).Error; err != nil {
+		return nil, nil, err
+	}
+	bookmarks, hasMore := pagination.Trim(bookmarks, page.Limit)
+	if !hasMore {
+		return bookmarks, nil, nil
+	}
+	last := bookmarks[len(bookmarks)-1]
+	return bookmarks, &pagination.Cursor{
+		Sort: filter.Sort,
+		ID:   last.ID,
+		Time: last.Question.LastActivityAt,
+		Int:  int64(last.Question.Score),
+	}, nil
+}
+
+func (s *BookmarkService) CountBookmarks(userID uint, filter BookmarkFilter) (int64, error) {
+	var count int64
+	err := s.bookmarks(userID, filter).Count(&count).Error
+	return count, err
+}
+
+// BookmarkedQuestionIDs reports which of the given questions the user has bookmarked.
+func (s *BookmarkService) BookmarkedQuestionIDs(userID uint, questionIDs []uint) (map[uint]bool, error) {
+	bookmarked := map[uint]bool{}
+	if len(questionIDs) == 0 {
+		return bookmarked, nil
+	}
+	var ids []uint
+	if err := s.DB.Model(&models.Bookmark{}).
+		Where("user_id = ? AND question_id IN ?", userID, questionIDs).
+		Pluck("question_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		bookmarked[id] = true
+	}
+	return bookmarked, nil
+}
+
+func (s *BookmarkService) GetLists(userID uint) ([]BookmarkListWithCount, error) {
+	var lists []BookmarkListWithCount
+	err := s.DB.Model(&models.BookmarkList{}).
+		Select("bookmark_lists.*, (SELECT COUNT(*) FROM bookmarks b WHERE b.list_id = bookmark_lists.id) AS bookmark_count").
+		Where("user_id = ?", userID).Order("name ASC").Scan(&lists).Error
+	return lists, err
+}
+
+func (s *BookmarkService) getList(userID, listID uint) (*models.BookmarkList, error) {
+	var list models.BookmarkList
+	if err := s.DB.Where("id = ? AND user_id = ?", listID, userID).First(&list).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookmarkListNotFound
+		}
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (s *BookmarkService) CreateList(userID uint, name string) (*models.BookmarkList, error) {
+	list := models.BookmarkList{UserID: userID, Name: strings.TrimSpace(name)}
+	result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&list)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrBookmarkListExists
+	}
+	return &list, nil
+}
+
+func (s *BookmarkService) RenameList(userID, listID uint, name string) (*models.BookmarkList, error) {
+	list, err := s.getList(userID, listID)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	var count int64
+	if err := s.DB.Model(&models.BookmarkList{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userID, name, listID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrBookmarkListExists
+	}
+	if err := s.DB.Model(list).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteList removes a list. Its bookmarks are kept and become unlisted.
+func (s *BookmarkService) DeleteList(userID, listID uint) error {
+	list, err := s.getList(userID, listID)
+	if err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Bookmark{}).Where("list_id = ?", list.ID).
+			Update("list_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(list).Error
+	})
+}
@@ -0,0 +1,327 @@
+// services/bounty_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bounty statuses
+const (
+	BountyStatusActive   = "active"
+	BountyStatusAwarded  = "awarded"
+	BountyStatusExpired  = "expired"  // Ran out with no qualifying answer
+	BountyStatusRefunded = "refunded" // Question closed or removed while the bounty ran
+)
+
+const NotificationKindBountyAwarded = "bounty_awarded"
+
+var (
+	ErrBountyNotFound         = errors.New("bounty not found")
+	ErrBountyNotActive        = errors.New("bounty is no longer active")
+	ErrBountyAlreadyActive    = errors.New("question already has an active bounty")
+	ErrBountyAmount           = errors.New("bounty amount is outside the allowed range")
+	ErrBountyDuration         = errors.New("bounty duration is outside the allowed range")
+	ErrNotBountyOwner         = errors.New("only the user who offered the bounty can award it")
+	ErrBountyAnswerInvalid    = errors.New("answer must be a visible answer to the bounty's question written by another user")
+	ErrInsufficientReputation = errors.New("not enough reputation to offer this bounty")
+)
+
+type BountyService struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewBountyService(db *gorm.DB, cfg *config.Config) *BountyService {
+	return &BountyService{DB: db, Config: cfg}
+}
+
+// StartBounty charges the amount to the user and features the question for the given
+// number of days. The user must keep at least BaseReputation after the charge.
+func (s *BountyService) StartBounty(userID, questionID uint, amount int, reason string, days int) (*models.Bounty, error) {
+	if amount < s.Config.BountyMinAmount || amount > s.Config.BountyMaxAmount {
+		return nil, ErrBountyAmount
+	}
+	if days < 1 || days > s.Config.BountyMaxDays {
+		return nil, ErrBountyDuration
+	}
+
+	var bounty models.Bounty
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var question models.Question
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status", "hidden_at").
+			First(&question, questionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrQuestionNotFound
+			}
+			return err
+		}
+		if question.HiddenAt != nil {
+			return ErrQuestionNotFound
+		}
+		if question.Status != QuestionStatusOpen {
+			return ErrQuestionNotOpen
+		}
+
+		var active int64
+		if err := tx.Model(&models.Bounty{}).
+			Where("question_id = ? AND status = ?", questionID, BountyStatusActive).Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrBountyAlreadyActive
+		}
+
+		// Lock the offering user so concurrent bounties cannot overspend
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "reputation").
+			First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.Reputation-amount < BaseReputation {
+			return ErrInsufficientReputation
+		}
+
+		bounty = models.Bounty{
+			QuestionID: questionID,
+			OwnerID:    userID,
+			Amount:     amount,
+			Reason:     reason,
+			Status:     BountyStatusActive,
+			ExpiresAt:  time.Now().AddDate(0, 0, days),
+		}
+		if err := tx.Create(&bounty).Error; err != nil {
+			return err
+		}
+		rep := &ReputationService{DB: tx, Config: s.Config}
+		return rep.RecordAmount(userID, RepBountyOffered, -amount, ReputationRef{QuestionID: &questionID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &bounty, nil
+}
+
+// AwardBounty lets the user who offered the bounty give it to a visible answer while
+// the question is still open; the bounty of a closed question is refunded instead.
+func (s *BountyService) AwardBounty(userID, bountyID, answerID uint) (*models.Bounty, error) {
+	var bounty models.Bounty
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bounty, bountyID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBountyNotFound
+			}
+			return err
+		}
+		if bounty.OwnerID != userID {
+			return ErrNotBountyOwner
+		}
+		if bounty.Status != BountyStatusActive {
+			return ErrBountyNotActive
+		}
+		var question models.Question
+		if err := tx.Select("id", "status").First(&question, bounty.QuestionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrQuestionNotOpen
+			}
+			return err
+		}
+		if question.Status != QuestionStatusOpen {
+			return ErrQuestionNotOpen
+		}
+
+		var answer models.Answer
+		if err := tx.Select("id", "owner_id", "question_id", "hidden_at").First(&answer, answerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBountyAnswerInvalid
+			}
+			return err
+		}
+		if answer.QuestionID != bounty.QuestionID || answer.OwnerID == bounty.OwnerID || answer.HiddenAt != nil {
+			return ErrBountyAnswerInvalid
+		}
+		return s.award(tx, &bounty, &answer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &bounty, nil
+}
+
+// GetActiveBounty returns the question's running bounty, or nil.
+func (s *BountyService) GetActiveBounty(questionID uint) (*models.Bounty, error) {
+	var bounty models.Bounty
+	err := s.DB.Where("question_id = ? AND status = ?", questionID, BountyStatusActive).First(&bounty).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &bounty, nil
+}
+
+// RefundQuestionBounties refunds the running bounty of a question that was closed or
+// removed.
+func (s *BountyService) RefundQuestionBounties(questionID uint) error {
+	var ids []uint
+	if err := s.DB.Model(&models.Bounty{}).
+		Where("question_id = ? AND status = ?", questionID, BountyStatusActive).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.settle(id, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProcessExpired settles every bounty that ran out, and refunds those whose question
+// is no longer open. It returns the number of bounties settled.
+func (s *BountyService) ProcessExpired() (int, error) {
+	var ids []uint
+	if err := s.DB.Model(&models.Bounty{}).
+		Joins("LEFT JOIN questions q ON q.id = bounties.question_id").
+		Where("bounties.status = ?", BountyStatusActive).
+		Where("bounties.expires_at <= ? OR q.id IS NULL OR q.deleted_at IS NOT NULL OR q.status <> ?", time.Now(), QuestionStatusOpen).
+		Pluck("bounties.id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := s.settle(id, false); err != nil {
+			return i, fmt.Errorf("bounty %d: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// RunExpiryWorker calls ProcessExpired every BountySweepMinutes until ctx is cancelled.
+func (s *BountyService) RunExpiryWorker(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.Config.BountySweepMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		if _, err := s.ProcessExpired(); err != nil {
+			log.Printf("Bounty expiry failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// settle ends an active bounty. A bounty on a question that is no longer open (or when
+// forceRefund is set) is refunded in full. Otherwise it goes to the top-scored visible
+// answer posted after the bounty started with at least BountyAutoAwardMinScore, or expires
+// with BountyExpiryRefundPercent refunded.
+func (s *BountyService) settle(bountyID uint, forceRefund bool) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var bounty models.Bounty
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bounty, bountyID).Error; err != nil {
+			return err
+		}
+		if bounty.Status != BountyStatusActive {
+			return nil
+		}
+
+		var question models.Question
+		err := tx.Select("id", "status").First(&question, bounty.QuestionID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if forceRefund || err != nil || question.Status != QuestionStatusOpen {
+			return s.refund(tx, &bounty, bounty.Amount, BountyStatusRefunded)
+		}
+
+		var answer models.Answer
+		err = tx.Select("id", "owner_id", "question_id").
+			Where("question_id = ? AND owner_id <> ? AND created_at >= ? AND score >= ? AND hidden_at IS NULL",
+				bounty.QuestionID, bounty.OwnerID, bounty.CreatedAt, s.Config.BountyAutoAwardMinScore).
+			Order("score DESC, created_at ASC").First(&answer).Error
+		if err == nil {
+			return s.award(tx, &bounty, &answer)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return s.refund(tx, &bounty, bounty.Amount*s.Config.BountyExpiryRefundPercent/100, BountyStatusExpired)
+	})
+}
+
+func (s *BountyService) award(tx *gorm.DB, bounty *models.Bounty, answer *models.Answer) error {
+	now := time.Now()
+	bounty.Status = BountyStatusAwarded
+	bounty.AwardedAnswerID = &answer.ID
+	bounty.AwardedAt = &now
+	if err := tx.Save(bounty).Error; err != nil {
+		return err
+	}
+
+	rep := &ReputationService{DB: tx, Config: s.Config}
+	ref := ReputationRef{QuestionID: &bounty.QuestionID, AnswerID: &answer.ID, ActorID: &bounty.OwnerID}
+	if err := rep.RecordAmount(answer.OwnerID, RepBountyAwarded, bounty.Amount, ref); err != nil {
+		return err
+	}
+	return tx.Create(&models.Notification{
+		UserID:     answer.OwnerID,
+		Message:    fmt.Sprintf("Your answer was awarded a +%d bounty", bounty.Amount),
+		Kind:       NotificationKindBountyAwarded,
+		QuestionID: &bounty.QuestionID,
+	}).Error
+}
+
+func (s *BountyService) refund(tx *gorm.DB, bounty *models.Bounty, amount int, status string) error {
+	bounty.Status = status
+	if err := tx.Save(bounty).Error; err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return nil
+	}
+	rep := &ReputationService{DB: tx, Config: s.Config}
+	return rep.RecordAmount(bounty.OwnerID, RepBountyRefunded, amount, ReputationRef{QuestionID: &bounty.QuestionID})
+}
+
+// GetFeatured returns a page of active bounties with their questions, soonest to
+// expire first.
+func (s *BountyService) GetFeatured(page pagination.Params) ([]models.Bounty, *pagination.Cursor, error) {
+	db := s.featured().Preload("Question.Tags.Tag").Order("bounties.expires_at ASC, bounties.id ASC")
+	if page.After != nil {
+		db = db.Where("(bounties.expires_at, bounties.id) > (?, ?)", page.After.Time, page.After.ID)
+	}
+
+	var bounties []models.Bounty
+	if err := db.Limit(page.Limit + 1).Find(&bounties).Error; err != nil {
+		return nil, nil, err
+	}
+	bounties, hasMore := pagination.Trim(bounties, page.Limit)
+	if !hasMore {
+		return bounties, nil, nil
+	}
+	last := bounties[len(bounties)-1]
+	return bounties, &pagination.Cursor{ID: last.ID, Time: last.ExpiresAt}, nil
+}
+
+func (s *BountyService) CountFeatured() (int64, error) {
+	var count int64
+	err := s.featured().Count(&count).Error
+	return count, err
+}
+
+func (s *BountyService) featured() *gorm.DB {
+	return s.DB.Model(&models.Bounty{}).
+		Joins("JOIN questions q ON q.id = bounties.question_id AND q.deleted_at IS NULL AND q.hidden_at IS NULL").
+		Where("bounties.status = ? AND bounties.expires_at > ?", BountyStatusActive, time.Now())
+}
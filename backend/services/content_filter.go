@@ -0,0 +1,545 @@
+// services/content_filter.go
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"stackit/cache"
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Content rule kinds
+const (
+	ContentRuleDomain  = "domain"  // Links to the domain or its subdomains
+	ContentRuleKeyword = "keyword" // Whole word or phrase, case-insensitive
+	ContentRuleRegex   = "regex"   // RE2 syntax, case-insensitive
+)
+
+var (
+	ErrInvalidContentRule  = errors.New("invalid content rule pattern")
+	ErrContentRuleExists   = errors.New("content rule already exists")
+	ErrContentRuleNotFound = errors.New("content rule not found")
+	ErrNotQuarantined      = errors.New("post is not quarantined")
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'()\[\]]+`)
+
+// ContentPost is a post about to be persisted, as the content checks see it.
+type ContentPost struct {
+	TargetType  string // "question" or "answer"
+	AuthorID    uint
+	Reputation  int
+	Title       string // Empty for answers
+	Body        string // Source as submitted, HTML or Markdown
+	Links       []*url.URL
+	ContentHash string
+}
+
+// ContentCheck is one stage of the content filter. Check returns why the post looks
+// like spam or abuse, or "" if it passes.
+type ContentCheck interface {
+	Name() string
+	Check(post *ContentPost) (string, error)
+}
+
+// ContentFinding is a check that a post failed.
+type ContentFinding struct {
+	Check  string
+	Reason string
+}
+
+// ContentVerdict is the outcome of screening a post.
+type ContentVerdict struct {
+	Findings []ContentFinding
+}
+
+// Quarantined reports whether the post must be held for moderator review.
+func (v *ContentVerdict) Quarantined() bool {
+	return len(v.Findings) > 0
+}
+
+// Reason summarizes the findings for the post's QuarantineReason.
+func (v *ContentVerdict) Reason() string {
+	reasons := make([]string, 0, len(v.Findings))
+	for _, f := range v.Findings {
+		reasons = append(reasons, f.Check+": "+f.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// contentRules is the compiled blocklist.
+type contentRules struct {
+	domains  []string
+	patterns []compiledContentRule
+}
+
+type compiledContentRule struct {
+	rule *models.ContentRule
+	re   *regexp.Regexp
+}
+
+// ContentFilter screens new posts through a pipeline of checks. A post that fails any
+// check is quarantined rather than rejected: it is saved hidden until a moderator
+// releases it or deletes it as spam, and either decision trains the classifier.
+// Create one and share it, so blocklist changes apply everywhere at once.
+type ContentFilter struct {
+	DB         *gorm.DB
+	Config     *config.Config
+	Classifier *SpamClassifier
+	checks     []ContentCheck
+	rules      *cache.TTL[string, *contentRules]
+}
+
+func NewContentFilter(db *gorm.DB, cfg *config.Config) *ContentFilter {
+	f := &ContentFilter{
+		DB:         db,
+		Config:     cfg,
+		Classifier: NewSpamClassifier(db, cfg),
+		rules:      cache.NewTTL[string, *contentRules](time.Minute, 1),
+	}
+	f.Use(
+		linkLimitCheck{cfg: cfg},
+		blocklistCheck{filter: f},
+		duplicateCheck{db: db, cfg: cfg},
+		classifierCheck{classifier: f.Classifier, threshold: cfg.SpamClassifierThreshold},
+	)
+	return f
+}
+
+// Use appends checks to the pipeline.
+func (f *ContentFilter) Use(checks ...ContentCheck) {
+	f.checks = append(f.checks, checks...)
+}
+
+// Screen runs a new post through every check, so moderators see all the reasons it
+// was held. Posts by moderators and admins are not screened.
+func (f *ContentFilter) Screen(targetType string, authorID uint, title, body string) (*ContentVerdict, error) {
+	var author models.User
+	if err := f.DB.Select("id", "role", "reputation").First(&author, authorID).Error; err != nil {
+		return nil, err
+	}
+	verdict := &ContentVerdict{}
+	if author.Role == "moderator" || author.Role == "admin" {
+		return verdict, nil
+	}
+
+	post := &ContentPost{
+		TargetType:  targetType,
+		AuthorID:    authorID,
+		Reputation:  author.Reputation,
+		Title:       title,
+		Body:        body,
+		Links:       extractLinks(title + "\n" + body),
+		ContentHash: contentHash(body),
+	}
+	for _, check := range f.checks {
+		reason, err := check.Check(post)
+		if err != nil {
+			return nil, fmt.Errorf("content check %s: %w", check.Name(), err)
+		}
+		if reason != "" {
+			verdict.Findings = append(verdict.Findings, ContentFinding{Check: check.Name(), Reason: reason})
+		}
+	}
+	return verdict, nil
+}
+
+// screenPost runs a new post through filter, if there is one, and returns when and
+// why it is quarantined; nil if it is not.
+func screenPost(filter *ContentFilter, targetType string, authorID uint, title, body string) (*time.Time, string, error) {
+	if filter == nil {
+		return nil, "", nil
+	}
+	verdict, err := filter.Screen(targetType, authorID, title, body)
+	if err != nil || !verdict.Quarantined() {
+		return nil, "", err
+	}
+	now := time.Now()
+	return &now, verdict.Reason(), nil
+}
+
+// linkLimitCheck holds low-reputation users to a few links per post.
+type linkLimitCheck struct {
+	cfg *config.Config
+}
+
+func (linkLimitCheck) Name() string { return "links" }
+
+func (c linkLimitCheck) Check(post *ContentPost) (string, error) {
+	if post.Reputation >= c.cfg.SpamLinkReputation || len(post.Links) <= c.cfg.SpamMaxLinks {
+		return "", nil
+	}
+	return fmt.Sprintf("%d links; users below %d reputation may post %d", len(post.Links),
+		c.cfg.SpamLinkReputation, c.cfg.SpamMaxLinks), nil
+}
+
+// blocklistCheck matches links against the blocked domains and the text against the
+// blocked keywords and patterns.
+type blocklistCheck struct {
+	filter *ContentFilter
+}
+
+func (blocklistCheck) Name() string { return "blocklist" }
+
+func (c blocklistCheck) Check(post *ContentPost) (string, error) {
+	rules, err := c.filter.compiledRules()
+	if err != nil {
+		return "", err
+	}
+	for _, link := range post.Links {
+		host := strings.ToLower(link.Hostname())
+		for _, domain := range rules.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return "links to blocked domain " + domain, nil
+			}
+		}
+	}
+	text := post.Title + "\n" + post.Body
+	for _, p := range rules.patterns {
+		if p.re.MatchString(text) {
+			return fmt.Sprintf("matches blocked %s %q", p.rule.Kind, p.rule.Pattern), nil
+		}
+	}
+	return "", nil
+}
+
+// duplicateCheck holds posts that copy another user's question or answer, including
+// deleted ones, since deleted spam is what gets reposted.
+type duplicateCheck struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func (duplicateCheck) Name() string { return "duplicate" }
+
+func (c duplicateCheck) Check(post *ContentPost) (string, error) {
+	if len(normalizeContent(post.Body)) < c.cfg.SpamDuplicateMinLength {
+		return "", nil
+	}
+	for _, targetType := range []string{FollowTargetQuestion, FollowTargetAnswer} {
+		var ids []uint
+		if err := c.db.Unscoped().Model(flagPostModel(targetType)).
+			Where("content_hash = ? AND owner_id <> ?", post.ContentHash, post.AuthorID).
+			Limit(1).Pluck("id", &ids).Error; err != nil {
+			return "", err
+		}
+		if len(ids) > 0 {
+			return fmt.Sprintf("copies %s %d by another user", targetType, ids[0]), nil
+		}
+	}
+	return "", nil
+}
+
+// classifierCheck holds posts the spam classifier is confident about.
+type classifierCheck struct {
+	classifier *SpamClassifier
+	threshold  float64
+}
+
+func (classifierCheck) Name() string { return "classifier" }
+
+func (c classifierCheck) Check(post *ContentPost) (string, error) {
+	probability, trained, err := c.classifier.SpamProbability(post.Title + "\n" + post.Body)
+	if err != nil || !trained || probability < c.threshold {
+		return "", err
+	}
+	return fmt.Sprintf("spam probability %.2f", probability), nil
+}
+
+// extractLinks returns the distinct URLs in text, both bare and in HTML or Markdown
+// links, so an HTML link that repeats its URL as its text counts once.
+func extractLinks(text string) []*url.URL {
+	seen := map[string]bool{}
+	var links []*url.URL
+	for _, match := range linkPattern.FindAllString(text, -1) {
+		match = strings.TrimRight(match, ".,;:!?")
+		if !strings.Contains(match, "://") {
+			match = "http://" + match
+		}
+		link, err := url.Parse(match)
+		if err != nil || link.Hostname() == "" || seen[link.String()] {
+			continue
+		}
+		seen[link.String()] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// normalizeContent lowercases text and collapses whitespace, so trivial edits do not
+// hide a copy.
+func normalizeContent(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// contentHash is stored on every post for the duplicate check.
+func contentHash(body string) string {
+	sum := sha256.Sum256([]byte(normalizeContent(body)))
+	return hex.EncodeToString(sum[:])
+}
+
+// compiledRules returns the blocklist, cached briefly.
+func (f *ContentFilter) compiledRules() (*contentRules, error) {
+	return f.rules.GetOrLoad("rules", func() (*contentRules, error) {
+		rules, err := f.ListRules()
+		if err != nil {
+			return nil, err
+		}
+		compiled := &contentRules{}
+		for i := range rules {
+			rule := &rules[i]
+			if rule.Kind == ContentRuleDomain {
+				compiled.domains = append(compiled.domains, rule.Pattern)
+				continue
+			}
+			re, err := compileContentRule(rule.Kind, rule.Pattern)
+			if err != nil {
+				log.Printf("Skipping invalid content rule %d: %v", rule.ID, err)
+				continue
+			}
+			compiled.patterns = append(compiled.patterns, compiledContentRule{rule: rule, re: re})
+		}
+		return compiled, nil
+	})
+}
+
+func compileContentRule(kind, pattern string) (*regexp.Regexp, error) {
+	if kind == ContentRuleKeyword {
+		return regexp.Compile(`(?i)\b` + regexp.QuoteMeta(pattern) + `\b`)
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// normalizeContentRule validates a rule's pattern and returns it in stored form.
+func normalizeContentRule(kind, pattern string) (string, error) {
+	pattern = strings.TrimSpace(pattern)
+	switch kind {
+	case ContentRuleDomain:
+		pattern = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(pattern), "*."), ".")
+		if !strings.Contains(pattern, ".") || strings.ContainsAny(pattern, "/:@ ") {
+			return "", ErrInvalidContentRule
+		}
+	case ContentRuleKeyword:
+		pattern = strings.ToLower(pattern)
+	}
+	if pattern == "" {
+		return "", ErrInvalidContentRule
+	}
+	if kind != ContentRuleDomain {
+		if _, err := compileContentRule(kind, pattern); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidContentRule, err)
+		}
+	}
+	return pattern, nil
+}
+
+// ListRules returns the blocklist, grouped by kind.
+func (f *ContentFilter) ListRules() ([]models.ContentRule, error) {
+	var rules []models.ContentRule
+	err := f.DB.Order("kind ASC, pattern ASC").Find(&rules).Error
+	return rules, err
+}
+
+// CreateRule adds a domain, keyword or regex to the blocklist. The actor must be an admin.
+func (f *ContentFilter) CreateRule(actor AuditActor, kind, pattern, note string) (*models.ContentRule, error) {
+	pattern, err := normalizeContentRule(kind, pattern)
+	if err != nil {
+		return nil, err
+	}
+	rule := models.ContentRule{Kind: kind, Pattern: pattern, Note: note, CreatedByID: *actor.UserID}
+	err = f.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rule)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrContentRuleExists
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditContentRuleCreated,
+			TargetType: AuditTargetContentRule,
+			TargetID:   &rule.ID,
+			Details:    map[string]interface{}{"kind": kind, "pattern": pattern, "note": note},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.rules.Clear()
+	return &rule, nil
+}
+
+// DeleteRule removes a rule from the blocklist. Posts it quarantined stay quarantined.
+func (f *ContentFilter) DeleteRule(actor AuditActor, ruleID uint) error {
+	err := f.DB.Transaction(func(tx *gorm.DB) error {
+		var rule models.ContentRule
+		if err := tx.First(&rule, ruleID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrContentRuleNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditContentRuleDeleted,
+			TargetType: AuditTargetContentRule,
+			TargetID:   &rule.ID,
+			Details:    map[string]interface{}{"kind": rule.Kind, "pattern": rule.Pattern},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	f.rules.Clear()
+	return nil
+}
+
+// GetQuarantinedQuestions returns a page of quarantined questions, oldest first.
+func (f *ContentFilter) GetQuarantinedQuestions(page pagination.Params) ([]models.Question, *pagination.Cursor, error) {
+	var questions []models.Question
+	if err := f.quarantinedPosts(page).Preload("Tags.Tag").Preload("Attachments").Find(&questions).Error; err != nil {
+		return nil, nil, err
+	}
+	questions, hasMore := pagination.Trim(questions, page.Limit)
+	if !hasMore {
+		return questions, nil, nil
+	}
+	return questions, &pagination.Cursor{ID: questions[len(questions)-1].ID}, nil
+}
+
+// GetQuarantinedAnswers returns a page of quarantined answers, oldest first.
+func (f *ContentFilter) GetQuarantinedAnswers(page pagination.Params) ([]models.Answer, *pagination.Cursor, error) {
+	var answers []models.Answer
+	if err := f.quarantinedPosts(page).Preload("Attachments").Find(&answers).Error; err != nil {
+		return nil, nil, err
+	}
+	answers, hasMore := pagination.Trim(answers, page.Limit)
+	if !hasMore {
+		return answers, nil, nil
+	}
+	return answers, &pagination.Cursor{ID: answers[len(answers)-1].ID}, nil
+}
+
+func (f *ContentFilter) quarantinedPosts(page pagination.Params) *gorm.DB {
+	db := f.DB.Where("quarantined_at IS NOT NULL").Order("id ASC")
+	if page.After != nil {
+		db = db.Where("id > ?", page.After.ID)
+	}
+	return db.Limit(page.Limit + 1)
+}
+
+// CountQuarantined counts quarantined posts of a type.
+func (f *ContentFilter) CountQuarantined(targetType string) (int64, error) {
+	var count int64
+	err := f.DB.Model(flagPostModel(targetType)).Where("quarantined_at IS NOT NULL").Count(&count).Error
+	return count, err
+}
+
+// Release publishes a quarantined post and trains the classifier that it is not spam.
+// The actor must be a moderator.
+func (f *ContentFilter) Release(actor AuditActor, targetType string, targetID uint) error {
+	return f.DB.Transaction(func(tx *gorm.DB) error {
+		reason, err := lockQuarantined(tx, targetType, targetID)
+		if err != nil {
+			return err
+		}
+		if err := publishQuarantined(tx, targetType, targetID); err != nil {
+			return err
+		}
+		if err := trainSpamClassifier(tx, targetType, targetID, false); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditPostReleased,
+			TargetType: targetType,
+			TargetID:   &targetID,
+			Details:    map[string]interface{}{"quarantine_reason": reason},
+		})
+	})
+}
+
+// ConfirmSpam deletes a quarantined post as spam and trains the classifier on it.
+// The actor must be a moderator.
+func (f *ContentFilter) ConfirmSpam(actor AuditActor, targetType string, targetID uint) error {
+	err := f.DB.Transaction(func(tx *gorm.DB) error {
+		reason, err := lockQuarantined(tx, targetType, targetID)
+		if err != nil {
+			return err
+		}
+		if err := trainSpamClassifier(tx, targetType, targetID, true); err != nil {
+			return err
+		}
+		remove := deleteAnswer
+		if targetType == FollowTargetQuestion {
+			remove = deleteQuestion
+		}
+		if err := remove(tx, f.Config, targetID); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditPostDeleted,
+			TargetType: targetType,
+			TargetID:   &targetID,
+			Details:    map[string]interface{}{"spam": true, "quarantine_reason": reason},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if targetType == FollowTargetQuestion {
+		if err := NewBountyService(f.DB, f.Config).RefundQuestionBounties(targetID); err != nil {
+			// The expiry worker refunds it on its next pass
+			log.Printf("Failed to refund bounty on spam question %d: %v", targetID, err)
+		}
+	}
+	return nil
+}
+
+// publishQuarantined lifts a post's quarantine and unhides it. It does nothing if the
+// post is not quarantined. Answers are left out of their question's answer count and
+// activity while quarantined, so they are counted now.
+func publishQuarantined(tx *gorm.DB, targetType string, targetID uint) error {
+	result := tx.Model(flagPostModel(targetType)).Where("id = ? AND quarantined_at IS NOT NULL", targetID).
+		UpdateColumns(map[string]interface{}{"quarantined_at": nil, "quarantine_reason": "", "hidden_at": nil})
+	if result.Error != nil || result.RowsAffected == 0 || targetType != FollowTargetAnswer {
+		return result.Error
+	}
+	return tx.Model(&models.Question{}).Where("id = (SELECT question_id FROM answers WHERE id = ?)", targetID).
+		UpdateColumns(map[string]interface{}{"answer_count": gorm.Expr("answer_count + 1"), "last_activity_at": time.Now()}).Error
+}
+
+// lockQuarantined locks a quarantined post and returns why it was held.
+func lockQuarantined(tx *gorm.DB, targetType string, targetID uint) (string, error) {
+	var post struct {
+		QuarantinedAt    *time.Time
+		QuarantineReason string
+	}
+	result := tx.Model(flagPostModel(targetType)).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("quarantined_at", "quarantine_reason").Where("id = ?", targetID).Limit(1).Scan(&post)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrFlagTargetNotFound
+	}
+	if post.QuarantinedAt == nil {
+		return "", ErrNotQuarantined
+	}
+	return post.QuarantineReason, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"no links", "plain text about example.com", nil},
+		{"bare link", "see https://example.com/docs for more", []string{"https://example.com/docs"}},
+		{"www without scheme", "try www.example.com.", []string{"http://www.example.com"}},
+		{"trailing punctuation", "links: http://a.example/x, https://b.example!", []string{"http://a.example/x", "https://b.example"}},
+		{"html link repeating its url", `<a href="https://example.com/x">https://example.com/x</a>`, []string{"https://example.com/x"}},
+		{"markdown link", "[docs](https://example.com/docs)", []string{"https://example.com/docs"}},
+		{"scheme is case-insensitive", "HTTPS://Example.com/Path", []string{"https://Example.com/Path"}},
+		{"no host", "http:// and https:///path", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, link := range extractLinks(tt.text) {
+				got = append(got, link.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractLinks(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeContentRule(t *testing.T) {
+	tests := []struct {
+		kind    string
+		pattern string
+		want    string
+	}{
+		{ContentRuleDomain, "Example.COM", "example.com"},
+		{ContentRuleDomain, " *.spam.example ", "spam.example"},
+		{ContentRuleDomain, ".spam.example", "spam.example"},
+		{ContentRuleKeyword, "  Buy Now ", "buy now"},
+		{ContentRuleKeyword, "c++", "c++"},
+		{ContentRuleRegex, `Cheap\s+Pills`, `Cheap\s+Pills`},
+	}
+	for _, tt := range tests {
+		got, err := normalizeContentRule(tt.kind, tt.pattern)
+		if err != nil {
+			t.Errorf("normalizeContentRule(%q, %q) error: %v", tt.kind, tt.pattern, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeContentRule(%q, %q) = %q, want %q", tt.kind, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeContentRuleInvalid(t *testing.T) {
+	tests := []struct {
+		kind    string
+		pattern string
+	}{
+		{ContentRuleDomain, ""},
+		{ContentRuleDomain, "localhost"},
+		{ContentRuleDomain, "https://spam.example"},
+		{ContentRuleDomain, "spam.example/path"},
+		{ContentRuleDomain, "user@spam.example"},
+		{ContentRuleDomain, "spam .example"},
+		{ContentRuleKeyword, "   "},
+		{ContentRuleRegex, ""},
+		{ContentRuleRegex, "(unclosed"},
+		{ContentRuleRegex, `a(?=b)`},
+	}
+	for _, tt := range tests {
+		if got, err := normalizeContentRule(tt.kind, tt.pattern); !errors.Is(err, ErrInvalidContentRule) {
+			t.Errorf("normalizeContentRule(%q, %q) = %q, %v, want ErrInvalidContentRule", tt.kind, tt.pattern, got, err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// services/feed_service.go
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TagPreferenceWatched = "watched"
+	TagPreferenceIgnored = "ignored"
+
+	NotificationKindWatchedTag = "watched_tag"
+)
+
+const feedSort = "feed"
+
+// FeedService manages watched and ignored tags and the personalized question feed
+// built from them.
+type FeedService struct {
+	DB     *gorm.DB
+	Config *config.Config
+	Tags   *TagService
+}
+
+func NewFeedService(db *gorm.DB, cfg *config.Config) *FeedService {
+	return &FeedService{DB: db, Config: cfg, Tags: NewTagService(db, cfg)}
+}
+
+func (s *FeedService) GetTagPreferences(userID uint) ([]models.TagPreference, error) {
+	var prefs []models.TagPreference
+	err := s.DB.Preload("Tag").Where("user_id = ?", userID).Order("kind ASC").Order("created_at ASC").Find(&prefs).Error
+	return prefs, err
+}
+
+// SetTagPreference watches or ignores a tag (synonyms resolve to their master),
+// replacing any previous preference for it.
+func (s *FeedService) SetTagPreference(userID uint, tagName, kind string) (*models.TagPreference, error) {
+	tag, err := s.Tags.GetTagByName(tagName)
+	if err != nil {
+		return nil, err
+	}
+
+	pref := models.TagPreference{UserID: userID, TagID: tag.ID, Kind: kind, Tag: tag.Tag}
+	err = s.DB.Omit("Tag").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "tag_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"kind"}),
+	}).Create(&pref).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+func (s *FeedService) RemoveTagPreference(userID uint, tagName string) error {
+	tag, err := s.Tags.GetTagByName(tagName)
+	if err != nil {
+		return err
+	}
+	return s.DB.Where("user_id = ? AND tag_id = ?", userID, tag.ID).Delete(&models.TagPreference{}).Error
+}
+
+// feed hides questions carrying any ignored tag.
+func (s *FeedService) feed(userID uint) *gorm.DB {
+	return s.DB.Model(&models.Question{}).Where("questions.hidden_at IS NULL").
+		Where(`NOT EXISTS (SELECT 1 FROM question_tags qt JOIN tag_preferences tp ON tp.tag_id = qt.tag_id
+			WHERE qt.question_id = questions.id AND tp.user_id = ? AND tp.kind = ?)`, userID, TagPreferenceIgnored)
+}
+
+// GetFeed orders questions by recent activity, treating those in watched tags as if
+// they were FeedWatchedBoostHours more recent. The ordering is per user, so pages
+// are addressed by offset.
+func (s *FeedService) GetFeed(userID uint, page pagination.Params) ([]models.Question, *pagination.Cursor, error) {
+	if err := page.CheckSort(feedSort); err != nil {
+		return nil, nil, err
+	}
+
+	var questions []models.Question
+	err := s.feed(userID).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL: `questions.last_activity_at + make_interval(hours => CASE WHEN EXISTS (
+					SELECT 1 FROM question_tags qt JOIN tag_preferences tp ON tp.tag_id = qt.tag_id
+					WHERE qt.question_id = questions.id AND tp.user_id = ? AND tp.kind = ?) THEN ? ELSE 0 END) DESC,
+				questions.id DESC`,
+			Vars:               []interface{}{userID, TagPreferenceWatched, s.Config.FeedWatchedBoostHours},
+			WithoutParentheses: true,
+		}}).
+		Preload("Tags.Tag").Offset(page.Offset()).Limit(page.Limit + 1).Find(&questions).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	questions, hasMore := pagination.Trim(questions, page.Limit)
+	if !hasMore {
+		return questions, nil, nil
+	}
+	return questions, &pagination.Cursor{Sort: feedSort, Offset: page.Offset() + len(questions)}, nil
+}
+
+func (s *FeedService) CountFeed(userID uint) (int64, error) {
+	var count int64
+	err := s.feed(userID).Count(&count).Error
+	return count, err
+}
+
+// NotifyTagWatchers notifies users watching any of the question's tags, skipping the
+// author, anyone ignoring one of its tags and anyone who reached the hourly cap.
+// Publish a NotificationKindWatchedTag event rather than calling it from a request.
+func (s *FeedService) NotifyTagWatchers(questionID uint) (int, error) {
+	limit := s.Config.WatchedTagNotificationsPerHour
+	if limit <= 0 {
+		return 0, nil
+	}
+	var question models.Question
+	if err := s.DB.Select("id", "title", "owner_id").Preload("Tags").First(&question, questionID).Error; err != nil {
+		return 0, err
+	}
+	if len(question.Tags) == 0 {
+		return 0, nil
+	}
+	tagIDs := []uint{}
+	for _, qt := range question.Tags {
+		tagIDs = append(tagIDs, qt.TagID)
+	}
+
+	var userIDs []uint
+	err := s.DB.Raw(`SELECT DISTINCT tp.user_id FROM tag_preferences tp
+		WHERE tp.tag_id IN ? AND tp.kind = ? AND tp.user_id <> ?
+		AND NOT EXISTS (SELECT 1 FROM tag_preferences ig WHERE ig.user_id = tp.user_id AND ig.kind = ? AND ig.tag_id IN ?)
+		AND (SELECT COUNT(*) FROM notifications n
+			WHERE n.user_id = tp.user_id AND n.kind = ? AND n.created_at > ? AND n.deleted_at IS NULL) < ?`,
+		tagIDs, TagPreferenceWatched, question.OwnerID, TagPreferenceIgnored, tagIDs,
+		NotificationKindWatchedTag, time.Now().Add(-time.Hour), limit).Scan(&userIDs).Error
+	if err != nil || len(userIDs) == 0 {
+		return 0, err
+	}
+
+	notifications := make([]models.Notification, 0, len(userIDs))
+	for _, id := range userIDs {
+		notifications = append(notifications, models.Notification{
+			UserID:     id,
+			Message:    fmt.Sprintf("New question in a watched tag: %s", question.Title),
+			Kind:       NotificationKindWatchedTag,
+			QuestionID: &question.ID,
+		})
+	}
+	if err := s.DB.CreateInBatches(&notifications, 500).Error; err != nil {
+		return 0, err
+	}
+	return len(notifications), nil
+}
@@ -0,0 +1,455 @@
+// services/flag_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FlagTargetUser flags a user; posts are flagged with FollowTargetQuestion and
+// FollowTargetAnswer.
+const FlagTargetUser = "user"
+
+// Flag reasons
+const (
+	FlagReasonSpam           = "spam"
+	FlagReasonRude           = "rude"
+	FlagReasonOffTopic       = "off-topic"
+	FlagReasonNeedsModerator = "needs-moderator" // Carries a free-text note
+)
+
+// Flag statuses
+const (
+	FlagStatusPending  = "pending"
+	FlagStatusHelpful  = "helpful"
+	FlagStatusDeclined = "declined"
+)
+
+// Moderator actions on a flagged target. Dismissing declines the flags; every other
+// action marks them helpful.
+const (
+	ReviewActionDismiss = "dismiss"
+	ReviewActionDelete  = "delete" // Questions and answers
+	ReviewActionLock    = "lock"   // Questions
+	ReviewActionWarn    = "warn"   // Notifies the owner of the post, or the flagged user
+)
+
+const NotificationKindModeratorWarning = "moderator_warning"
+
+var (
+	ErrFlagTargetNotFound  = errors.New("flagged post or user not found")
+	ErrFlagOwnContent      = errors.New("you cannot flag your own content")
+	ErrAlreadyFlagged      = errors.New("you already have a pending flag on this")
+	ErrNoPendingFlags      = errors.New("there are no pending flags to review")
+	ErrInvalidReviewAction = errors.New("action does not apply to this kind of target")
+)
+
+// FlagSummary aggregates the pending flags on one target for the review queue.
+type FlagSummary struct {
+	TargetType     string
+	TargetID       uint
+	FlagCount      int64
+	Spam           int64
+	Rude           int64
+	OffTopic       int64
+	NeedsModerator int64
+	Hidden         bool
+	FirstFlaggedAt time.Time
+	LastFlaggedAt  time.Time
+}
+
+// FlagTarget is a flagged post or user; exactly one field is set.
+type FlagTarget struct {
+	Question *models.Question
+	Answer   *models.Answer
+	User     *models.User
+}
+
+type FlagService struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewFlagService(db *gorm.DB, cfg *config.Config) *FlagService {
+	return &FlagService{DB: db, Config: cfg}
+}
+
+// CreateFlag records a flag. A post that collects FlagSpamHideThreshold pending spam
+// flags is hidden until a moderator reviews it.
+func (s *FlagService) CreateFlag(flaggerID uint, targetType string, targetID uint, reason, note string) (*models.Flag, error) {
+	ownerID, _, err := flagTargetOwner(s.DB, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID == flaggerID {
+		return nil, ErrFlagOwnContent
+	}
+
+	flag := models.Flag{
+		TargetType: targetType,
+		TargetID:   targetID,
+		FlaggerID:  flaggerID,
+		Reason:     reason,
+		Note:       note,
+		Status:     FlagStatusPending,
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&flag)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyFlagged
+		}
+		if reason != FlagReasonSpam || targetType == FlagTargetUser || s.Config.FlagSpamHideThreshold <= 0 {
+			return nil
+		}
+
+		var spamFlags int64
+		if err := pendingFlags(tx, targetType, targetID).Where("reason = ?", FlagReasonSpam).
+			Count(&spamFlags).Error; err != nil {
+			return err
+		}
+		if spamFlags < int64(s.Config.FlagSpamHideThreshold) {
+			return nil
+		}
+		return tx.Model(flagPostModel(targetType)).Where("id = ? AND hidden_at IS NULL", targetID).
+			UpdateColumn("hidden_at", time.Now()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// queue groups pending flags by target, optionally of a single target type.
+func (s *FlagService) queue(targetType string) *gorm.DB {
+	db := s.DB.Model(&models.Flag{}).Where("status = ?", FlagStatusPending)
+	if targetType != "" {
+		db = db.Where("target_type = ?", targetType)
+	}
+	return db.Group("target_type, target_id")
+}
+
+// GetQueue returns a page of flagged targets, most flagged first and then longest
+// waiting. Pages use offsets since the ordering is computed.
+func (s *FlagService) GetQueue(targetType string, page pagination.Params) ([]FlagSummary, *pagination.Cursor, error) {
+	sort := "queue:" + targetType
+	if err := page.CheckSort(sort); err != nil {
+		return nil, nil, err
+	}
+	offset := page.Offset()
+
+	var summaries []FlagSummary
+	err := s.queue(targetType).
+		Select(`target_type, target_id, COUNT(*) AS flag_count,
+			COUNT(*) FILTER (WHERE reason = ?) AS spam,
+			COUNT(*) FILTER (WHERE reason = ?) AS rude,
+			COUNT(*) FILTER (WHERE reason = ?) AS off_topic,
+			COUNT(*) FILTER (WHERE reason = ?) AS needs_moderator,
+			CASE target_type
+				WHEN ? THEN EXISTS (SELECT 1 FROM questions q WHERE q.id = target_id AND q.hidden_at IS NOT NULL)
+				WHEN ? THEN EXISTS (SELECT 1 FROM answers a WHERE a.id = target_id AND a.hidden_at IS NOT NULL)
+				ELSE FALSE END AS hidden,
+			MIN(created_at) AS first_flagged_at, MAX(created_at) AS last_flagged_at`,
+			FlagReasonSpam, FlagReasonRude, FlagReasonOffTopic, FlagReasonNeedsModerator,
+			FollowTargetQuestion, FollowTargetAnswer).
+		Order("flag_count DESC, first_flagged_at ASC, target_type, target_id").
+		Offset(offset).Limit(page.Limit + 1).Scan(&summaries).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	summaries, hasMore := pagination.Trim(summaries, page.Limit)
+	if !hasMore {
+		return summaries, nil, nil
+	}
+	return summaries, &pagination.Cursor{Sort: sort, Offset: offset + len(summaries)}, nil
+}
+
+func (s *FlagService) CountQueue(targetType string) (int64, error) {
+	var count int64
+	err := s.DB.Table("(?) AS t", s.queue(targetType).Select("target_type, target_id")).Count(&count).Error
+	return count, err
+}
+
+// GetPendingFlags returns the pending flags on a target with their flaggers, oldest first.
+func (s *FlagService) GetPendingFlags(targetType string, targetID uint) ([]models.Flag, error) {
+	var flags []models.Flag
+	err := pendingFlags(s.DB, targetType, targetID).Preload("Flagger").Order("id").Find(&flags).Error
+	return flags, err
+}
+
+// GetTarget loads a flagged post or user for review.
+func (s *FlagService) GetTarget(targetType string, targetID uint) (*FlagTarget, error) {
+	var target FlagTarget
+	var err error
+	switch targetType {
+	case FollowTargetQuestion:
+		target.Question = &models.Question{}
+		err = s.DB.Preload("Tags.Tag").Preload("Attachments").First(target.Question, targetID).Error
+	case FollowTargetAnswer:
+		target.Answer = &models.Answer{}
+		err = s.DB.Preload("Attachments").First(target.Answer, targetID).Error
+	case FlagTargetUser:
+		target.User = &models.User{}
+		err = s.DB.First(target.User, targetID).Error
+	default:
+		return nil, ErrFlagTargetNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFlagTargetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// Review resolves the pending flags on a target with a moderator action, records the
+// outcome and updates each flagger's flag accuracy. Posts left standing are unhidden.
+// Spam decisions train the content filter's classifier. The actor must be the moderator.
+func (s *FlagService) Review(actor AuditActor, targetType string, targetID uint, action, note string) (*models.FlagReview, error) {
+	if !reviewActionApplies(action, targetType) {
+		return nil, ErrInvalidReviewAction
+	}
+
+	review := models.FlagReview{
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Action:      action,
+		Note:        note,
+		ModeratorID: *actor.UserID,
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var flags []models.Flag
+		if err := pendingFlags(tx, targetType, targetID).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Find(&flags).Error; err != nil {
+			return err
+		}
+		if len(flags) == 0 {
+			return ErrNoPendingFlags
+		}
+		review.FlagCount = len(flags)
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		// Before apply, which may delete the post the classifier learns from
+		if err := trainFromReview(tx, &review, flags); err != nil {
+			return err
+		}
+		applied, err := s.apply(tx, &review)
+		if err != nil {
+			return err
+		}
+
+		status, counter := FlagStatusHelpful, "helpful_flags"
+		if action == ReviewActionDismiss {
+			status, counter = FlagStatusDeclined, "declined_flags"
+		}
+		flagIDs := make([]uint, 0, len(flags))
+		flaggerIDs := make([]uint, 0, len(flags))
+		for _, f := range flags {
+			flagIDs = append(flagIDs, f.ID)
+			flaggerIDs = append(flaggerIDs, f.FlaggerID)
+		}
+		if err := tx.Model(&models.Flag{}).Where("id IN ?", flagIDs).
+			Updates(map[string]interface{}{"status": status, "review_id": review.ID}).Error; err != nil {
+			return err
+		}
+		// A user has at most one pending flag per target, so each flagger counts once
+		if err := tx.Model(&models.User{}).Where("id IN ?", flaggerIDs).
+			UpdateColumn(counter, gorm.Expr(counter+" + 1")).Error; err != nil {
+			return err
+		}
+
+		entries := append(applied, AuditEntry{
+			Action:     AuditFlagsReviewed,
+			TargetType: targetType,
+			TargetID:   &targetID,
+			Details:    map[string]interface{}{"review_id": review.ID, "action": action, "note": note, "flag_count": review.FlagCount},
+		})
+		for _, entry := range entries {
+			if err := recordAudit(tx, actor, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if targetType == FollowTargetQuestion && action != ReviewActionDismiss && action != ReviewActionWarn {
+		if err := NewBountyService(s.DB, s.Config).RefundQuestionBounties(targetID); err != nil {
+			// The expiry worker refunds it on its next pass
+			log.Printf("Failed to refund bounty on moderated question %d: %v", targetID, err)
+		}
+	}
+	return &review, nil
+}
+
+// apply carries out the review's action on its target. It returns the audit entries
+// of what it did, for Review to record last.
+func (s *FlagService) apply(tx *gorm.DB, review *models.FlagReview) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	switch review.Action {
+	case ReviewActionDelete:
+		remove := deleteAnswer
+		if review.TargetType == FollowTargetQuestion {
+			remove = deleteQuestion
+		}
+		if err := remove(tx, s.Config, review.TargetID); err != nil {
+			return nil, err
+		}
+		return []AuditEntry{{
+			Action:     AuditPostDeleted,
+			TargetType: review.TargetType,
+			TargetID:   &review.TargetID,
+			Details:    map[string]interface{}{"review_id": review.ID},
+		}}, nil
+	case ReviewActionLock:
+		entry, err := setLocked(tx, review.TargetID, true)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	case ReviewActionWarn:
+		ownerID, questionID, err := flagTargetOwner(tx, review.TargetType, review.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		message := "A moderator has warned you about your conduct"
+		if review.TargetType != FlagTargetUser {
+			message = fmt.Sprintf("A moderator has warned you about your %s", review.TargetType)
+		}
+		if review.Note != "" {
+			message += ": " + review.Note
+		}
+		if err := tx.Create(&models.Notification{
+			UserID:     ownerID,
+			Message:    message,
+			Kind:       NotificationKindModeratorWarning,
+			QuestionID: questionID,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if review.TargetType == FlagTargetUser {
+		return entries, nil
+	}
+	// The moderator's decision also settles a quarantine
+	if err := publishQuarantined(tx, review.TargetType, review.TargetID); err != nil {
+		return nil, err
+	}
+	return entries, tx.Model(flagPostModel(review.TargetType)).Where("id = ?", review.TargetID).UpdateColumn("hidden_at", nil).Error
+}
+
+// trainFromReview teaches the spam classifier from a review of a post flagged as spam
+// or quarantined: deleting it confirms spam, dismissing the flags refutes it.
+func trainFromReview(tx *gorm.DB, review *models.FlagReview, flags []models.Flag) error {
+	if review.TargetType == FlagTargetUser || (review.Action != ReviewActionDelete && review.Action != ReviewActionDismiss) {
+		return nil
+	}
+	spamRelated := false
+	for _, f := range flags {
+		spamRelated = spamRelated || f.Reason == FlagReasonSpam
+	}
+	if !spamRelated {
+		var quarantined int64
+		if err := tx.Model(flagPostModel(review.TargetType)).
+			Where("id = ? AND quarantined_at IS NOT NULL", review.TargetID).Count(&quarantined).Error; err != nil {
+			return err
+		}
+		spamRelated = quarantined > 0
+	}
+	if !spamRelated {
+		return nil
+	}
+	return trainSpamClassifier(tx, review.TargetType, review.TargetID, review.Action == ReviewActionDelete)
+}
+
+// GetUserFlags returns a page of the flags a user raised, newest first.
+func (s *FlagService) GetUserFlags(userID uint, page pagination.Params) ([]models.Flag, *pagination.Cursor, error) {
+	db := s.DB.Where("flagger_id = ?", userID).Order("id DESC")
+	if page.After != nil {
+		db = db.Where("id < ?", page.After.ID)
+	}
+
+	var flags []models.Flag
+	if err := db.Limit(page.Limit + 1).Find(&flags).Error; err != nil {
+		return nil, nil, err
+	}
+	flags, hasMore := pagination.Trim(flags, page.Limit)
+	if !hasMore {
+		return flags, nil, nil
+	}
+	return flags, &pagination.Cursor{ID: flags[len(flags)-1].ID}, nil
+}
+
+func (s *FlagService) CountUserFlags(userID uint) (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.Flag{}).Where("flagger_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func pendingFlags(db *gorm.DB, targetType string, targetID uint) *gorm.DB {
+	return db.Model(&models.Flag{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, FlagStatusPending)
+}
+
+// flagTargetOwner returns the user responsible for a target (the owner of a post, or
+// the flagged user) and the question it belongs to, if any.
+func flagTargetOwner(db *gorm.DB, targetType string, targetID uint) (uint, *uint, error) {
+	var rows []struct {
+		OwnerID    uint
+		QuestionID uint
+	}
+	switch targetType {
+	case FollowTargetQuestion:
+		db = db.Model(&models.Question{}).Select("owner_id, id AS question_id")
+	case FollowTargetAnswer:
+		db = db.Model(&models.Answer{}).Select("owner_id, question_id")
+	case FlagTargetUser:
+		db = db.Model(&models.User{}).Select("id AS owner_id, 0 AS question_id")
+	default:
+		return 0, nil, ErrFlagTargetNotFound
+	}
+	if err := db.Where("id = ?", targetID).Limit(1).Scan(&rows).Error; err != nil {
+		return 0, nil, err
+	}
+	if len(rows) == 0 {
+		return 0, nil, ErrFlagTargetNotFound
+	}
+	if rows[0].QuestionID == 0 {
+		return rows[0].OwnerID, nil, nil
+	}
+	return rows[0].OwnerID, &rows[0].QuestionID, nil
+}
+
+func flagPostModel(targetType string) interface{} {
+	if targetType == FollowTargetQuestion {
+		return &models.Question{}
+	}
+	return &models.Answer{}
+}
+
+func reviewActionApplies(action, targetType string) bool {
+	switch action {
+	case ReviewActionDismiss, ReviewActionWarn:
+		return targetType == FollowTargetQuestion || targetType == FollowTargetAnswer || targetType == FlagTargetUser
+	case ReviewActionDelete:
+		return targetType == FollowTargetQuestion || targetType == FollowTargetAnswer
+	case ReviewActionLock:
+		return targetType == FollowTargetQuestion
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package services
+
+import "testing"
+
+func TestReviewActionApplies(t *testing.T) {
+	tests := []struct {
+		action   string
+		question bool
+		answer   bool
+		user     bool
+	}{
+		{ReviewActionDismiss, true, true, true},
+		{ReviewActionWarn, true, true, true},
+		{ReviewActionDelete, true, true, false},
+		{ReviewActionLock, true, false, false},
+		{"ban", false, false, false},
+		{"", false, false, false},
+	}
+	for _, tt := range tests {
+		for targetType, want := range map[string]bool{
+			FollowTargetQuestion: tt.question,
+			FollowTargetAnswer:   tt.answer,
+			FlagTargetUser:       tt.user,
+			"tag":                false,
+		} {
+			if got := reviewActionApplies(tt.action, targetType); got != want {
+				t.Errorf("reviewActionApplies(%q, %q) = %v, want %v", tt.action, targetType, got, want)
+			}
+		}
+	}
+}
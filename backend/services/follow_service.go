@@ -0,0 +1,62 @@
+// services/follow_service.go
+package services
+
+import (
+	"errors"
+
+	"stackit/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	FollowTargetQuestion = "question"
+	FollowTargetAnswer   = "answer"
+)
+
+var ErrFollowTargetNotFound = errors.New("question or answer not found")
+
+type FollowService struct {
+	DB *gorm.DB
+}
+
+func NewFollowService(db *gorm.DB) *FollowService {
+	return &FollowService{DB: db}
+}
+
+// Follow subscribes the user to a question or answer. Following twice is a no-op.
+func (s *FollowService) Follow(userID uint, targetType string, targetID uint) error {
+	var model interface{} = &models.Question{}
+	if targetType == FollowTargetAnswer {
+		model = &models.Answer{}
+	}
+	var count int64
+	if err := s.DB.Model(model).Where("id = ?", targetID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrFollowTargetNotFound
+	}
+	return followTarget(s.DB, userID, targetType, targetID)
+}
+
+func (s *FollowService) Unfollow(userID uint, targetType string, targetID uint) error {
+	return s.DB.Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).
+		Delete(&models.Follow{}).Error
+}
+
+func (s *FollowService) IsFollowing(userID uint, targetType string, targetID uint) (bool, error) {
+	var count int64
+	err := s.DB.Model(&models.Follow{}).
+		Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// followTarget records a follow without checking the target, for use inside the
+// transactions that create it (authors follow their own posts).
+func followTarget(db *gorm.DB, userID uint, targetType string, targetID uint) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Follow{UserID: userID, TargetType: targetType, TargetID: targetID}).Error
+}
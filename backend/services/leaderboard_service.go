@@ -0,0 +1,161 @@
+// services/leaderboard_service.go
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+)
+
+// Leaderboard periods
+const (
+	LeaderboardWeek    = "week"
+	LeaderboardMonth   = "month"
+	LeaderboardQuarter = "quarter"
+	LeaderboardAllTime = "all"
+)
+
+// Rolling window of each period; zero means all time.
+var leaderboardPeriods = map[string]time.Duration{
+	LeaderboardWeek:    7 * 24 * time.Hour,
+	LeaderboardMonth:   30 * 24 * time.Hour,
+	LeaderboardQuarter: 90 * 24 * time.Hour,
+	LeaderboardAllTime: 0,
+}
+
+// Leaderboard metrics, mapped to the column they rank by
+var leaderboardMetrics = map[string]string{
+	"reputation":       "reputation",
+	"accepted_answers": "accepted_answers",
+	"answer_score":     "answer_score",
+}
+
+// Aggregates over reputation_events e. A reversal counts against the event it undoes,
+// so undone votes and acceptances drop out; counting events rather than summing deltas
+// keeps capped (zero-amount) upvotes in the answer score.
+const leaderboardAggregates = `SUM(e.delta),
+	SUM(CASE WHEN e.kind = 'answer_accepted' THEN CASE WHEN e.reverses_id IS NULL THEN 1 ELSE -1 END ELSE 0 END),
+	SUM(CASE WHEN e.kind = 'upvote_received' THEN CASE WHEN e.reverses_id IS NULL THEN 1 ELSE -1 END
+		WHEN e.kind = 'downvote_received' THEN CASE WHEN e.reverses_id IS NULL THEN -1 ELSE 1 END
+		ELSE 0 END)`
+
+// leaderboardEvents and leaderboardSince date each reversal by the event it undoes, so
+// both fall in the same periods: undoing last month's vote today must not leave a
+// negative week.
+const leaderboardEvents = `reputation_events e
+	LEFT JOIN reputation_events reversed ON reversed.id = e.reverses_id`
+
+const leaderboardSince = `COALESCE(reversed.created_at, e.created_at) >= ?`
+
+// ValidLeaderboardPeriod reports whether period is one of the leaderboard periods.
+func ValidLeaderboardPeriod(period string) bool {
+	_, ok := leaderboardPeriods[period]
+	return ok
+}
+
+// ValidLeaderboardMetric reports whether metric is one of the leaderboard metrics.
+func ValidLeaderboardMetric(metric string) bool {
+	_, ok := leaderboardMetrics[metric]
+	return ok
+}
+
+type LeaderboardService struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewLeaderboardService(db *gorm.DB, cfg *config.Config) *LeaderboardService {
+	return &LeaderboardService{DB: db, Config: cfg}
+}
+
+// Refresh rebuilds every period, overall and per tag, from the reputation ledger in a
+// single transaction so readers never see a half-built table.
+func (s *LeaderboardService) Refresh() error {
+	now := time.Now()
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM leaderboard_entries`).Error; err != nil {
+			return err
+		}
+		for period, window := range leaderboardPeriods {
+			var since time.Time
+			if window > 0 {
+				since = now.Add(-window)
+			}
+			if err := tx.Exec(`INSERT INTO leaderboard_entries
+				(period, tag_id, user_id, reputation, accepted_answers, answer_score, refreshed_at)
+				SELECT ?, 0, e.user_id, `+leaderboardAggregates+`, ?
+				FROM `+leaderboardEvents+`
+				WHERE `+leaderboardSince+`
+				GROUP BY e.user_id`, period, now, since).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`INSERT INTO leaderboard_entries
+				(period, tag_id, user_id, reputation, accepted_answers, answer_score, refreshed_at)
+				SELECT ?, qt.tag_id, e.user_id, `+leaderboardAggregates+`, ?
+				FROM `+leaderboardEvents+`
+				JOIN question_tags qt ON qt.question_id = e.question_id
+				WHERE `+leaderboardSince+`
+				GROUP BY qt.tag_id, e.user_id`, period, now, since).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RunRefresher rebuilds the leaderboards every LeaderboardRefreshMinutes until ctx is
+// cancelled.
+func (s *LeaderboardService) RunRefresher(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.Config.LeaderboardRefreshMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := s.Refresh(); err != nil {
+			log.Printf("Leaderboard refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *LeaderboardService) entries(period, metric string, tagID uint) *gorm.DB {
+	return s.DB.Model(&models.LeaderboardEntry{}).
+		Where("period = ? AND tag_id = ? AND "+leaderboardMetrics[metric]+" > 0", period, tagID)
+}
+
+// GetLeaderboard returns a page of users ranked by metric within the period, overall
+// when tagID is 0. Pages use offsets so the caller can number the ranks.
+func (s *LeaderboardService) GetLeaderboard(period, metric string, tagID uint, page pagination.Params) ([]models.LeaderboardEntry, int, *pagination.Cursor, error) {
+	sort := period + ":" + metric
+	if err := page.CheckSort(sort); err != nil {
+		return nil, 0, nil, err
+	}
+	offset := page.Offset()
+
+	var entries []models.LeaderboardEntry
+	err := s.entries(period, metric, tagID).Preload("User").
+		Order(leaderboardMetrics[metric] + " DESC").Order("user_id ASC").
+		Offset(offset).Limit(page.Limit + 1).Find(&entries).Error
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	entries, hasMore := pagination.Trim(entries, page.Limit)
+	if !hasMore {
+		return entries, offset, nil, nil
+	}
+	return entries, offset, &pagination.Cursor{Sort: sort, Offset: offset + len(entries)}, nil
+}
+
+func (s *LeaderboardService) CountLeaderboard(period, metric string, tagID uint) (int64, error) {
+	var count int64
+	err := s.entries(period, metric, tagID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,111 @@
+// services/notification_dispatcher.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"stackit/config"
+	"stackit/models"
+
+	"gorm.io/gorm"
+)
+
+// Notification kinds sent to followers
+const (
+	NotificationKindNewAnswer        = "new_answer"
+	NotificationKindAnswerAccepted   = "answer_accepted"
+	NotificationKindQuestionClosed   = "question_closed"
+	NotificationKindQuestionReopened = "question_reopened"
+)
+
+var followerMessages = map[string]string{
+	NotificationKindNewAnswer:        "New answer on: %s",
+	NotificationKindAnswerAccepted:   "An answer was accepted on: %s",
+	NotificationKindQuestionClosed:   "Question closed: %s",
+	NotificationKindQuestionReopened: "Question reopened: %s",
+}
+
+// ActivityEvent is something followers of a question (and, for answer events, of the
+// answer) are told about. NotificationKindWatchedTag events go to the watchers of the
+// question's tags instead, see FeedService.NotifyTagWatchers.
+type ActivityEvent struct {
+	Kind       string
+	QuestionID uint
+	AnswerID   *uint // Followers of this answer are notified too
+	ActorID    uint  // Never notified about their own action
+}
+
+// NotificationDispatcher fans activity out to followers on a background goroutine,
+// so request latency does not grow with the number of followers.
+type NotificationDispatcher struct {
+	DB     *gorm.DB
+	Feed   *FeedService // Delivers watched tag events
+	events chan ActivityEvent
+}
+
+func NewNotificationDispatcher(db *gorm.DB, cfg *config.Config, queueSize int) *NotificationDispatcher {
+	return &NotificationDispatcher{DB: db, Feed: NewFeedService(db, cfg), events: make(chan ActivityEvent, queueSize)}
+}
+
+// Publish queues an event without blocking. Events are dropped (and logged) when the
+// queue is full rather than stalling the request.
+func (d *NotificationDispatcher) Publish(event ActivityEvent) {
+	select {
+	case d.events <- event:
+	default:
+		log.Printf("Notification queue full, dropping %s event for question %d", event.Kind, event.QuestionID)
+	}
+}
+
+// Run delivers queued events until ctx is cancelled, then drains what is left.
+func (d *NotificationDispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case event := <-d.events:
+			d.deliverAndLog(event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-d.events:
+					d.deliverAndLog(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *NotificationDispatcher) deliverAndLog(event ActivityEvent) {
+	if err := d.deliver(event); err != nil {
+		log.Printf("Failed to deliver %s notifications for question %d: %v", event.Kind, event.QuestionID, err)
+	}
+}
+
+// deliver inserts one notification per follower with a single INSERT ... SELECT.
+func (d *NotificationDispatcher) deliver(event ActivityEvent) error {
+	if event.Kind == NotificationKindWatchedTag {
+		_, err := d.Feed.NotifyTagWatchers(event.QuestionID)
+		return err
+	}
+
+	var question models.Question
+	if err := d.DB.Select("id", "title").First(&question, event.QuestionID).Error; err != nil {
+		return err
+	}
+	var answerID uint
+	if event.AnswerID != nil {
+		answerID = *event.AnswerID
+	}
+
+	message := fmt.Sprintf(followerMessages[event.Kind], question.Title)
+	return d.DB.Exec(`INSERT INTO notifications (created_at, updated_at, user_id, message, is_read, kind, question_id)
+		SELECT DISTINCT NOW(), NOW(), f.user_id, ?, false, ?, ?::bigint FROM follows f
+		WHERE ((f.target_type = ? AND f.target_id = ?) OR (f.target_type = ? AND f.target_id = ?))
+		AND f.user_id <> ?`,
+		message, event.Kind, question.ID,
+		FollowTargetQuestion, question.ID, FollowTargetAnswer, answerID,
+		event.ActorID).Error
+}
@@ -0,0 +1,101 @@
+// services/privilege_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"stackit/config"
+	"stackit/models"
+
+	"gorm.io/gorm"
+)
+
+// Privilege names
+const (
+	PrivilegeUpvote     = "upvote"
+	PrivilegeComment    = "comment"
+	PrivilegeDownvote   = "downvote"
+	PrivilegeEditOthers = "edit_others"
+	PrivilegeVoteClose  = "vote_close"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+// Privilege is an action unlocked at a reputation threshold.
+type Privilege struct {
+	Name        string
+	Description string
+	Reputation  int
+}
+
+// PrivilegeError reports an action the user does not yet have the reputation for.
+type PrivilegeError struct {
+	Privilege  Privilege
+	Reputation int // The user's current reputation
+}
+
+func (e *PrivilegeError) Error() string {
+	return fmt.Sprintf("%s requires %d reputation, you have %d", e.Privilege.Description, e.Privilege.Reputation, e.Reputation)
+}
+
+type PrivilegeService struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewPrivilegeService(db *gorm.DB, cfg *config.Config) *PrivilegeService {
+	return &PrivilegeService{DB: db, Config: cfg}
+}
+
+// Privileges returns the privilege table ordered by required reputation.
+func (s *PrivilegeService) Privileges() []Privilege {
+	privileges := []Privilege{
+		{Name: PrivilegeUpvote, Description: "Upvoting", Reputation: s.Config.PrivilegeUpvote},
+		{Name: PrivilegeComment, Description: "Commenting", Reputation: s.Config.PrivilegeComment},
+		{Name: PrivilegeDownvote, Description: "Downvoting", Reputation: s.Config.PrivilegeDownvote},
+		{Name: PrivilegeEditOthers, Description: "Editing content written by others", Reputation: s.Config.PrivilegeEditOthers},
+		{Name: PrivilegeVoteClose, Description: "Voting to close or reopen questions", Reputation: s.Config.PrivilegeVoteClose},
+	}
+	sort.SliceStable(privileges, func(i, j int) bool { return privileges[i].Reputation < privileges[j].Reputation })
+	return privileges
+}
+
+func (s *PrivilegeService) privilege(name string) Privilege {
+	for _, p := range s.Privileges() {
+		if p.Name == name {
+			return p
+		}
+	}
+	panic("unknown privilege " + name)
+}
+
+// Require returns a *PrivilegeError when the user lacks the named privilege.
+// Moderators hold every privilege regardless of reputation.
+func (s *PrivilegeService) Require(userID uint, isModerator bool, name string) error {
+	if isModerator {
+		return nil
+	}
+	reputation, err := s.Reputation(userID)
+	if err != nil {
+		return err
+	}
+	privilege := s.privilege(name)
+	if reputation < privilege.Reputation {
+		return &PrivilegeError{Privilege: privilege, Reputation: reputation}
+	}
+	return nil
+}
+
+// Reputation returns the user's cached reputation.
+func (s *PrivilegeService) Reputation(userID uint) (int, error) {
+	var user models.User
+	if err := s.DB.Select("reputation").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrUserNotFound
+		}
+		return 0, err
+	}
+	return user.Reputation, nil
+}
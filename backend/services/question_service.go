@@ -2,14 +2,24 @@
 package services
 
 import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"stackit/config"
 	"stackit/models"
+	"stackit/pagination"
 	"stackit/schemas"
+	"stackit/utils"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type QuestionService struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Filter *ContentFilter // Screens new questions; nil posts them unscreened
 }
 
 func NewQuestionService(db *gorm.DB) *QuestionService {
@@ -17,56 +27,225 @@ func NewQuestionService(db *gorm.DB) *QuestionService {
 }
 
 func (s *QuestionService) CreateQuestion(questionCreate *schemas.QuestionCreate, ownerID uint) (*models.Question, error) {
-	question := models.Question{
-		Title:       questionCreate.Title,
-		Description: questionCreate.Description,
-		OwnerID:     ownerID,
+	format := questionCreate.ContentFormat
+	if format == "" {
+		format = utils.ContentFormatHTML
 	}
-
-	if err := s.DB.Create(&question).Error; err != nil {
+	rendered, err := utils.RenderContent(questionCreate.Description, format)
+	if err != nil {
+		return nil, err
+	}
+	quarantinedAt, reason, err := screenPost(s.Filter, FollowTargetQuestion, ownerID, questionCreate.Title, questionCreate.Description)
+	if err != nil {
 		return nil, err
 	}
 
-	// Handle tags
-	for _, tagName := range questionCreate.Tags {
-		tag, err := s.GetOrCreateTag(tagName)
-		if err != nil {
-			return nil, err
+	question := models.Question{
+		Title:            questionCreate.Title,
+		Description:      questionCreate.Description,
+		ContentFormat:    format,
+		DescriptionHTML:  rendered,
+		OwnerID:          ownerID,
+		LastActivityAt:   time.Now(),
+		HiddenAt:         quarantinedAt,
+		QuarantinedAt:    quarantinedAt,
+		QuarantineReason: reason,
+		ContentHash:      contentHash(questionCreate.Description),
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		txService := &QuestionService{DB: tx}
+		if err := tx.Create(&question).Error; err != nil {
+			return err
 		}
-		questionTag := models.QuestionTag{
-			QuestionID: question.ID,
-			TagID:      tag.ID,
+
+		// Handle tags; synonyms resolve to their master, so two names can yield the same tag
+		linked := map[uint]bool{}
+		for _, tagName := range questionCreate.Tags {
+			tag, err := txService.GetOrCreateTag(tagName)
+			if err != nil {
+				return err
+			}
+			if linked[tag.ID] {
+				continue
+			}
+			linked[tag.ID] = true
+			questionTag := models.QuestionTag{
+				QuestionID: question.ID,
+				TagID:      tag.ID,
+			}
+			if err := tx.Create(&questionTag).Error; err != nil {
+				return err
+			}
 		}
-		if err := s.DB.Create(&questionTag).Error; err != nil {
-			return nil, err
+
+		if err := followTarget(tx, ownerID, FollowTargetQuestion, question.ID); err != nil {
+			return err
 		}
+		return linkAttachments(tx, ownerID, questionCreate.AttachmentIDs, &question.ID, nil)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Reload question to include associated tags
 	return s.GetQuestionByID(question.ID)
 }
 
-func (s *QuestionService) GetQuestions(offset, limit int) ([]models.Question, error) {
+// Sort modes accepted by GetQuestions
+const (
+	SortNewest     = "newest"
+	SortActive     = "active"
+	SortVotes      = "votes"
+	SortUnanswered = "unanswered"
+	SortHot        = "hot"
+	SortFrequent   = "frequent"
+)
+
+var questionSortOrders = map[string]string{
+	SortNewest:     "questions.created_at DESC, questions.id DESC",
+	SortActive:     "questions.last_activity_at DESC, questions.id DESC",
+	SortVotes:      "questions.score DESC, questions.id DESC",
+	SortUnanswered: "questions.created_at DESC, questions.id DESC",
+	SortHot:        "questions.hot_score DESC, questions.id DESC", // See RankingService
+	// Most often linked to as the canonical question of duplicates
+	SortFrequent: "(SELECT COUNT(*) FROM questions d WHERE d.duplicate_of_id = questions.id AND d.deleted_at IS NULL) DESC, questions.score DESC, questions.id DESC",
+}
+
+func IsValidQuestionSort(sort string) bool {
+	_, ok := questionSortOrders[sort]
+	return ok
+}
+
+type QuestionFilter struct {
+	Sort         string   // One of the Sort* constants; defaults to newest
+	Tags         []string // Matched case-insensitively
+	MatchAllTags bool     // Require every tag instead of any
+	Author       string   // Username
+	Accepted     *bool
+	From         *time.Time // Inclusive
+	To           *time.Time // Exclusive
+}
+
+func (s *QuestionService) filteredQuestions(filter QuestionFilter) *gorm.DB {
+	db := s.DB.Model(&models.Question{}).Where("questions.hidden_at IS NULL")
+	if filter.Sort == SortUnanswered {
+		db = db.Where("questions.answer_count = 0")
+	}
+	if len(filter.Tags) > 0 {
+		lowerTags := []string{}
+		for _, t := range filter.Tags {
+			lowerTags = append(lowerTags, strings.ToLower(t))
+		}
+		required := 1
+		if filter.MatchAllTags {
+			required = len(lowerTags)
+		}
+		db = db.Where(`questions.id IN (SELECT qt.question_id FROM question_tags qt JOIN tags t ON t.id = qt.tag_id
+			WHERE LOWER(t.name) IN ? GROUP BY qt.question_id HAVING COUNT(DISTINCT t.id) >= ?)`, lowerTags, required)
+	}
+	if filter.Author != "" {
+		db = db.Where("questions.owner_id = (SELECT id FROM users WHERE username = ? AND deleted_at IS NULL)", filter.Author)
+	}
+	if filter.Accepted != nil {
+		db = db.Where("questions.has_accepted = ?", *filter.Accepted)
+	}
+	if filter.From != nil {
+		db = db.Where("questions.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("questions.created_at < ?", *filter.To)
+	}
+	return db
+}
+
+// GetQuestions returns one page of questions and the cursor of the next page, if any.
+// Sorts backed by a column are keyset-paginated; computed orderings use offsets.
+func (s *QuestionService) GetQuestions(filter QuestionFilter, page pagination.Params) ([]models.Question, *pagination.Cursor, error) {
+	order, ok := questionSortOrders[filter.Sort]
+	if !ok {
+		filter.Sort = SortNewest
+		order = questionSortOrders[SortNewest]
+	}
+	if err := page.CheckSort(filter.Sort); err != nil {
+		return nil, nil, err
+	}
+
+	db := s.filteredQuestions(filter)
+	if after := page.After; after != nil {
+		switch filter.Sort {
+		case SortNewest, SortUnanswered:
+			db = db.Where("(questions.created_at, questions.id) < (?, ?)", after.Time, after.ID)
+		case SortActive:
+			db = db.Where("(questions.last_activity_at, questions.id) < (?, ?)", after.Time, after.ID)
+		case SortVotes:
+			db = db.Where("(questions.score, questions.id) < (?, ?)", after.Int, after.ID)
+		case SortHot:
+			db = db.Where("(questions.hot_score, questions.id) < (?, ?)", after.Float, after.ID)
+		default:
+			db = db.Offset(after.Offset)
+		}
+	}
+
 	var questions []models.Question
-	if err := s.DB.Preload("Tags.Tag").Offset(offset).Limit(limit).Find(&questions).Error; err != nil {
-		return nil, err
+	if err := db.Preload("Tags.Tag").Order(order).Limit(page.Limit + 1).Find(&questions).Error; err != nil {
+		return nil, nil, err
+	}
+	questions, hasMore := pagination.Trim(questions, page.Limit)
+	if !hasMore {
+		return questions, nil, nil
 	}
-	return questions, nil
+
+	last := questions[len(questions)-1]
+	next := &pagination.Cursor{Sort: filter.Sort, ID: last.ID}
+	switch filter.Sort {
+	case SortNewest, SortUnanswered:
+		next.Time = last.CreatedAt
+	case SortActive:
+		next.Time = last.LastActivityAt
+	case SortVotes:
+		next.Int = int64(last.Score)
+	case SortHot:
+		next.Float = last.HotScore
+	default:
+		next.Offset = page.Offset() + len(questions)
+	}
+	return questions, next, nil
+}
+
+func (s *QuestionService) CountQuestions(filter QuestionFilter) (int64, error) {
+	var count int64
+	err := s.filteredQuestions(filter).Count(&count).Error
+	return count, err
 }
 
 func (s *QuestionService) GetQuestionByID(id uint) (*models.Question, error) {
 	var question models.Question
-	if err := s.DB.Preload("Tags.Tag").First(&question, id).Error; err != nil {
+	if err := s.DB.Preload("Tags.Tag").Preload("Attachments").First(&question, id).Error; err != nil {
 		return nil, err
 	}
 	return &question, nil
 }
 
+// GetOrCreateTag normalizes the name and returns the matching tag, following synonyms
+// to their master tag. Unknown names create a new tag.
 func (s *QuestionService) GetOrCreateTag(tagName string) (*models.Tag, error) {
+	name, err := utils.NormalizeTagName(tagName)
+	if err != nil {
+		return nil, err
+	}
+
+	var synonym models.TagSynonym
+	if err := s.DB.Preload("Tag").Where("name = ?", name).First(&synonym).Error; err == nil {
+		return &synonym.Tag, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
 	var tag models.Tag
-	if err := s.DB.Where("name = ?", tagName).First(&tag).Error; err != nil {
+	if err := s.DB.Where("name = ?", name).First(&tag).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			tag = models.Tag{Name: tagName}
+			tag = models.Tag{Name: name}
 			if err := s.DB.Create(&tag).Error; err != nil {
 				return nil, err
 			}
@@ -76,3 +255,341 @@ func (s *QuestionService) GetOrCreateTag(tagName string) (*models.Tag, error) {
 	}
 	return &tag, nil
 }
+
+type SimilarQuestion struct {
+	ID              uint
+	Title           string
+	TitleSimilarity float64
+	SharedTags      int
+	Score           float64 // Combined ranking score in [0, 1]
+}
+
+// FindSimilarQuestions returns likely duplicates of a draft, ranked by trigram
+// similarity of the title blended with the share of draft tags the candidate carries.
+func (s *QuestionService) FindSimilarQuestions(title string, tags []string, limit int) ([]SimilarQuestion, error) {
+	lowerTags := []string{}
+	for _, t := range tags {
+		lowerTags = append(lowerTags, strings.ToLower(strings.TrimSpace(t)))
+	}
+
+	// Over-fetch by title similarity (the % operator uses the trigram index), then re-rank with tags
+	var candidates []SimilarQuestion
+	err := s.DB.Table("questions q").
+		Select(`q.id, q.title, similarity(q.title, ?) AS title_similarity,
+			(SELECT COUNT(*) FROM question_tags qt JOIN tags t ON t.id = qt.tag_id
+			 WHERE qt.question_id = q.id AND LOWER(t.name) IN ?) AS shared_tags`, title, lowerTags).
+		Where("q.deleted_at IS NULL AND q.hidden_at IS NULL AND q.title % ?", title).
+		Order("title_similarity DESC").
+		Limit(limit * 5).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		c.Score = c.TitleSimilarity
+		if len(lowerTags) > 0 {
+			c.Score = 0.75*c.TitleSimilarity + 0.25*float64(c.SharedTags)/float64(len(lowerTags))
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+const (
+	QuestionStatusOpen   = "open"
+	QuestionStatusClosed = "closed"
+	QuestionStatusLocked = "locked"
+
+	CloseReasonDuplicate = "duplicate"
+)
+
+var (
+	ErrQuestionNotOpen       = errors.New("question is not open")
+	ErrQuestionNotClosed     = errors.New("question is not closed")
+	ErrQuestionLocked        = errors.New("question is locked")
+	ErrAlreadyVotedToClose   = errors.New("you have already voted on this question")
+	ErrInvalidDuplicateOf    = errors.New("duplicate target must be a different, existing question")
+	ErrDuplicateOfIsRequired = errors.New("duplicate_of_id is required when closing as duplicate")
+)
+
+// VoteToClose records a close vote. The question closes once cfg.CloseVotesRequired
+// votes are in, or immediately when cast by a moderator. The winning reason (and
+// duplicate target) is the one with the most votes; a moderator's choice wins outright
+// and is audited. The actor is the voter.
+func (s *QuestionService) VoteToClose(actor AuditActor, questionID uint, isModerator bool, reason string, duplicateOfID *uint, votesRequired int) (*models.Question, error) {
+	userID := *actor.UserID
+	if reason == CloseReasonDuplicate {
+		if duplicateOfID == nil {
+			return nil, ErrDuplicateOfIsRequired
+		}
+		canonical, err := s.canonicalQuestionID(*duplicateOfID)
+		if err != nil || canonical == questionID {
+			return nil, ErrInvalidDuplicateOf
+		}
+		duplicateOfID = &canonical
+	} else {
+		duplicateOfID = nil
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var question models.Question
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&question, questionID).Error; err != nil {
+			return err
+		}
+		if question.Status != QuestionStatusOpen {
+			return ErrQuestionNotOpen
+		}
+
+		vote := models.CloseVote{QuestionID: questionID, UserID: userID, Kind: "close", Reason: reason, DuplicateOfID: duplicateOfID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyVotedToClose
+		}
+
+		var votes []models.CloseVote
+		if err := tx.Where("question_id = ? AND kind = ?", questionID, "close").Order("created_at").Find(&votes).Error; err != nil {
+			return err
+		}
+		if !isModerator && len(votes) < votesRequired {
+			return nil
+		}
+		if !isModerator {
+			reason, duplicateOfID = pluralityCloseReason(votes)
+		}
+
+		now := time.Now()
+		question.Status = QuestionStatusClosed
+		question.CloseReason = reason
+		question.DuplicateOfID = duplicateOfID
+		question.ClosedAt = &now
+		if err := tx.Save(&question).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("question_id = ?", questionID).Delete(&models.CloseVote{}).Error; err != nil {
+			return err
+		}
+		if !isModerator {
+			return nil
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditQuestionClosed,
+			TargetType: FollowTargetQuestion,
+			TargetID:   &questionID,
+			Diff: map[string]AuditChange{
+				"status":          {From: QuestionStatusOpen, To: QuestionStatusClosed},
+				"close_reason":    {From: "", To: reason},
+				"duplicate_of_id": {From: nil, To: duplicateOfID},
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetQuestionByID(questionID)
+}
+
+// VoteToReopen records a reopen vote on a closed question, reopening it under the same
+// rules as VoteToClose. Locked questions can only be unlocked by a moderator.
+func (s *QuestionService) VoteToReopen(actor AuditActor, questionID uint, isModerator bool, votesRequired int) (*models.Question, error) {
+	userID := *actor.UserID
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var question models.Question
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&question, questionID).Error; err != nil {
+			return err
+		}
+		if question.Status != QuestionStatusClosed {
+			return ErrQuestionNotClosed
+		}
+
+		vote := models.CloseVote{QuestionID: questionID, UserID: userID, Kind: "reopen"}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyVotedToClose
+		}
+
+		var count int64
+		if err := tx.Model(&models.CloseVote{}).Where("question_id = ? AND kind = ?", questionID, "reopen").Count(&count).Error; err != nil {
+			return err
+		}
+		if !isModerator && count < int64(votesRequired) {
+			return nil
+		}
+
+		diff := map[string]AuditChange{
+			"status":          {From: question.Status, To: QuestionStatusOpen},
+			"close_reason":    {From: question.CloseReason, To: ""},
+			"duplicate_of_id": {From: question.DuplicateOfID, To: nil},
+		}
+		question.Status = QuestionStatusOpen
+		question.CloseReason = ""
+		question.DuplicateOfID = nil
+		question.ClosedAt = nil
+		if err := tx.Save(&question).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("question_id = ?", questionID).Delete(&models.CloseVote{}).Error; err != nil {
+			return err
+		}
+		if !isModerator {
+			return nil
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditQuestionReopened,
+			TargetType: FollowTargetQuestion,
+			TargetID:   &questionID,
+			Diff:       diff,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetQuestionByID(questionID)
+}
+
+// SetLocked locks or unlocks a question. Locking freezes all activity; unlocking
+// returns the question to the state it was locked from.
+func (s *QuestionService) SetLocked(actor AuditActor, questionID uint, locked bool) (*models.Question, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		entry, err := setLocked(tx, questionID, locked)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetQuestionByID(questionID)
+}
+
+// setLocked applies SetLocked on tx and returns the audit entry for the caller to
+// record once the rest of its transaction is done. Locking keeps the close reason and
+// duplicate link and saves the status and close time it replaces, for unlocking to
+// restore. Locking a locked question or unlocking an unlocked one changes nothing.
+func setLocked(tx *gorm.DB, questionID uint, locked bool) (AuditEntry, error) {
+	var question models.Question
+	if err := tx.First(&question, questionID).Error; err != nil {
+		return AuditEntry{}, err
+	}
+
+	action := AuditQuestionUnlocked
+	status := question.Status
+	updates := map[string]interface{}{}
+	if locked {
+		action = AuditQuestionLocked
+		if question.Status != QuestionStatusLocked {
+			status = QuestionStatusLocked
+			updates = map[string]interface{}{
+				"status": status, "closed_at": time.Now(),
+				"locked_from_status": question.Status, "locked_from_closed_at": question.ClosedAt,
+			}
+		}
+	} else if question.Status == QuestionStatusLocked {
+		// Questions locked before the previous state was saved go back to open
+		status = question.LockedFromStatus
+		if status == "" {
+			status = QuestionStatusOpen
+		}
+		updates = map[string]interface{}{
+			"status": status, "closed_at": question.LockedFromClosedAt,
+			"locked_from_status": "", "locked_from_closed_at": nil,
+		}
+		if status == QuestionStatusOpen {
+			updates["closed_at"], updates["close_reason"], updates["duplicate_of_id"] = nil, "", nil
+		}
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&question).Updates(updates).Error; err != nil {
+			return AuditEntry{}, err
+		}
+	}
+	if err := tx.Where("question_id = ?", questionID).Delete(&models.CloseVote{}).Error; err != nil {
+		return AuditEntry{}, err
+	}
+	return AuditEntry{
+		Action:     action,
+		TargetType: FollowTargetQuestion,
+		TargetID:   &questionID,
+		Diff:       map[string]AuditChange{"status": {From: question.Status, To: status}},
+	}, nil
+}
+
+// canonicalQuestionID follows duplicate links so duplicates always point at the original.
+func (s *QuestionService) canonicalQuestionID(id uint) (uint, error) {
+	for hops := 0; hops < 10; hops++ {
+		var question models.Question
+		if err := s.DB.Select("id", "duplicate_of_id").First(&question, id).Error; err != nil {
+			return 0, err
+		}
+		if question.DuplicateOfID == nil {
+			return question.ID, nil
+		}
+		id = *question.DuplicateOfID
+	}
+	return id, nil
+}
+
+func pluralityCloseReason(votes []models.CloseVote) (string, *uint) {
+	reasons := map[string]int{}
+	targets := map[uint]int{}
+	for _, v := range votes {
+		reasons[v.Reason]++
+		if v.DuplicateOfID != nil {
+			targets[*v.DuplicateOfID]++
+		}
+	}
+
+	reason, best := "", 0
+	for _, v := range votes { // Iterate votes, not the map, so ties go to the earliest vote
+		if reasons[v.Reason] > best {
+			reason, best = v.Reason, reasons[v.Reason]
+		}
+	}
+	if reason != CloseReasonDuplicate {
+		return reason, nil
+	}
+
+	var target *uint
+	best = 0
+	for _, v := range votes {
+		if v.DuplicateOfID != nil && targets[*v.DuplicateOfID] > best {
+			target, best = v.DuplicateOfID, targets[*v.DuplicateOfID]
+		}
+	}
+	return reason, target
+}
+
+// deleteQuestion soft-deletes a question and reverses the reputation earned by its
+// answers. Active bounties are refunded separately, see RefundQuestionBounties.
+func deleteQuestion(tx *gorm.DB, cfg *config.Config, questionID uint) error {
+	var answerIDs []uint
+	if err := tx.Model(&models.Answer{}).Where("question_id = ?", questionID).Pluck("id", &answerIDs).Error; err != nil {
+		return err
+	}
+	rep := &ReputationService{DB: tx, Config: cfg}
+	for _, id := range answerIDs {
+		if err := rep.ReverseAnswerEvents(id); err != nil {
+			return err
+		}
+	}
+
+	result := tx.Delete(&models.Question{}, questionID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
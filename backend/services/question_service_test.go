@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"stackit/models"
+)
+
+func TestPluralityCloseReason(t *testing.T) {
+	dup := func(id uint) *uint { return &id }
+	tests := []struct {
+		name       string
+		votes      []models.CloseVote
+		wantReason string
+		wantTarget *uint
+	}{
+		{
+			name: "no votes",
+		},
+		{
+			name:       "majority wins",
+			votes:      []models.CloseVote{{Reason: "unclear"}, {Reason: "off-topic"}, {Reason: "off-topic"}},
+			wantReason: "off-topic",
+		},
+		{
+			name:       "tie goes to the earliest vote",
+			votes:      []models.CloseVote{{Reason: "unclear"}, {Reason: "off-topic"}, {Reason: "off-topic"}, {Reason: "unclear"}},
+			wantReason: "unclear",
+		},
+		{
+			name: "duplicate takes the most voted target",
+			votes: []models.CloseVote{
+				{Reason: CloseReasonDuplicate, DuplicateOfID: dup(7)},
+				{Reason: CloseReasonDuplicate, DuplicateOfID: dup(9)},
+				{Reason: CloseReasonDuplicate, DuplicateOfID: dup(9)},
+			},
+			wantReason: CloseReasonDuplicate,
+			wantTarget: dup(9),
+		},
+		{
+			name: "duplicate target tie goes to the earliest vote",
+			votes: []models.CloseVote{
+				{Reason: CloseReasonDuplicate, DuplicateOfID: dup(9)},
+				{Reason: CloseReasonDuplicate, DuplicateOfID: dup(7)},
+				{Reason: "unclear"},
+			},
+			wantReason: CloseReasonDuplicate,
+			wantTarget: dup(9),
+		},
+		{
+			name: "duplicate targets are ignored when another reason wins",
+			votes: []models.CloseVote{
+				{Reason: CloseReasonDuplicate, DuplicateOfID: dup(7)},
+				{Reason: "too-broad"},
+				{Reason: "too-broad"},
+			},
+			wantReason: "too-broad",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, target := pluralityCloseReason(tt.votes)
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+			switch {
+			case target == nil && tt.wantTarget == nil:
+			case target == nil || tt.wantTarget == nil || *target != *tt.wantTarget:
+				t.Errorf("target = %v, want %v", target, tt.wantTarget)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// services/ranking_service.go
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RankingService struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewRankingService(db *gorm.DB, cfg *config.Config) *RankingService {
+	return &RankingService{DB: db, Config: cfg}
+}
+
+// pointsSQL is the undecayed weight of a question; the two parameters are the answer
+// and view weights.
+const pointsSQL = "(score + answer_count * ? + LN(1 + view_count) * ?)"
+
+// RecomputeHotScores refreshes hot_score for questions active within the hot window
+// and zeroes it for questions that have dropped out of the window.
+func (s *RankingService) RecomputeHotScores() (int64, error) {
+	cutoff := time.Now().Add(-time.Duration(s.Config.HotWindowHours) * time.Hour)
+
+	result := s.DB.Model(&models.Question{}).
+		Where("last_activity_at >= ?", cutoff).
+		UpdateColumn("hot_score", gorm.Expr(
+			pointsSQL+" / POWER(EXTRACT(EPOCH FROM (NOW() - created_at)) / 3600 + 2, ?)",
+			s.Config.HotAnswerWeight, s.Config.HotViewWeight, s.Config.HotGravity))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	if err := s.DB.Model(&models.Question{}).
+		Where("last_activity_at < ? AND hot_score <> 0", cutoff).
+		UpdateColumn("hot_score", 0).Error; err != nil {
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
+
+// RunHotScoreWorker recomputes hot scores every HotRecomputeMinutes until ctx is cancelled.
+func (s *RankingService) RunHotScoreWorker(ctx context.Context) {
+	interval := time.Duration(s.Config.HotRecomputeMinutes) * time.Minute
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RecomputeHotScores(); err != nil {
+			log.Printf("Hot score recompute failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetTrending returns the questions that gained the most weight within the window,
+// counting only answers posted inside it, optionally restricted to a tag.
+func (s *RankingService) GetTrending(window time.Duration, tag string, limit int) ([]models.Question, error) {
+	cutoff := time.Now().Add(-window)
+
+	db := s.DB.Model(&models.Question{}).
+		Where("questions.last_activity_at >= ? AND questions.hidden_at IS NULL", cutoff).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL: `(questions.score
+				+ (SELECT COUNT(*) FROM answers a WHERE a.question_id = questions.id AND a.created_at >= ? AND a.deleted_at IS NULL) * ?
+				+ LN(1 + questions.view_count) * ?) DESC, questions.hot_score DESC, questions.id DESC`,
+			Vars:               []interface{}{cutoff, s.Config.HotAnswerWeight, s.Config.HotViewWeight},
+			WithoutParentheses: true,
+		}})
+	if tag != "" {
+		db = db.Where(`questions.id IN (SELECT qt.question_id FROM question_tags qt JOIN tags t ON t.id = qt.tag_id
+			WHERE LOWER(t.name) = LOWER(?))`, tag)
+	}
+
+	var questions []models.Question
+	if err := db.Preload("Tags.Tag").Limit(limit).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
@@ -0,0 +1,204 @@
+// services/reputation_service.go
+package services
+
+import (
+	"errors"
+	"time"
+
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+)
+
+// Reputation event kinds
+const (
+	RepUpvoteReceived   = "upvote_received"
+	RepDownvoteReceived = "downvote_received"
+	RepDownvoteCast     = "downvote_cast"
+	RepAnswerAccepted   = "answer_accepted" // To the answer's author
+	RepAcceptedAnswer   = "accepted_answer" // To the question owner who accepted it
+	RepBountyOffered    = "bounty_offered"
+	RepBountyAwarded    = "bounty_awarded"
+	RepBountyRefunded   = "bounty_refunded"
+)
+
+// BaseReputation is what every user starts with; the cached reputation is this plus
+// the sum of the user's ledger.
+const BaseReputation = 1
+
+// Fixed amounts per kind. Bounty events carry their own amount.
+var reputationDeltas = map[string]int{
+	RepUpvoteReceived:   10,
+	RepDownvoteReceived: -2,
+	RepDownvoteCast:     -1,
+	RepAnswerAccepted:   15,
+	RepAcceptedAnswer:   2,
+}
+
+// Kinds whose gains count towards ReputationDailyCap
+var cappedReputationKinds = []string{RepUpvoteReceived}
+
+// ReputationRef points a ledger entry at the post and user behind it.
+type ReputationRef struct {
+	QuestionID *uint
+	AnswerID   *uint
+	ActorID    *uint
+}
+
+type ReputationService struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewReputationService(db *gorm.DB, cfg *config.Config) *ReputationService {
+	return &ReputationService{DB: db, Config: cfg}
+}
+
+// Record appends an event with the kind's fixed amount and updates the cached reputation.
+// Run it on a transaction-bound service so the ledger moves with the action.
+func (s *ReputationService) Record(userID uint, kind string, ref ReputationRef) error {
+	return s.RecordAmount(userID, kind, reputationDeltas[kind], ref)
+}
+
+// RecordAmount is Record with an explicit amount, for bounties.
+func (s *ReputationService) RecordAmount(userID uint, kind string, delta int, ref ReputationRef) error {
+	if delta > 0 && isCappedReputationKind(kind) && s.Config.ReputationDailyCap > 0 {
+		// Only gains count, so undoing a vote does not make room for another
+		var earnedToday int64
+		if err := s.DB.Model(&models.ReputationEvent{}).
+			Where("user_id = ? AND kind IN ? AND created_at >= ?", userID, cappedReputationKinds, startOfDayUTC()).
+			Where("delta > 0 AND reverses_id IS NULL").
+			Select("COALESCE(SUM(delta), 0)").Scan(&earnedToday).Error; err != nil {
+			return err
+		}
+		remaining := max(s.Config.ReputationDailyCap-int(earnedToday), 0)
+		delta = min(delta, remaining)
+	}
+
+	// Zero-amount events are still recorded so a later reversal has something to match
+	return s.append(models.ReputationEvent{
+		UserID:     userID,
+		Kind:       kind,
+		Delta:      delta,
+		QuestionID: ref.QuestionID,
+		AnswerID:   ref.AnswerID,
+		ActorID:    ref.ActorID,
+	})
+}
+
+// Reverse appends a reversal of the latest unreversed event of the kind matching the
+// answer and actor of ref. It is a no-op when there is nothing to reverse.
+func (s *ReputationService) Reverse(userID uint, kind string, ref ReputationRef) error {
+	db := s.unreversed().Where("user_id = ? AND kind = ?", userID, kind)
+	if ref.AnswerID != nil {
+		db = db.Where("answer_id = ?", *ref.AnswerID)
+	}
+	if ref.QuestionID != nil {
+		db = db.Where("question_id = ?", *ref.QuestionID)
+	}
+	if ref.ActorID != nil {
+		db = db.Where("actor_id = ?", *ref.ActorID)
+	}
+
+	var event models.ReputationEvent
+	if err := db.Order("id DESC").First(&event).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.reverseEvent(&event)
+}
+
+// ReverseAnswerEvents reverses every outstanding event tied to an answer, for when the
+// answer is deleted. An awarded bounty is kept: its offerer has paid for it and is not
+// refunded, so reversing it would destroy the reputation.
+func (s *ReputationService) ReverseAnswerEvents(answerID uint) error {
+	var events []models.ReputationEvent
+	if err := s.unreversed().Where("answer_id = ? AND kind <> ?", answerID, RepBountyAwarded).Find(&events).Error; err != nil {
+		return err
+	}
+	for i := range events {
+		if err := s.reverseEvent(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ReputationService) unreversed() *gorm.DB {
+	return s.DB.Model(&models.ReputationEvent{}).
+		Where("reverses_id IS NULL AND NOT EXISTS (SELECT 1 FROM reputation_events r WHERE r.reverses_id = reputation_events.id)")
+}
+
+func (s *ReputationService) reverseEvent(event *models.ReputationEvent) error {
+	return s.append(models.ReputationEvent{
+		UserID:     event.UserID,
+		Kind:       event.Kind,
+		Delta:      -event.Delta,
+		QuestionID: event.QuestionID,
+		AnswerID:   event.AnswerID,
+		ActorID:    event.ActorID,
+		ReversesID: &event.ID,
+	})
+}
+
+func (s *ReputationService) append(event models.ReputationEvent) error {
+	if err := s.DB.Create(&event).Error; err != nil {
+		return err
+	}
+	if event.Delta == 0 {
+		return nil
+	}
+	return s.DB.Model(&models.User{}).Where("id = ?", event.UserID).
+		UpdateColumn("reputation", gorm.Expr("reputation + ?", event.Delta)).Error
+}
+
+// GetHistory returns a page of a user's ledger, newest first.
+func (s *ReputationService) GetHistory(userID uint, page pagination.Params) ([]models.ReputationEvent, *pagination.Cursor, error) {
+	db := s.DB.Where("user_id = ?", userID).Order("id DESC")
+	if page.After != nil {
+		db = db.Where("id < ?", page.After.ID)
+	}
+
+	var events []models.ReputationEvent
+	if err := db.Limit(page.Limit + 1).Find(&events).Error; err != nil {
+		return nil, nil, err
+	}
+	events, hasMore := pagination.Trim(events, page.Limit)
+	if !hasMore {
+		return events, nil, nil
+	}
+	return events, &pagination.Cursor{ID: events[len(events)-1].ID}, nil
+}
+
+func (s *ReputationService) CountHistory(userID uint) (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.ReputationEvent{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// RecomputeAll rebuilds every cached reputation from the ledger and returns the number
+// of users whose value changed.
+func (s *ReputationService) RecomputeAll() (int64, error) {
+	result := s.DB.Exec(`UPDATE users SET reputation = t.total
+		FROM (SELECT u.id, ? + COALESCE(SUM(e.delta), 0) AS total
+			FROM users u LEFT JOIN reputation_events e ON e.user_id = u.id GROUP BY u.id) t
+		WHERE users.id = t.id AND users.reputation <> t.total`, BaseReputation)
+	return result.RowsAffected, result.Error
+}
+
+func isCappedReputationKind(kind string) bool {
+	for _, k := range cappedReputationKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func startOfDayUTC() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
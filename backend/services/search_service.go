@@ -0,0 +1,250 @@
+// services/search_service.go
+package services
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackit/pagination"
+
+	"gorm.io/gorm"
+)
+
+// SearchQuery is a parsed search string. Supported syntax:
+//
+//	[tag]              question has tag (repeatable, all must match)
+//	user:name          asked by user
+//	is:accepted        has an accepted answer
+//	is:unanswered      has no answers
+//	score:>=N          question score compared with N (>, >=, <, <=, =)
+//	created:>DATE      created relative to YYYY-MM-DD (same operators)
+//	"some phrase"      exact phrase
+//
+// Everything else is free text.
+type SearchQuery struct {
+	Terms        []string
+	Phrases      []string
+	Tags         []string
+	User         string
+	IsAccepted   bool
+	IsUnanswered bool
+	ScoreOp      string
+	Score        int
+	CreatedOp    string
+	Created      time.Time
+}
+
+// Text returns the free-text part in websearch_to_tsquery syntax.
+func (q *SearchQuery) Text() string {
+	parts := append([]string{}, q.Terms...)
+	for _, p := range q.Phrases {
+		parts = append(parts, `"`+p+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+func ParseSearchQuery(raw string) (*SearchQuery, error) {
+	q := &SearchQuery{}
+	for _, tok := range tokenizeSearch(raw) {
+		switch {
+		case strings.HasPrefix(tok, `"`):
+			if phrase := strings.TrimSpace(strings.Trim(tok, `"`)); phrase != "" {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+		case strings.HasPrefix(tok, "[") && strings.HasSuffix(tok, "]") && len(tok) > 2:
+			q.Tags = append(q.Tags, strings.ToLower(tok[1:len(tok)-1]))
+		case strings.HasPrefix(tok, "user:") && len(tok) > len("user:"):
+			q.User = tok[len("user:"):]
+		case tok == "is:accepted":
+			q.IsAccepted = true
+		case tok == "is:unanswered":
+			q.IsUnanswered = true
+		case strings.HasPrefix(tok, "score:"):
+			op, value := splitSearchOperator(tok[len("score:"):])
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid score filter: %s", tok)
+			}
+			q.ScoreOp, q.Score = op, n
+		case strings.HasPrefix(tok, "created:"):
+			op, value := splitSearchOperator(tok[len("created:"):])
+			t, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid created filter, expected YYYY-MM-DD: %s", tok)
+			}
+			q.CreatedOp, q.Created = op, t
+		default:
+			q.Terms = append(q.Terms, tok)
+		}
+	}
+	return q, nil
+}
+
+// tokenizeSearch splits on whitespace, keeping double-quoted phrases together.
+func tokenizeSearch(raw string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			if inQuotes {
+				cur.WriteRune(r)
+				flush()
+			} else {
+				flush()
+				cur.WriteRune(r)
+			}
+			inQuotes = !inQuotes
+		case !inQuotes && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func splitSearchOperator(s string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(s, op) {
+			return op, s[len(op):]
+		}
+	}
+	return "=", s
+}
+
+type SearchResult struct {
+	ID             uint
+	Title          string
+	TitleHighlight string
+	Snippet        string
+	OwnerID        uint
+	Rank           float64
+	Score          int
+	AnswerCount    int
+	HasAccepted    bool
+	CreatedAt      time.Time
+}
+
+type SearchService struct {
+	DB *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// Placeholders handed to ts_headline so the output can be HTML-escaped before
+// the real <mark> tags are inserted.
+const (
+	highlightStart = "{{{mark}}}"
+	highlightStop  = "{{{/mark}}}"
+)
+
+// matching builds the FROM and WHERE clauses shared by Search and Count.
+func (s *SearchService) matching(query *SearchQuery) *gorm.DB {
+	db := s.DB.Table("questions q")
+	if text := query.Text(); text != "" {
+		db = s.DB.Table("questions q CROSS JOIN websearch_to_tsquery('english', ?) AS query", text).
+			Where("(q.search_vector @@ query OR EXISTS (SELECT 1 FROM answers a WHERE a.question_id = q.id AND a.deleted_at IS NULL AND a.hidden_at IS NULL AND a.search_vector @@ query))")
+	}
+
+	db = db.Where("q.deleted_at IS NULL AND q.hidden_at IS NULL")
+	for _, tag := range query.Tags {
+		db = db.Where("EXISTS (SELECT 1 FROM question_tags qt JOIN tags t ON t.id = qt.tag_id WHERE qt.question_id = q.id AND LOWER(t.name) = ?)", tag)
+	}
+	if query.User != "" {
+		db = db.Where("q.owner_id = (SELECT id FROM users WHERE username = ? AND deleted_at IS NULL)", query.User)
+	}
+	if query.IsAccepted {
+		db = db.Where("q.has_accepted")
+	}
+	if query.IsUnanswered {
+		db = db.Where("q.answer_count = 0")
+	}
+	if query.ScoreOp != "" {
+		db = db.Where("q.score "+query.ScoreOp+" ?", query.Score)
+	}
+	if query.CreatedOp != "" {
+		cond, args := createdCondition(query.CreatedOp, query.Created)
+		db = db.Where(cond, args...)
+	}
+	return db
+}
+
+// Search returns one page of results. Rank is computed per query, so pages are
+// addressed by offset cursors rather than keysets.
+func (s *SearchService) Search(query *SearchQuery, page pagination.Params) ([]SearchResult, *pagination.Cursor, error) {
+	headlineOpts := fmt.Sprintf(`StartSel="%s",StopSel="%s"`, highlightStart, highlightStop)
+
+	db := s.matching(query)
+	if query.Text() != "" {
+		db = db.Select(`q.id, q.title, q.owner_id, q.created_at,
+				ts_headline('english', q.title, query, ?) AS title_highlight,
+				ts_headline('english', regexp_replace(COALESCE(NULLIF(q.description_html, ''), q.description), '<[^>]*>', ' ', 'g'), query, ?) AS snippet,
+				ts_rank(q.search_vector, query) + COALESCE((SELECT MAX(ts_rank(a.search_vector, query)) FROM answers a WHERE a.question_id = q.id AND a.deleted_at IS NULL AND a.hidden_at IS NULL), 0) AS rank,
+				q.score, q.answer_count, q.has_accepted`,
+			headlineOpts, headlineOpts+",MaxFragments=2,MaxWords=30,MinWords=10").
+			Order("rank DESC").Order("q.created_at DESC").Order("q.id DESC")
+	} else {
+		db = db.Select(`q.id, q.title, q.owner_id, q.created_at, q.title AS title_highlight,
+				LEFT(regexp_replace(COALESCE(NULLIF(q.description_html, ''), q.description), '<[^>]*>', ' ', 'g'), 200) AS snippet,
+				0 AS rank, q.score, q.answer_count, q.has_accepted`).
+			Order("q.created_at DESC").Order("q.id DESC")
+	}
+
+	var results []SearchResult
+	if err := db.Offset(page.Offset()).Limit(page.Limit + 1).Scan(&results).Error; err != nil {
+		return nil, nil, err
+	}
+	results, hasMore := pagination.Trim(results, page.Limit)
+	for i := range results {
+		results[i].TitleHighlight = renderHighlight(results[i].TitleHighlight)
+		results[i].Snippet = renderHighlight(results[i].Snippet)
+	}
+	if !hasMore {
+		return results, nil, nil
+	}
+	return results, &pagination.Cursor{Offset: page.Offset() + len(results)}, nil
+}
+
+func (s *SearchService) Count(query *SearchQuery) (int64, error) {
+	var count int64
+	err := s.matching(query).Count(&count).Error
+	return count, err
+}
+
+// createdCondition treats the date as a whole day, so created:>2026-01-01 starts on January 2nd.
+func createdCondition(op string, day time.Time) (string, []interface{}) {
+	next := day.AddDate(0, 0, 1)
+	switch op {
+	case ">":
+		return "q.created_at >= ?", []interface{}{next}
+	case ">=":
+		return "q.created_at >= ?", []interface{}{day}
+	case "<":
+		return "q.created_at < ?", []interface{}{day}
+	case "<=":
+		return "q.created_at < ?", []interface{}{next}
+	default:
+		return "q.created_at >= ? AND q.created_at < ?", []interface{}{day, next}
+	}
+}
+
+// renderHighlight HTML-escapes ts_headline output and turns the sentinels into <mark> tags.
+func renderHighlight(s string) string {
+	s = html.EscapeString(html.UnescapeString(strings.Join(strings.Fields(s), " ")))
+	s = strings.ReplaceAll(s, highlightStart, "<mark>")
+	return strings.ReplaceAll(s, highlightStop, "</mark>")
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want SearchQuery
+	}{
+		{
+			name: "empty",
+			raw:  "   ",
+			want: SearchQuery{},
+		},
+		{
+			name: "free text",
+			raw:  "nil pointer\tpanic",
+			want: SearchQuery{Terms: []string{"nil", "pointer", "panic"}},
+		},
+		{
+			name: "phrases",
+			raw:  `go "nil map" "" "  write  "`,
+			want: SearchQuery{Terms: []string{"go"}, Phrases: []string{"nil map", "write"}},
+		},
+		{
+			name: "unterminated phrase runs to the end",
+			raw:  `"nil map write`,
+			want: SearchQuery{Phrases: []string{"nil map write"}},
+		},
+		{
+			name: "tags are lowercased",
+			raw:  "[Go] [postgres] []",
+			want: SearchQuery{Tags: []string{"go", "postgres"}, Terms: []string{"[]"}},
+		},
+		{
+			name: "user and flags",
+			raw:  "user:alice is:accepted is:unanswered user:",
+			want: SearchQuery{User: "alice", IsAccepted: true, IsUnanswered: true, Terms: []string{"user:"}},
+		},
+		{
+			name: "score operators",
+			raw:  "score:>=5",
+			want: SearchQuery{ScoreOp: ">=", Score: 5},
+		},
+		{
+			name: "score without operator is equality",
+			raw:  "score:-2",
+			want: SearchQuery{ScoreOp: "=", Score: -2},
+		},
+		{
+			name: "created",
+			raw:  "created:<2024-03-01 deadlock",
+			want: SearchQuery{Terms: []string{"deadlock"}, CreatedOp: "<", Created: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSearchQuery(tt.raw)
+			if err != nil {
+				t.Fatalf("ParseSearchQuery(%q) error: %v", tt.raw, err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseSearchQuery(%q) = %+v, want %+v", tt.raw, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	for _, raw := range []string{"score:>many", "score:", "created:yesterday", "created:>=2024-13-01"} {
+		if _, err := ParseSearchQuery(raw); err == nil {
+			t.Errorf("ParseSearchQuery(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestSearchQueryText(t *testing.T) {
+	q := SearchQuery{Terms: []string{"nil", "panic"}, Phrases: []string{"map write"}}
+	if got, want := q.Text(), `nil panic "map write"`; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+// services/session_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"stackit/cache"
+	"stackit/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionRevoked   = errors.New("session has been revoked, please log in again")
+	ErrAccountBanned    = errors.New("account has been banned")
+	ErrAccountSuspended = errors.New("account is suspended")
+)
+
+// sessionState is the part of a user a token is checked against on every request.
+type sessionState struct {
+	Version        int
+	Role           string
+	SuspendedUntil *time.Time
+	Banned         bool
+}
+
+// SessionService checks tokens against the current state of their user, so forced
+// logouts, suspensions, bans and role changes apply to tokens already issued. State
+// is cached briefly; admin actions invalidate it immediately.
+type SessionService struct {
+	DB     *gorm.DB
+	states *cache.TTL[uint, sessionState]
+}
+
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{DB: db, states: cache.NewTTL[uint, sessionState](30*time.Second, 10000)}
+}
+
+// CheckSession returns the user's current role if a token issued at the given session
+// version is still valid.
+func (s *SessionService) CheckSession(userID uint, version int) (string, error) {
+	state, err := s.states.GetOrLoad(userID, func() (sessionState, error) {
+		var user models.User
+		if err := s.DB.Select("id", "role", "session_version", "suspended_until", "banned_at").
+			First(&user, userID).Error; err != nil {
+			return sessionState{}, err
+		}
+		return sessionState{
+			Version:        user.SessionVersion,
+			Role:           user.Role,
+			SuspendedUntil: user.SuspendedUntil,
+			Banned:         user.BannedAt != nil,
+		}, nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrSessionRevoked
+		}
+		return "", err
+	}
+	if state.Version != version {
+		return "", ErrSessionRevoked
+	}
+	if err := accountRestriction(state.Banned, state.SuspendedUntil); err != nil {
+		return "", err
+	}
+	return state.Role, nil
+}
+
+// Invalidate drops the cached state of a user after it changed.
+func (s *SessionService) Invalidate(userID uint) {
+	s.states.Delete(userID)
+}
+
+// accountRestriction reports why a banned or suspended account may not be used.
+func accountRestriction(banned bool, suspendedUntil *time.Time) error {
+	if banned {
+		return ErrAccountBanned
+	}
+	if suspendedUntil != nil && suspendedUntil.After(time.Now()) {
+		return fmt.Errorf("%w until %s", ErrAccountSuspended, suspendedUntil.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// services/spam_classifier.go
+package services
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"stackit/config"
+	"stackit/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	spamWordPattern = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}'_-]*`)
+	htmlTagPattern  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Most tokens considered per post, so a huge post cannot make a huge query
+const maxSpamTokens = 500
+
+// SpamClassifier is a naive Bayes classifier over the words and link hosts of posts,
+// trained from moderators' spam decisions: posts deleted as spam count as spam, posts
+// released from quarantine or whose spam flags were dismissed count as ham.
+type SpamClassifier struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewSpamClassifier(db *gorm.DB, cfg *config.Config) *SpamClassifier {
+	return &SpamClassifier{DB: db, Config: cfg}
+}
+
+// SpamProbability returns the probability that text is spam. The second result is
+// false, and the probability meaningless, until SpamClassifierMinPosts spam and ham
+// posts each have been trained.
+func (c *SpamClassifier) SpamProbability(text string) (float64, bool, error) {
+	var counts []struct {
+		IsSpam bool
+		Posts  int
+	}
+	if err := c.DB.Model(&models.SpamTrainingPost{}).Select("is_spam, COUNT(*) AS posts").
+		Group("is_spam").Scan(&counts).Error; err != nil {
+		return 0, false, err
+	}
+	var spamPosts, hamPosts int
+	for _, count := range counts {
+		if count.IsSpam {
+			spamPosts = count.Posts
+		} else {
+			hamPosts = count.Posts
+		}
+	}
+	if spamPosts < c.Config.SpamClassifierMinPosts || hamPosts < c.Config.SpamClassifierMinPosts {
+		return 0, false, nil
+	}
+
+	tokens := spamTokens(text)
+	if len(tokens) == 0 {
+		return 0, true, nil
+	}
+	var rows []models.SpamToken
+	if err := c.DB.Where("token IN ?", tokens).Find(&rows).Error; err != nil {
+		return 0, false, err
+	}
+
+	// Log odds of spam: the prior plus each known token's likelihood ratio, with
+	// Laplace smoothing. Tokens never seen in training carry no evidence and are skipped.
+	logOdds := math.Log(float64(spamPosts) / float64(hamPosts))
+	for _, row := range rows {
+		if row.Spam+row.Ham == 0 {
+			continue
+		}
+		pSpam := (float64(row.Spam) + 1) / (float64(spamPosts) + 2)
+		pHam := (float64(row.Ham) + 1) / (float64(hamPosts) + 2)
+		logOdds += math.Log(pSpam / pHam)
+	}
+	return 1 / (1 + math.Exp(-logOdds)), true, nil
+}
+
+// trainSpamClassifier counts a moderated post as spam or ham. Training a post again
+// with the same class does nothing; with the other class its tokens move over. Call
+// it on the transaction of the moderator's decision.
+func trainSpamClassifier(tx *gorm.DB, targetType string, targetID uint, spam bool) error {
+	text, err := postText(tx, targetType, targetID)
+	if err != nil {
+		return err
+	}
+	tokens := spamTokens(text)
+
+	var trained models.SpamTrainingPost
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("target_type = ? AND target_id = ?", targetType, targetID).Limit(1).Find(&trained).Error; err != nil {
+		return err
+	}
+	if trained.ID != 0 {
+		if trained.IsSpam == spam {
+			return nil
+		}
+		// The post may have been edited since, so some counts may not come back off
+		if err := adjustSpamTokens(tx, tokens, trained.IsSpam, -1); err != nil {
+			return err
+		}
+		if err := tx.Model(&trained).Update("is_spam", spam).Error; err != nil {
+			return err
+		}
+	} else if err := tx.Create(&models.SpamTrainingPost{TargetType: targetType, TargetID: targetID, IsSpam: spam}).Error; err != nil {
+		return err
+	}
+	return adjustSpamTokens(tx, tokens, spam, 1)
+}
+
+// adjustSpamTokens adds delta to the spam or ham count of each token. Counts never go
+// below zero.
+func adjustSpamTokens(tx *gorm.DB, tokens []string, spam bool, delta int) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	column := "ham"
+	if spam {
+		column = "spam"
+	}
+	if delta < 0 {
+		return tx.Model(&models.SpamToken{}).Where("token IN ?", tokens).
+			UpdateColumn(column, gorm.Expr("GREATEST("+column+" + ?, 0)", delta)).Error
+	}
+
+	rows := make([]models.SpamToken, 0, len(tokens))
+	for _, token := range tokens {
+		row := models.SpamToken{Token: token, Ham: delta}
+		if spam {
+			row = models.SpamToken{Token: token, Spam: delta}
+		}
+		rows = append(rows, row)
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{column: gorm.Expr("spam_tokens."+column+" + ?", delta)}),
+	}).Create(&rows).Error
+}
+
+// postText returns the text of a question (title and description) or answer,
+// including deleted ones.
+func postText(db *gorm.DB, targetType string, targetID uint) (string, error) {
+	if targetType == FollowTargetQuestion {
+		var question models.Question
+		if err := db.Unscoped().Select("id", "title", "description").First(&question, targetID).Error; err != nil {
+			return "", err
+		}
+		return question.Title + "\n" + question.Description, nil
+	}
+	var answer models.Answer
+	if err := db.Unscoped().Select("id", "content").First(&answer, targetID).Error; err != nil {
+		return "", err
+	}
+	return answer.Content, nil
+}
+
+// spamTokens splits text into the classifier's features: its distinct lowercase words
+// of three or more characters, outside HTML tags, and the host of each link.
+func spamTokens(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	add := func(token string) {
+		if len(tokens) < maxSpamTokens && !seen[token] && len(token) <= 64 {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	for _, link := range extractLinks(text) {
+		add("host:" + strings.ToLower(link.Hostname()))
+	}
+	for _, word := range spamWordPattern.FindAllString(htmlTagPattern.ReplaceAllString(text, " "), -1) {
+		if len([]rune(word)) >= 3 {
+			add(strings.ToLower(word))
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpamTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"short words are skipped", "go is ok to use", []string{"use"}},
+		{"lowercased and deduplicated", "Cheap cheap CHEAP pills", []string{"cheap", "pills"}},
+		{"inner punctuation kept", "don't use node_modules or e-mail", []string{"don't", "use", "node_modules", "e-mail"}},
+		{"html tags ignored", `<strong class="promo">Deal</strong>`, []string{"deal"}},
+		{"link hosts first", "Visit https://Shop.example/buy now", []string{"host:shop.example", "visit", "https", "shop", "example", "buy", "now"}},
+		{"unicode words", "Größe über für", []string{"größe", "über", "für"}},
+		{"overlong tokens dropped", strings.Repeat("a", 65) + " fine", []string{"fine"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spamTokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spamTokens(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpamTokensLimit(t *testing.T) {
+	var words []string
+	for i := 0; i < maxSpamTokens+50; i++ {
+		words = append(words, fmt.Sprintf("word%d", i))
+	}
+	if got := spamTokens(strings.Join(words, " ")); len(got) != maxSpamTokens {
+		t.Errorf("got %d tokens, want %d", len(got), maxSpamTokens)
+	}
+}
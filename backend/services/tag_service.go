@@ -0,0 +1,357 @@
+// services/tag_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"stackit/cache"
+	"stackit/config"
+	"stackit/models"
+	"stackit/pagination"
+	"stackit/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTagNotFound      = errors.New("tag not found")
+	ErrTagNameTaken     = errors.New("name is already used by a tag or synonym")
+	ErrSynonymNotFound  = errors.New("synonym not found")
+	ErrMergeIntoItself  = errors.New("cannot merge a tag into itself")
+	ErrSynonymIsTagName = errors.New("a synonym cannot point at its own name")
+)
+
+// Sort modes accepted by ListTags
+const (
+	TagSortPopular = "popular"
+	TagSortName    = "name"
+	TagSortNewest  = "newest"
+)
+
+// TagWithCount is a tag with the number of live questions using it.
+type TagWithCount struct {
+	models.Tag
+	QuestionCount int64
+}
+
+type TagService struct {
+	DB *gorm.DB
+
+	// Popular and related lists are aggregates over question_tags, so they are cached
+	// and cleared whenever synonyms or merges reshape the tag set.
+	lists *cache.TTL[string, []TagWithCount]
+}
+
+func NewTagService(db *gorm.DB, cfg *config.Config) *TagService {
+	return &TagService{
+		DB:    db,
+		lists: cache.NewTTL[string, []TagWithCount](time.Duration(cfg.TagCacheSeconds)*time.Second, 1000),
+	}
+}
+
+const tagQuestionCountSQL = `(SELECT COUNT(*) FROM question_tags qt JOIN questions q ON q.id = qt.question_id
+	WHERE qt.tag_id = tags.id AND q.deleted_at IS NULL)`
+
+func (s *TagService) withCounts() *gorm.DB {
+	return s.DB.Model(&models.Tag{}).Select("tags.*, " + tagQuestionCountSQL + " AS question_count")
+}
+
+// ListTags returns one page of tags with usage counts. Name and newest orderings are
+// keyset-paginated; popularity is computed, so it pages by offset.
+func (s *TagService) ListTags(sort string, page pagination.Params) ([]TagWithCount, *pagination.Cursor, error) {
+	if err := page.CheckSort(sort); err != nil {
+		return nil, nil, err
+	}
+
+	db := s.withCounts()
+	switch sort {
+	case TagSortName:
+		db = db.Order("tags.name ASC")
+		if page.After != nil {
+			var lastName string
+			if err := s.DB.Model(&models.Tag{}).Unscoped().Where("id = ?", page.After.ID).Pluck("name", &lastName).Error; err != nil {
+				return nil, nil, err
+			}
+			db = db.Where("tags.name > ?", lastName)
+		}
+	case TagSortNewest:
+		db = db.Order("tags.id DESC")
+		if page.After != nil {
+			db = db.Where("tags.id < ?", page.After.ID)
+		}
+	default:
+		db = db.Order("question_count DESC").Order("tags.name ASC").Offset(page.Offset())
+	}
+
+	var tags []TagWithCount
+	if err := db.Limit(page.Limit + 1).Scan(&tags).Error; err != nil {
+		return nil, nil, err
+	}
+	tags, hasMore := pagination.Trim(tags, page.Limit)
+	if !hasMore {
+		return tags, nil, nil
+	}
+	if sort == TagSortName || sort == TagSortNewest {
+		return tags, &pagination.Cursor{Sort: sort, ID: tags[len(tags)-1].ID}, nil
+	}
+	return tags, &pagination.Cursor{Sort: sort, Offset: page.Offset() + len(tags)}, nil
+}
+
+func (s *TagService) CountTags() (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.Tag{}).Count(&count).Error
+	return count, err
+}
+
+// GetTagByName looks a tag up by name or synonym, with its synonyms and usage count.
+// The raw name is matched too, so tags created before normalization stay reachable.
+func (s *TagService) GetTagByName(name string) (*TagWithCount, error) {
+	names := []string{name}
+	if normalized, err := utils.NormalizeTagName(name); err == nil {
+		names = append(names, normalized)
+	}
+
+	var tag TagWithCount
+	err := s.withCounts().
+		Where("tags.name IN ? OR tags.id IN (SELECT tag_id FROM tag_synonyms WHERE name IN ?)", names, names).
+		Order("tags.id ASC").Take(&tag).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+	if err := s.DB.Where("tag_id = ?", tag.ID).Order("name ASC").Find(&tag.Synonyms).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// UpdateTag edits the excerpt and wiki; nil fields are left unchanged.
+func (s *TagService) UpdateTag(name string, excerpt, wiki *string) (*TagWithCount, error) {
+	tag, err := s.GetTagByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if excerpt != nil {
+		updates["excerpt"] = *excerpt
+	}
+	if wiki != nil {
+		updates["wiki"] = *wiki
+	}
+	if len(updates) > 0 {
+		if err := s.DB.Model(&tag.Tag).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return s.GetTagByName(tag.Name)
+}
+
+// AddSynonym makes synonymName resolve to the given master tag. The actor must be a
+// moderator.
+func (s *TagService) AddSynonym(actor AuditActor, masterName, synonymName string) (*models.TagSynonym, error) {
+	master, err := s.GetTagByName(masterName)
+	if err != nil {
+		return nil, err
+	}
+	name, err := utils.NormalizeTagName(synonymName)
+	if err != nil {
+		return nil, err
+	}
+	if name == master.Name {
+		return nil, ErrSynonymIsTagName
+	}
+
+	synonym := models.TagSynonym{Name: name, TagID: master.ID}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if taken, err := nameTaken(tx, name); err != nil {
+			return err
+		} else if taken {
+			return ErrTagNameTaken
+		}
+		if err := tx.Create(&synonym).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditTagSynonymAdded,
+			TargetType: AuditTargetTag,
+			TargetID:   &master.ID,
+			Details:    map[string]interface{}{"tag": master.Name, "synonym": name},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.lists.Clear()
+	return &synonym, nil
+}
+
+// RemoveSynonym is the inverse of AddSynonym. The actor must be a moderator.
+func (s *TagService) RemoveSynonym(actor AuditActor, masterName, synonymName string) error {
+	master, err := s.GetTagByName(masterName)
+	if err != nil {
+		return err
+	}
+	name, err := utils.NormalizeTagName(synonymName)
+	if err != nil {
+		return ErrSynonymNotFound
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("tag_id = ? AND name = ?", master.ID, name).Delete(&models.TagSynonym{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSynonymNotFound
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditTagSynonymRemoved,
+			TargetType: AuditTargetTag,
+			TargetID:   &master.ID,
+			Details:    map[string]interface{}{"tag": master.Name, "synonym": name},
+		})
+	})
+}
+
+// MergeTags folds the source tag into the target: every QuestionTag and TagPreference
+// is reassigned, the source's synonyms move over, and the source name becomes a
+// synonym of the target.
+func (s *TagService) MergeTags(actor AuditActor, sourceName, targetName string) (*TagWithCount, error) {
+	source, err := s.GetTagByName(sourceName)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.GetTagByName(targetName)
+	if err != nil {
+		return nil, err
+	}
+	if source.ID == target.ID {
+		return nil, ErrMergeIntoItself
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Questions tagged with both keep a single row for the target
+		if err := tx.Exec(`INSERT INTO question_tags (question_id, tag_id)
+			SELECT question_id, ? FROM question_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`, target.ID, source.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&models.QuestionTag{}).Error; err != nil {
+			return err
+		}
+		// Watchers and ignorers of the source follow it into the target; a user who already
+		// had a preference for the target keeps it
+		if err := tx.Exec(`INSERT INTO tag_preferences (user_id, tag_id, kind, created_at)
+			SELECT user_id, ?, kind, created_at FROM tag_preferences WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`, target.ID, source.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&models.TagPreference{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TagSynonym{}).Where("tag_id = ?", source.ID).
+			Update("tag_id", target.ID).Error; err != nil {
+			return err
+		}
+		// Hard delete so the unique name can be reused by the synonym
+		if err := tx.Unscoped().Delete(&models.Tag{}, source.ID).Error; err != nil {
+			return err
+		}
+		// Legacy names that cannot be normalized are not worth keeping as synonyms
+		if synonymName, err := utils.NormalizeTagName(source.Name); err == nil && synonymName != target.Name {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.TagSynonym{Name: synonymName, TagID: target.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return recordAudit(tx, actor, AuditEntry{
+			Action:     AuditTagMerged,
+			TargetType: AuditTargetTag,
+			TargetID:   &target.ID,
+			Details:    map[string]interface{}{"source_id": source.ID, "source": source.Name, "target": target.Name},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.lists.Clear()
+	return s.GetTagByName(target.Name)
+}
+
+// Autocomplete suggests tags for a partially typed name. Prefix matches on the name
+// or a synonym come first, then fuzzy (trigram) matches; ties go to the most used tag.
+func (s *TagService) Autocomplete(prefix string, limit int) ([]TagWithCount, error) {
+	normalized, err := utils.NormalizeTagName(prefix)
+	if err != nil {
+		return []TagWithCount{}, nil // Nothing can match a name that could never be created
+	}
+	// Normalized names cannot contain LIKE wildcards, so no escaping is needed
+	pattern := normalized + "%"
+
+	var tags []TagWithCount
+	err = s.withCounts().
+		Where("tags.name LIKE ? OR tags.name % ? OR tags.id IN (SELECT tag_id FROM tag_synonyms WHERE name LIKE ?)",
+			pattern, normalized, pattern).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL: `(tags.name LIKE ? OR tags.id IN (SELECT tag_id FROM tag_synonyms WHERE name LIKE ?)) DESC,
+				question_count DESC, similarity(tags.name, ?) DESC, tags.name ASC`,
+			Vars:               []interface{}{pattern, pattern, normalized},
+			WithoutParentheses: true,
+		}}).
+		Limit(limit).Scan(&tags).Error
+	return tags, err
+}
+
+// PopularTags returns the most used tags among questions created within the window;
+// a zero window counts all questions. QuestionCount is the count within the window.
+func (s *TagService) PopularTags(window time.Duration, limit int) ([]TagWithCount, error) {
+	return s.lists.GetOrLoad(fmt.Sprintf("popular:%s:%d", window, limit), func() ([]TagWithCount, error) {
+		db := s.DB.Table("question_tags qt").
+			Select("tags.*, COUNT(*) AS question_count").
+			Joins("JOIN questions q ON q.id = qt.question_id AND q.deleted_at IS NULL").
+			Joins("JOIN tags ON tags.id = qt.tag_id AND tags.deleted_at IS NULL")
+		if window > 0 {
+			db = db.Where("q.created_at >= ?", time.Now().Add(-window))
+		}
+
+		var tags []TagWithCount
+		err := db.Group("tags.id").Order("question_count DESC").Order("tags.name ASC").
+			Limit(limit).Scan(&tags).Error
+		return tags, err
+	})
+}
+
+// RelatedTags returns the tags that most often appear on the same questions as the
+// given tag. QuestionCount is the number of questions the two tags share.
+func (s *TagService) RelatedTags(name string, limit int) ([]TagWithCount, error) {
+	tag, err := s.GetTagByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.lists.GetOrLoad(fmt.Sprintf("related:%d:%d", tag.ID, limit), func() ([]TagWithCount, error) {
+		var tags []TagWithCount
+		err := s.DB.Table("question_tags a").
+			Select("tags.*, COUNT(*) AS question_count").
+			Joins("JOIN question_tags b ON b.question_id = a.question_id AND b.tag_id <> a.tag_id").
+			Joins("JOIN questions q ON q.id = a.question_id AND q.deleted_at IS NULL").
+			Joins("JOIN tags ON tags.id = b.tag_id AND tags.deleted_at IS NULL").
+			Where("a.tag_id = ?", tag.ID).
+			Group("tags.id").Order("question_count DESC").Order("tags.name ASC").
+			Limit(limit).Scan(&tags).Error
+		return tags, err
+	})
+}
+
+// nameTaken reports whether a tag or synonym already uses the name.
+func nameTaken(db *gorm.DB, name string) (bool, error) {
+	var count int64
+	err := db.Raw(`SELECT (SELECT COUNT(*) FROM tags WHERE name = ?) + (SELECT COUNT(*) FROM tag_synonyms WHERE name = ?)`,
+		name, name).Scan(&count).Error
+	return count > 0, err
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"stackit/models"
+	"stackit/pagination"
+	"stackit/schemas"
 
 	"gorm.io/gorm"
 )
@@ -38,12 +42,129 @@ func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
-func (s *UserService) GetUnreadNotifications(userID uint) ([]models.Notification, error) {
+// UpdateProfile applies the fields present in the update to the user's profile.
+func (s *UserService) UpdateProfile(userID uint, update *schemas.ProfileUpdate) (*models.User, error) {
+	changes := map[string]interface{}{}
+	setString := func(column string, value *string) {
+		if value != nil {
+			changes[column] = strings.TrimSpace(*value)
+		}
+	}
+	setString("display_name", update.DisplayName)
+	setString("bio", update.Bio)
+	setString("location", update.Location)
+	setString("website_url", update.WebsiteURL)
+	setString("avatar_url", update.AvatarURL)
+	if update.ShowEmail != nil {
+		changes["show_email"] = *update.ShowEmail
+	}
+
+	if len(changes) > 0 {
+		if err := s.DB.Model(&models.User{}).Where("id = ?", userID).Updates(changes).Error; err != nil {
+			return nil, err
+		}
+	}
+	return s.GetUserByID(userID)
+}
+
+// CountPosts returns how many live questions and answers the user has written.
+func (s *UserService) CountPosts(userID uint) (questions, answers int64, err error) {
+	if err = s.DB.Model(&models.Question{}).Where("owner_id = ?", userID).Count(&questions).Error; err != nil {
+		return 0, 0, err
+	}
+	err = s.DB.Model(&models.Answer{}).Where("owner_id = ?", userID).Count(&answers).Error
+	return questions, answers, err
+}
+
+// ActivityItem is one entry of a user's activity timeline.
+type ActivityItem struct {
+	Kind       string // "question", "answer", "accepted_answer" or "badge"
+	ID         uint   // Question, answer or badge award ID
+	QuestionID *uint
+	Title      string // Question title
+	Badge      string // Badge slug
+	OccurredAt time.Time
+	KindOrder  int // Tie-breaker between kinds at the same instant
+}
+
+// activitySQL unions everything that appears on a user's timeline. Each branch binds
+// the user ID and, for posts, then whether hidden ones are included.
+const activitySQL = `
+	SELECT 'question' AS kind, q.id, q.id AS question_id, q.title, '' AS badge, q.created_at AS occurred_at, 1 AS kind_order
+	FROM questions q WHERE q.owner_id = ? AND q.deleted_at IS NULL AND (? OR q.hidden_at IS NULL)
+	UNION ALL
+	SELECT 'answer', a.id, a.question_id, q.title, '', a.created_at, 2
+	FROM answers a JOIN questions q ON q.id = a.question_id AND q.deleted_at IS NULL
+	WHERE a.owner_id = ? AND a.deleted_at IS NULL AND (? OR (a.hidden_at IS NULL AND q.hidden_at IS NULL))
+	UNION ALL
+	SELECT 'accepted_answer', a.id, a.question_id, q.title, '', a.accepted_at, 3
+	FROM answers a JOIN questions q ON q.id = a.question_id AND q.deleted_at IS NULL
+	WHERE a.owner_id = ? AND a.is_accepted AND a.accepted_at IS NOT NULL AND a.deleted_at IS NULL
+		AND (? OR (a.hidden_at IS NULL AND q.hidden_at IS NULL))
+	UNION ALL
+	SELECT 'badge', b.id, CASE WHEN b.post_type = 'question' THEN b.post_id END, '', b.badge, b.created_at, 4
+	FROM badge_awards b WHERE b.user_id = ?`
+
+func activityVars(userID uint, includeHidden bool) []interface{} {
+	return []interface{}{userID, includeHidden, userID, includeHidden, userID, includeHidden, userID}
+}
+
+// GetActivity returns a page of the user's timeline, newest first. Unless includeHidden,
+// hidden posts and answers on hidden questions are left out.
+func (s *UserService) GetActivity(userID uint, includeHidden bool, page pagination.Params) ([]ActivityItem, *pagination.Cursor, error) {
+	query := `SELECT * FROM (` + activitySQL + `) t`
+	vars := activityVars(userID, includeHidden)
+	if page.After != nil {
+		query += ` WHERE (t.occurred_at, t.kind_order, t.id) < (?, ?, ?)`
+		vars = append(vars, page.After.Time, page.After.Int, page.After.ID)
+	}
+	query += ` ORDER BY t.occurred_at DESC, t.kind_order DESC, t.id DESC LIMIT ?`
+	vars = append(vars, page.Limit+1)
+
+	var items []ActivityItem
+	if err := s.DB.Raw(query, vars...).Scan(&items).Error; err != nil {
+		return nil, nil, err
+	}
+	items, hasMore := pagination.Trim(items, page.Limit)
+	if !hasMore {
+		return items, nil, nil
+	}
+	last := items[len(items)-1]
+	return items, &pagination.Cursor{ID: last.ID, Time: last.OccurredAt, Int: int64(last.KindOrder)}, nil
+}
+
+func (s *UserService) CountActivity(userID uint, includeHidden bool) (int64, error) {
+	var count int64
+	err := s.DB.Raw(`SELECT COUNT(*) FROM (`+activitySQL+`) t`, activityVars(userID, includeHidden)...).Scan(&count).Error
+	return count, err
+}
+
+func (s *UserService) unreadNotifications(userID uint) *gorm.DB {
+	return s.DB.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false)
+}
+
+// GetUnreadNotifications returns a page of unread notifications, newest first.
+func (s *UserService) GetUnreadNotifications(userID uint, page pagination.Params) ([]models.Notification, *pagination.Cursor, error) {
+	db := s.unreadNotifications(userID).Order("id DESC")
+	if page.After != nil {
+		db = db.Where("id < ?", page.After.ID)
+	}
+
 	var notifications []models.Notification
-	if err := s.DB.Where("user_id = ? AND is_read = ?", userID, false).Find(&notifications).Error; err != nil {
-		return nil, err
+	if err := db.Limit(page.Limit + 1).Find(&notifications).Error; err != nil {
+		return nil, nil, err
+	}
+	notifications, hasMore := pagination.Trim(notifications, page.Limit)
+	if !hasMore {
+		return notifications, nil, nil
 	}
-	return notifications, nil
+	return notifications, &pagination.Cursor{ID: notifications[len(notifications)-1].ID}, nil
+}
+
+func (s *UserService) CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := s.unreadNotifications(userID).Count(&count).Error
+	return count, err
 }
 
 func (s *UserService) MarkNotificationAsRead(notificationID uint, userID uint) (*models.Notification, error) {
@@ -66,14 +187,6 @@ func (s *UserService) MarkNotificationAsRead(notificationID uint, userID uint) (
 	return &notification, nil
 }
 
-func (s *UserService) GetAllUsers(offset, limit int) ([]models.User, error) {
-	var users []models.User
-	if err := s.DB.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
-		return nil, err
-	}
-	return users, nil
-}
-
 func (s *UserService) CreateNotification(userID uint, message string) error {
 	notification := models.Notification{
 		UserID:  userID,
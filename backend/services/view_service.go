@@ -0,0 +1,129 @@
+// services/view_service.go
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"stackit/config"
+
+	"gorm.io/gorm"
+)
+
+// ViewTracker counts unique question views. Viewers are deduplicated in memory for
+// ViewWindowMinutes and increments are buffered until the next flush, so reading a
+// question never writes to the database directly.
+type ViewTracker struct {
+	DB     *gorm.DB
+	Config *config.Config
+
+	mu      sync.Mutex
+	seen    map[string]time.Time // question/viewer key -> when the counted view expires
+	pending map[uint]int         // question ID -> views not yet flushed
+}
+
+func NewViewTracker(db *gorm.DB, cfg *config.Config) *ViewTracker {
+	return &ViewTracker{
+		DB:      db,
+		Config:  cfg,
+		seen:    map[string]time.Time{},
+		pending: map[uint]int{},
+	}
+}
+
+// ViewerKey identifies a viewer by user ID, or by a hash of IP and user agent for
+// anonymous requests so raw addresses are never kept.
+func ViewerKey(userID uint, ip, userAgent string) string {
+	if userID != 0 {
+		return fmt.Sprintf("u:%d", userID)
+	}
+	sum := sha256.Sum256([]byte(ip + "\x00" + userAgent))
+	return "a:" + hex.EncodeToString(sum[:16])
+}
+
+// RecordView counts a view unless the same viewer was already counted for the
+// question within the window. It reports whether the view was counted.
+func (t *ViewTracker) RecordView(questionID uint, viewer string) bool {
+	key := fmt.Sprintf("%d|%s", questionID, viewer)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if expires, ok := t.seen[key]; ok && now.Before(expires) {
+		return false
+	}
+	t.seen[key] = now.Add(time.Duration(t.Config.ViewWindowMinutes) * time.Minute)
+	t.pending[questionID]++
+	return true
+}
+
+// Pending returns the buffered, not yet flushed views of a question.
+func (t *ViewTracker) Pending(questionID uint) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending[questionID]
+}
+
+// Flush writes buffered increments in a single UPDATE and drops expired dedupe
+// entries. On failure the increments are put back for the next attempt.
+func (t *ViewTracker) Flush() error {
+	now := time.Now()
+
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = map[uint]int{}
+	for key, expires := range t.seen {
+		if !now.Before(expires) {
+			delete(t.seen, key)
+		}
+	}
+	t.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	rows := make([]string, 0, len(batch))
+	args := make([]interface{}, 0, len(batch)*2)
+	for id, n := range batch {
+		rows = append(rows, "(?::bigint, ?::int)")
+		args = append(args, id, n)
+	}
+	err := t.DB.Exec(`UPDATE questions SET view_count = questions.view_count + v.n
+		FROM (VALUES `+strings.Join(rows, ", ")+`) AS v(id, n)
+		WHERE questions.id = v.id`, args...).Error
+	if err != nil {
+		t.mu.Lock()
+		for id, n := range batch {
+			t.pending[id] += n
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RunFlusher flushes every ViewFlushSeconds until ctx is cancelled, then flushes once more.
+func (t *ViewTracker) RunFlusher(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(t.Config.ViewFlushSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				log.Printf("Final view count flush failed: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Printf("View count flush failed: %v", err)
+			}
+		}
+	}
+}
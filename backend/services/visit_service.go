@@ -0,0 +1,51 @@
+// services/visit_service.go
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"stackit/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VisitTracker records the days on which users are active, for the days-visited
+// badges. Each user is written at most once per UTC day per process.
+type VisitTracker struct {
+	DB *gorm.DB
+
+	mu   sync.Mutex
+	seen map[uint]time.Time // user ID -> last day recorded
+}
+
+func NewVisitTracker(db *gorm.DB) *VisitTracker {
+	return &VisitTracker{DB: db, seen: map[uint]time.Time{}}
+}
+
+// RecordVisit notes that the user was active today.
+func (t *VisitTracker) RecordVisit(userID uint) {
+	if userID == 0 {
+		return
+	}
+	today := startOfDayUTC()
+
+	t.mu.Lock()
+	if t.seen[userID].Equal(today) {
+		t.mu.Unlock()
+		return
+	}
+	t.seen[userID] = today
+	t.mu.Unlock()
+
+	err := t.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UserVisit{UserID: userID, Day: today}).Error
+	if err != nil {
+		log.Printf("Failed to record visit for user %d: %v", userID, err)
+		t.mu.Lock()
+		delete(t.seen, userID)
+		t.mu.Unlock()
+	}
+}
@@ -0,0 +1,32 @@
+// storage/storage.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"stackit/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Storage persists uploaded blobs under opaque keys.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the storage backend selected by cfg.StorageBackend ("local" or "s3").
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "local", "":
+		return NewLocalStorage(cfg.UploadDir)
+	case "s3":
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
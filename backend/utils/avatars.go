@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// AvatarSizes are the square pixel sizes avatars are stored and served at.
+var AvatarSizes = []int{32, 64, 128, 256}
+
+// AvatarSize returns the smallest stored size that is at least the requested one,
+// or the largest size for bigger requests.
+func AvatarSize(requested int) int {
+	for _, size := range AvatarSizes {
+		if size >= requested {
+			return size
+		}
+	}
+	return AvatarSizes[len(AvatarSizes)-1]
+}
+
+// CropSquare crops img to the square crop, clamped to the image bounds. An empty crop,
+// or one outside the image, takes the largest centred square.
+func CropSquare(img image.Image, crop image.Rectangle) image.Image {
+	bounds := img.Bounds()
+	crop = crop.Intersect(bounds)
+	if crop.Empty() {
+		side := min(bounds.Dx(), bounds.Dy())
+		x := bounds.Min.X + (bounds.Dx()-side)/2
+		y := bounds.Min.Y + (bounds.Dy()-side)/2
+		crop = image.Rect(x, y, x+side, y+side)
+	}
+	side := min(crop.Dx(), crop.Dy())
+	crop = image.Rect(crop.Min.X, crop.Min.Y, crop.Min.X+side, crop.Min.Y+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, crop.Min, draw.Src)
+	return dst
+}
+
+// ResizeSquare scales a square image to size x size pixels.
+func ResizeSquare(img image.Image, size int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// EncodePNG encodes img as a compressed PNG.
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Identicon draws a deterministic, horizontally symmetric 5x5 pattern derived from
+// seed, in a colour also derived from seed, on a light background.
+func Identicon(seed string, size int) image.Image {
+	sum := sha256.Sum256([]byte(seed))
+	fg := color.RGBA{R: sum[0]/2 + 64, G: sum[1]/2 + 64, B: sum[2]/2 + 64, A: 255}
+	bg := color.RGBA{R: 240, G: 240, B: 240, A: 255}
+
+	const grid = 5
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	padding := size / 10
+	cell := (size - 2*padding) / grid
+	offset := (size - cell*grid) / 2
+	for row := 0; row < grid; row++ {
+		for col := 0; col < (grid+1)/2; col++ {
+			// One bit per cell of the left half; the right half mirrors it
+			bit := sum[3+row*3+col]&1 == 1
+			if !bit {
+				continue
+			}
+			for _, c := range []int{col, grid - 1 - col} {
+				x, y := offset+c*cell, offset+row*cell
+				draw.Draw(img, image.Rect(x, y, x+cell, y+cell), image.NewUniform(fg), image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// coordinateImage returns an image whose pixels encode their own coordinates, so a
+// crop can be located by its first pixel.
+func coordinateImage(r image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	return img
+}
+
+func TestCropSquare(t *testing.T) {
+	tests := []struct {
+		name     string
+		bounds   image.Rectangle
+		crop     image.Rectangle
+		wantMin  image.Point
+		wantSide int
+	}{
+		{"crop inside", image.Rect(0, 0, 100, 80), image.Rect(10, 20, 50, 60), image.Pt(10, 20), 40},
+		{"non-square crop takes the shorter side", image.Rect(0, 0, 100, 80), image.Rect(10, 20, 70, 50), image.Pt(10, 20), 30},
+		{"crop clamped to the image", image.Rect(0, 0, 100, 80), image.Rect(60, 40, 160, 140), image.Pt(60, 40), 40},
+		{"empty crop centres on a landscape image", image.Rect(0, 0, 100, 80), image.Rectangle{}, image.Pt(10, 0), 80},
+		{"empty crop centres on a portrait image", image.Rect(0, 0, 60, 100), image.Rectangle{}, image.Pt(0, 20), 60},
+		{"crop outside the image is ignored", image.Rect(0, 0, 100, 80), image.Rect(200, 200, 240, 240), image.Pt(10, 0), 80},
+		{"image not at the origin", image.Rect(50, 50, 90, 70), image.Rectangle{}, image.Pt(60, 50), 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := coordinateImage(tt.bounds)
+			got := CropSquare(src, tt.crop)
+			if want := image.Rect(0, 0, tt.wantSide, tt.wantSide); got.Bounds() != want {
+				t.Fatalf("bounds = %v, want %v", got.Bounds(), want)
+			}
+			for _, p := range []image.Point{{}, {tt.wantSide - 1, tt.wantSide - 1}} {
+				want := src.At(tt.wantMin.X+p.X, tt.wantMin.Y+p.Y)
+				if got.At(p.X, p.Y) != want {
+					t.Errorf("pixel %v = %v, want %v", p, got.At(p.X, p.Y), want)
+				}
+			}
+		})
+	}
+}
+
+func TestIdenticon(t *testing.T) {
+	for _, size := range AvatarSizes {
+		img := Identicon("alice", size)
+		if want := image.Rect(0, 0, size, size); img.Bounds() != want {
+			t.Fatalf("Identicon(%d) bounds = %v, want %v", size, img.Bounds(), want)
+		}
+
+		// Sample the centre of every cell; each row must mirror around the middle column
+		const grid = 5
+		padding := size / 10
+		cell := (size - 2*padding) / grid
+		offset := (size - cell*grid) / 2
+		at := func(row, col int) color.Color {
+			return img.At(offset+col*cell+cell/2, offset+row*cell+cell/2)
+		}
+		for row := 0; row < grid; row++ {
+			for col := 0; col < grid/2; col++ {
+				if at(row, col) != at(row, grid-1-col) {
+					t.Errorf("Identicon(%d) row %d is not symmetric at column %d", size, row, col)
+				}
+			}
+		}
+
+		if got, want := img.At(0, 0), (color.RGBA{R: 240, G: 240, B: 240, A: 255}); got != want {
+			t.Errorf("Identicon(%d) corner = %v, want the background %v", size, got, want)
+		}
+	}
+}
+
+func TestIdenticonDeterministic(t *testing.T) {
+	a, b := encodeIdenticon(t, "alice"), encodeIdenticon(t, "alice")
+	if string(a) != string(b) {
+		t.Error("Identicon differs between calls with the same seed")
+	}
+	if string(a) == string(encodeIdenticon(t, "bob")) {
+		t.Error("Identicon is the same for different seeds")
+	}
+}
+
+func encodeIdenticon(t *testing.T, seed string) []byte {
+	t.Helper()
+	data, err := EncodePNG(Identicon(seed, 64))
+	if err != nil {
+		t.Fatalf("EncodePNG: %v", err)
+	}
+	return data
+}
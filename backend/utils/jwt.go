@@ -7,17 +7,19 @@ import (
 )
 
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID         uint   `json:"user_id"`
+	Username       string `json:"username"`
+	Role           string `json:"role"`
+	SessionVersion int    `json:"sv"` // Must match the user's current version, see models.User
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(username string, role string, expirationTime time.Time, secretKey string) (string, error) {
-	// In a real app, you'd also include the user's ID
+func GenerateJWT(userID uint, username string, role string, sessionVersion int, expirationTime time.Time, secretKey string) (string, error) {
 	claims := &Claims{
-		Username: username,
-		Role:     role,
+		UserID:         userID,
+		Username:       username,
+		Role:           role,
+		SessionVersion: sessionVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
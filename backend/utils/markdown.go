@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	ContentFormatHTML     = "html"
+	ContentFormatMarkdown = "markdown"
+)
+
+var (
+	// CommonMark plus the GFM table extension. Fenced code blocks are part of CommonMark.
+	markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.Table))
+	htmlPolicy       = newHTMLPolicy()
+)
+
+func newHTMLPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Keep the language hint on fenced code blocks (e.g. class="language-go")
+	p.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code")
+	return p
+}
+
+// RenderContent converts post content in the given format into sanitized HTML.
+func RenderContent(source, format string) (string, error) {
+	switch format {
+	case ContentFormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdownRenderer.Convert([]byte(source), &buf); err != nil {
+			return "", err
+		}
+		return htmlPolicy.Sanitize(buf.String()), nil
+	case ContentFormatHTML, "":
+		return htmlPolicy.Sanitize(source), nil
+	default:
+		return "", fmt.Errorf("unsupported content format: %s", format)
+	}
+}
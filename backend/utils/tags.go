@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const MaxTagLength = 35
+
+var (
+	ErrInvalidTagName = errors.New("invalid tag name")
+
+	tagSeparators = regexp.MustCompile(`[\s_]+`)
+	// Lowercase letters, digits and the punctuation used by names like c++, c#, .net or
+	// node.js; must start with a letter or digit (or "." for .net style names).
+	tagNamePattern = regexp.MustCompile(`^[a-z0-9.][a-z0-9+#.\-]*$`)
+)
+
+// NormalizeTagName lowercases a tag, joins words with hyphens and validates the result,
+// so "Go Lang" and "go_lang" both become "go-lang".
+func NormalizeTagName(name string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	normalized = tagSeparators.ReplaceAllString(normalized, "-")
+	normalized = strings.Trim(normalized, "-")
+
+	if normalized == "" {
+		return "", fmt.Errorf("%w: tag is empty", ErrInvalidTagName)
+	}
+	if len(normalized) > MaxTagLength {
+		return "", fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTagName, normalized, MaxTagLength)
+	}
+	if !tagNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("%w: %q may only contain a-z, 0-9, '+', '#', '.' and '-'", ErrInvalidTagName, normalized)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTagName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"go", "go"},
+		{"  Go  ", "go"},
+		{"Go Lang", "go-lang"},
+		{"go_lang", "go-lang"},
+		{"go \t_ lang", "go-lang"},
+		{"_go_", "go"},
+		{"-go-", "go"},
+		{"C++", "c++"},
+		{"C#", "c#"},
+		{".NET", ".net"},
+		{"Node.js", "node.js"},
+		{"python-3.x", "python-3.x"},
+		{strings.Repeat("a", MaxTagLength), strings.Repeat("a", MaxTagLength)},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeTagName(tt.name)
+		if err != nil {
+			t.Errorf("NormalizeTagName(%q) error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeTagName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTagNameInvalid(t *testing.T) {
+	for _, name := range []string{
+		"",
+		"   ",
+		"__",
+		"+go",
+		"#go",
+		"go/lang",
+		"gö",
+		"go!",
+		strings.Repeat("a", MaxTagLength+1),
+	} {
+		if got, err := NormalizeTagName(name); !errors.Is(err, ErrInvalidTagName) {
+			t.Errorf("NormalizeTagName(%q) = %q, %v, want ErrInvalidTagName", name, got, err)
+		}
+	}
+}